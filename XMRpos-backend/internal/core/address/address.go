@@ -0,0 +1,158 @@
+// Package address encodes Monero addresses, so integrated addresses can be handed out
+// for a light-wallet server without a round trip to the wallet.
+package address
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Monero base58 encodes 8 byte blocks into 11 characters, a shorter last block into fewer
+const (
+	fullBlockSize        = 8
+	fullEncodedBlockSize = 11
+)
+
+var encodedBlockSizes = []int{0, 2, 3, 5, 6, 7, 9, 10, 11}
+
+const (
+	keySize       = 32
+	checksumSize  = 4
+	PaymentIDSize = 8
+)
+
+// Network bytes of standard addresses and of the integrated addresses derived from them
+var integratedPrefixes = map[byte]byte{
+	18: 19, // mainnet
+	53: 54, // testnet
+	24: 25, // stagenet
+}
+
+var ErrInvalidAddress = errors.New("invalid Monero address")
+
+// NewPaymentID returns a random 8 byte payment ID as hex
+func NewPaymentID() (string, error) {
+	id := make([]byte, PaymentIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ValidateStandard checks that addr is a standard (primary) address with a valid checksum
+func ValidateStandard(addr string) error {
+	_, err := decodeStandard(addr)
+	return err
+}
+
+// Integrated combines a standard address with a hex payment ID
+func Integrated(standard string, paymentID string) (string, error) {
+	data, err := decodeStandard(standard)
+	if err != nil {
+		return "", err
+	}
+	id, err := hex.DecodeString(paymentID)
+	if err != nil || len(id) != PaymentIDSize {
+		return "", fmt.Errorf("payment ID must be %d hex encoded bytes", PaymentIDSize)
+	}
+
+	out := make([]byte, 0, 1+2*keySize+PaymentIDSize+checksumSize)
+	out = append(out, integratedPrefixes[data[0]])
+	out = append(out, data[1:1+2*keySize]...)
+	out = append(out, id...)
+	return encode(append(out, checksum(out)...)), nil
+}
+
+func decodeStandard(addr string) ([]byte, error) {
+	data, err := decode(addr)
+	if err != nil || len(data) != 1+2*keySize+checksumSize {
+		return nil, ErrInvalidAddress
+	}
+	if _, ok := integratedPrefixes[data[0]]; !ok {
+		return nil, ErrInvalidAddress
+	}
+	body := data[:len(data)-checksumSize]
+	if string(checksum(body)) != string(data[len(body):]) {
+		return nil, ErrInvalidAddress
+	}
+	return data, nil
+}
+
+func checksum(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)[:checksumSize]
+}
+
+func encode(data []byte) string {
+	var sb strings.Builder
+	for len(data) > 0 {
+		n := min(len(data), fullBlockSize)
+		sb.WriteString(encodeBlock(data[:n]))
+		data = data[n:]
+	}
+	return sb.String()
+}
+
+func encodeBlock(block []byte) string {
+	num := new(big.Int).SetBytes(block)
+	size := encodedBlockSizes[len(block)]
+	out := make([]byte, size)
+	base := big.NewInt(int64(len(alphabet)))
+	rem := new(big.Int)
+	for i := size - 1; i >= 0; i-- {
+		num.DivMod(num, base, rem)
+		out[i] = alphabet[rem.Int64()]
+	}
+	return string(out)
+}
+
+func decode(s string) ([]byte, error) {
+	var out []byte
+	for len(s) > 0 {
+		n := min(len(s), fullEncodedBlockSize)
+		block, err := decodeBlock(s[:n])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+		s = s[n:]
+	}
+	return out, nil
+}
+
+func decodeBlock(s string) ([]byte, error) {
+	size := -1
+	for i, encoded := range encodedBlockSizes {
+		if encoded == len(s) {
+			size = i
+			break
+		}
+	}
+	if size < 0 {
+		return nil, ErrInvalidAddress
+	}
+
+	num := new(big.Int)
+	base := big.NewInt(int64(len(alphabet)))
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 {
+			return nil, ErrInvalidAddress
+		}
+		num.Mul(num, base)
+		num.Add(num, big.NewInt(int64(digit)))
+	}
+	if num.BitLen() > size*8 {
+		return nil, ErrInvalidAddress
+	}
+	return num.FillBytes(make([]byte, size)), nil
+}
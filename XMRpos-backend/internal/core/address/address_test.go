@@ -0,0 +1,71 @@
+package address_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/address"
+)
+
+// The Monero general fund donation address
+const generalFund = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+
+func TestIntegrated(t *testing.T) {
+	if err := address.ValidateStandard(generalFund); err != nil {
+		t.Fatalf("valid address rejected: %v", err)
+	}
+
+	integrated, err := address.Integrated(generalFund, "0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "4DrvGduF3ynBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVPkQywN5o4wNU3C4oBH"
+	if integrated != want {
+		t.Fatalf("integrated address: got %s, want %s", integrated, want)
+	}
+
+	if _, err := address.Integrated(generalFund, "0123"); err == nil {
+		t.Fatal("short payment ID accepted")
+	}
+}
+
+func TestValidateStandard(t *testing.T) {
+	for name, addr := range map[string]string{
+		"bad checksum": generalFund[:len(generalFund)-1] + "B",
+		"truncated":    generalFund[:90],
+		"bad alphabet": "0" + generalFund[1:],
+		"integrated":   "4DrvGduF3ynBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVPkQywN5o4wNU3C4oBH",
+	} {
+		if err := address.ValidateStandard(addr); !errors.Is(err, address.ErrInvalidAddress) {
+			t.Fatalf("%s: got %v, want ErrInvalidAddress", name, err)
+		}
+	}
+}
+
+func TestNewPaymentID(t *testing.T) {
+	a, err := address.NewPaymentID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := address.NewPaymentID()
+	if len(a) != 2*address.PaymentIDSize || a == b {
+		t.Fatalf("unexpected payment IDs %s and %s", a, b)
+	}
+}
+
+func TestPaymentURI(t *testing.T) {
+	uri := address.PaymentURI(generalFund, 1_500_000_000_000, "Café & Bar", "2 coffees")
+	want := "monero:" + generalFund + "?tx_amount=1.5&recipient_name=Caf%C3%A9%20%26%20Bar&tx_description=2%20coffees"
+	if uri != want {
+		t.Fatalf("got %s, want %s", uri, want)
+	}
+	if uri := address.PaymentURI(generalFund, 0, "", ""); uri != "monero:"+generalFund {
+		t.Fatalf("empty parameters not left out: %s", uri)
+	}
+
+	for amount, want := range map[int64]string{1: "0.000000000001", 2_000_000_000_000: "2", 123_450_000_000: "0.12345"} {
+		if got := address.FormatXMR(amount); got != want {
+			t.Fatalf("FormatXMR(%d): got %s, want %s", amount, got, want)
+		}
+	}
+}
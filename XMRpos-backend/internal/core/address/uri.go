@@ -0,0 +1,44 @@
+package address
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const atomicUnitsPerXMR = 1_000_000_000_000
+
+// PaymentURI builds a monero: URI as wallets expect it in a QR code. Empty parameters are left out.
+func PaymentURI(addr string, amount int64, recipientName string, description string) string {
+	var params []string
+	if amount > 0 {
+		params = append(params, "tx_amount="+FormatXMR(amount))
+	}
+	if recipientName != "" {
+		params = append(params, "recipient_name="+escape(recipientName))
+	}
+	if description != "" {
+		params = append(params, "tx_description="+escape(description))
+	}
+
+	uri := "monero:" + addr
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// FormatXMR renders atomic units as a decimal XMR amount without trailing zeros
+func FormatXMR(amount int64) string {
+	whole := strconv.FormatInt(amount/atomicUnitsPerXMR, 10)
+	frac := strings.TrimRight(strconv.FormatInt(atomicUnitsPerXMR+amount%atomicUnitsPerXMR, 10)[1:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// Wallets don't all decode "+" as a space, so spaces are percent-encoded
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
@@ -2,12 +2,45 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/address"
 )
 
+// Supported values for PAYMENT_BACKEND
+const (
+	PaymentBackendMoneroPay = "moneropay"
+	PaymentBackendWalletRPC = "walletrpc"
+)
+
+// Supported values for EXCHANGE_RATE_PROVIDER
+const (
+	ExchangeRateProviderCryptoCompare = "cryptocompare"
+	ExchangeRateProviderFixed         = "fixed"
+)
+
+// Supported values for MAIL_SENDER
+const (
+	MailSenderSMTP = "smtp"
+	MailSenderLog  = "log"
+)
+
+// How far the amount sent by a POS may be off the server exchange rate when EXCHANGE_RATE_TOLERANCE_PERCENT is not set
+const defaultExchangeRateTolerancePercent = 2
+
+// DefaultCheckoutPageURL is the checkout page in web/ when CHECKOUT_PAGE_URL is not set
+const DefaultCheckoutPageURL = "/checkout.html"
+
+// DefaultReorgWatchWindow is how long confirmed payments are still checked for reorgs, and their
+// credits held back from payouts, when REORG_WATCH_WINDOW is not set
+const DefaultReorgWatchWindow = 2 * time.Hour
+
 type Config struct {
 	// Admin Configuration
 	AdminName     string
@@ -29,6 +62,65 @@ type Config struct {
 	JWTMoneroPaySecret string
 	JWTLwsToken        string
 
+	// LwsAddress is the standard address watched by the light-wallet server. When set, every
+	// transaction gets an integrated address with its own payment ID so LWS hooks can be matched.
+	LwsAddress string
+
+	// Payment Backend Configuration
+	PaymentBackend string
+	// PaymentTolerancePercent is how far a payment may miss the amount and still count as paid
+	PaymentTolerancePercent float64
+
+	// Invoice Settings
+	// InvoiceExpiry is how long the amount quoted for a transaction stays valid
+	InvoiceExpiry time.Duration
+	// LatePaymentWindow is how long expired invoices are still watched for late payments
+	LatePaymentWindow time.Duration
+	// ReorgWatchWindow is how long confirmed payments are still checked for double spends and reorgs
+	ReorgWatchWindow time.Duration
+
+	// Exchange Rate Settings
+	// ExchangeRateProvider is empty when the rate implied by the POS request is trusted
+	ExchangeRateProvider string
+	ExchangeRateAPIURL   string
+	FixedExchangeRates   map[string]float64
+	ExchangeRateCacheTTL time.Duration
+	// ExchangeRateTolerancePercent is how far a POS supplied amount may be off the server rate
+	ExchangeRateTolerancePercent float64
+
+	// Webhook Settings
+	// WebhookRetryBaseDelay is how long the first retry of a failed delivery waits, it doubles on every attempt
+	WebhookRetryBaseDelay time.Duration
+	// WebhookAllowHTTP permits plain http endpoints, meant for local development only
+	WebhookAllowHTTP bool
+	// WebhookAllowPrivateHosts permits endpoints on loopback, private and other internal addresses, meant for local development only
+	WebhookAllowPrivateHosts bool
+
+	// IdempotencyKeyTTL is how long the response to a request with an Idempotency-Key is kept for retries
+	IdempotencyKeyTTL time.Duration
+
+	// Mail Settings
+	// MailSender is empty when the backend sends no email
+	MailSender   string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// MailFrom is the sender of every email, an address optionally with a display name
+	MailFrom string
+
+	// Payout Address Settings
+	// PayoutAddressCoolingOff is how long a payout address change waits before it takes effect, payouts are frozen meanwhile
+	PayoutAddressCoolingOff time.Duration
+	// PayoutAddressEmailConfirmation makes vendors with an email address confirm changes through an emailed link
+	PayoutAddressEmailConfirmation bool
+	// PayoutAddressConfirmURL is the page the confirmation link points to, the token is appended as ?token=
+	PayoutAddressConfirmURL string
+
+	// Checkout Settings
+	// CheckoutPageURL is the hosted checkout page invoices link to, the token is appended as ?token=
+	CheckoutPageURL string
+
 	// MoneroPay API Configuration
 	MoneroPayBaseURL     string
 	MoneroPayCallbackURL string
@@ -45,6 +137,13 @@ type Config struct {
 	WalletName              string
 	WalletPassword          string
 	WalletAutoRefreshPeriod uint32
+
+	// Background Worker Settings
+	ConfirmationCheckInterval time.Duration
+	TransferCompleterInterval time.Duration
+	ExpiryCheckInterval       time.Duration
+	WebhookDispatchInterval   time.Duration
+	PayoutSchedulerInterval   time.Duration
 }
 
 func LoadConfig() (*Config, error) {
@@ -72,6 +171,29 @@ func LoadConfig() (*Config, error) {
 		JWTRefreshSecret:   os.Getenv("JWT_REFRESH_SECRET"),
 		JWTMoneroPaySecret: os.Getenv("JWT_MONEROPAY_SECRET"),
 		JWTLwsToken:        os.Getenv("JWT_LWS_TOKEN"),
+		LwsAddress:         os.Getenv("LWS_ADDRESS"),
+
+		// Payment Backend Configuration
+		PaymentBackend: os.Getenv("PAYMENT_BACKEND"),
+
+		// Exchange Rate Configuration
+		ExchangeRateProvider:         os.Getenv("EXCHANGE_RATE_PROVIDER"),
+		ExchangeRateAPIURL:           os.Getenv("EXCHANGE_RATE_API_URL"),
+		ExchangeRateTolerancePercent: defaultExchangeRateTolerancePercent,
+
+		// Mail Configuration
+		MailSender:   os.Getenv("MAIL_SENDER"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailFrom:     os.Getenv("MAIL_FROM"),
+
+		// Payout Address Configuration
+		PayoutAddressConfirmURL: os.Getenv("PAYOUT_ADDRESS_CONFIRM_URL"),
+
+		// Checkout Configuration
+		CheckoutPageURL: os.Getenv("CHECKOUT_PAGE_URL"),
 
 		// MoneroPay API Configuration
 		MoneroPayBaseURL:     os.Getenv("MONEROPAY_BASE_URL"),
@@ -98,6 +220,220 @@ func LoadConfig() (*Config, error) {
 		config.WalletAutoRefreshPeriod = uint32(value)
 	}
 
+	if interval := os.Getenv("CONFIRMATION_CHECK_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid CONFIRMATION_CHECK_INTERVAL: %s", interval)
+		}
+		config.ConfirmationCheckInterval = value
+	}
+
+	if interval := os.Getenv("TRANSFER_COMPLETER_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid TRANSFER_COMPLETER_INTERVAL: %s", interval)
+		}
+		config.TransferCompleterInterval = value
+	}
+
+	if interval := os.Getenv("EXPIRY_CHECK_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid EXPIRY_CHECK_INTERVAL: %s", interval)
+		}
+		config.ExpiryCheckInterval = value
+	}
+
+	if interval := os.Getenv("WEBHOOK_DISPATCH_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_DISPATCH_INTERVAL: %s", interval)
+		}
+		config.WebhookDispatchInterval = value
+	}
+
+	if interval := os.Getenv("PAYOUT_SCHEDULER_INTERVAL"); interval != "" {
+		value, err := time.ParseDuration(interval)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid PAYOUT_SCHEDULER_INTERVAL: %s", interval)
+		}
+		config.PayoutSchedulerInterval = value
+	}
+
+	if delay := os.Getenv("WEBHOOK_RETRY_BASE_DELAY"); delay != "" {
+		value, err := time.ParseDuration(delay)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE_DELAY: %s", delay)
+		}
+		config.WebhookRetryBaseDelay = value
+	}
+
+	if allow := os.Getenv("WEBHOOK_ALLOW_HTTP"); allow != "" {
+		value, err := strconv.ParseBool(allow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_HTTP: %s", allow)
+		}
+		config.WebhookAllowHTTP = value
+	}
+
+	if allow := os.Getenv("WEBHOOK_ALLOW_PRIVATE_HOSTS"); allow != "" {
+		value, err := strconv.ParseBool(allow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE_HOSTS: %s", allow)
+		}
+		config.WebhookAllowPrivateHosts = value
+	}
+
+	if coolingOff := os.Getenv("PAYOUT_ADDRESS_COOLING_OFF"); coolingOff != "" {
+		value, err := time.ParseDuration(coolingOff)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid PAYOUT_ADDRESS_COOLING_OFF: %s", coolingOff)
+		}
+		config.PayoutAddressCoolingOff = value
+	}
+
+	if confirm := os.Getenv("PAYOUT_ADDRESS_EMAIL_CONFIRMATION"); confirm != "" {
+		value, err := strconv.ParseBool(confirm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PAYOUT_ADDRESS_EMAIL_CONFIRMATION: %s", confirm)
+		}
+		config.PayoutAddressEmailConfirmation = value
+	}
+
+	if expiry := os.Getenv("INVOICE_EXPIRY"); expiry != "" {
+		value, err := time.ParseDuration(expiry)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid INVOICE_EXPIRY: %s", expiry)
+		}
+		config.InvoiceExpiry = value
+	}
+
+	if window := os.Getenv("LATE_PAYMENT_WINDOW"); window != "" {
+		value, err := time.ParseDuration(window)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid LATE_PAYMENT_WINDOW: %s", window)
+		}
+		config.LatePaymentWindow = value
+	}
+
+	if window := os.Getenv("REORG_WATCH_WINDOW"); window != "" {
+		value, err := time.ParseDuration(window)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid REORG_WATCH_WINDOW: %s", window)
+		}
+		config.ReorgWatchWindow = value
+	}
+
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		value, err := time.ParseDuration(ttl)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid IDEMPOTENCY_KEY_TTL: %s", ttl)
+		}
+		config.IdempotencyKeyTTL = value
+	}
+
+	if tolerance := os.Getenv("PAYMENT_TOLERANCE_PERCENT"); tolerance != "" {
+		value, err := strconv.ParseFloat(tolerance, 64)
+		if err != nil || value < 0 || value > 100 {
+			return nil, fmt.Errorf("invalid PAYMENT_TOLERANCE_PERCENT: %s", tolerance)
+		}
+		config.PaymentTolerancePercent = value
+	}
+
+	if ttl := os.Getenv("EXCHANGE_RATE_CACHE_TTL"); ttl != "" {
+		value, err := time.ParseDuration(ttl)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATE_CACHE_TTL: %s", ttl)
+		}
+		config.ExchangeRateCacheTTL = value
+	}
+
+	if tolerance := os.Getenv("EXCHANGE_RATE_TOLERANCE_PERCENT"); tolerance != "" {
+		value, err := strconv.ParseFloat(tolerance, 64)
+		if err != nil || value < 0 || value > 100 {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATE_TOLERANCE_PERCENT: %s", tolerance)
+		}
+		config.ExchangeRateTolerancePercent = value
+	}
+
+	if fixed := os.Getenv("EXCHANGE_RATE_FIXED"); fixed != "" {
+		rates, err := parseFixedRates(fixed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATE_FIXED: %w", err)
+		}
+		config.FixedExchangeRates = rates
+	}
+
+	switch config.ExchangeRateProvider {
+	case "", ExchangeRateProviderCryptoCompare:
+	case ExchangeRateProviderFixed:
+		if len(config.FixedExchangeRates) == 0 {
+			return nil, fmt.Errorf("EXCHANGE_RATE_FIXED is required with the fixed exchange rate provider")
+		}
+	default:
+		return nil, fmt.Errorf("invalid EXCHANGE_RATE_PROVIDER: %s", config.ExchangeRateProvider)
+	}
+
+	if config.SMTPPort != "" {
+		value, err := strconv.Atoi(config.SMTPPort)
+		if err != nil || value <= 0 || value > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %s", config.SMTPPort)
+		}
+	}
+
+	if config.MailFrom != "" {
+		if _, err := mail.ParseAddress(config.MailFrom); err != nil {
+			return nil, fmt.Errorf("invalid MAIL_FROM: %s", config.MailFrom)
+		}
+	}
+
+	switch config.MailSender {
+	case "", MailSenderLog:
+	case MailSenderSMTP:
+		if config.SMTPHost == "" || config.MailFrom == "" {
+			return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are required with the smtp mail sender")
+		}
+	default:
+		return nil, fmt.Errorf("invalid MAIL_SENDER: %s", config.MailSender)
+	}
+
+	// The confirmation link is opened from an email, so it must be absolute
+	if config.PayoutAddressEmailConfirmation {
+		if config.MailSender == "" {
+			return nil, fmt.Errorf("MAIL_SENDER is required with PAYOUT_ADDRESS_EMAIL_CONFIRMATION")
+		}
+		parsed, err := url.Parse(config.PayoutAddressConfirmURL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return nil, fmt.Errorf("invalid PAYOUT_ADDRESS_CONFIRM_URL: %q", config.PayoutAddressConfirmURL)
+		}
+	}
+
+	if config.LwsAddress != "" {
+		if err := address.ValidateStandard(config.LwsAddress); err != nil {
+			return nil, fmt.Errorf("invalid LWS_ADDRESS: %w", err)
+		}
+	}
+
+	if config.CheckoutPageURL == "" {
+		config.CheckoutPageURL = DefaultCheckoutPageURL
+	} else if parsed, err := url.Parse(config.CheckoutPageURL); err != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return nil, fmt.Errorf("invalid CHECKOUT_PAGE_URL: %s", config.CheckoutPageURL)
+	}
+
+	switch config.PaymentBackend {
+	case "":
+		config.PaymentBackend = PaymentBackendMoneroPay
+	case PaymentBackendMoneroPay, PaymentBackendWalletRPC:
+	default:
+		return nil, fmt.Errorf("invalid PAYMENT_BACKEND: %s", config.PaymentBackend)
+	}
+
+	// Payments to the LWS address land in the primary account of its wallet, while the wallet RPC
+	// backend pays vendors out of their own accounts, which would never hold those funds
+	if config.LwsAddress != "" && config.PaymentBackend == PaymentBackendWalletRPC {
+		return nil, fmt.Errorf("LWS_ADDRESS cannot be used with the %s payment backend, it keeps a wallet account per vendor", PaymentBackendWalletRPC)
+	}
+
 	// Validate required fields
 	if config.AdminName == "" ||
 		config.AdminPassword == "" ||
@@ -110,12 +446,33 @@ func LoadConfig() (*Config, error) {
 		config.JWTSecret == "" ||
 		config.JWTRefreshSecret == "" ||
 		config.JWTMoneroPaySecret == "" ||
-		config.MoneroPayBaseURL == "" ||
-		config.MoneroPayCallbackURL == "" ||
 		config.JWTLwsToken == "" ||
 		config.MoneroWalletRPCEndpoint == "" {
 		return nil, fmt.Errorf("missing required environment variables")
 	}
 
+	// MoneroPay is only needed when it is the payment backend
+	if config.PaymentBackend == PaymentBackendMoneroPay &&
+		(config.MoneroPayBaseURL == "" || config.MoneroPayCallbackURL == "") {
+		return nil, fmt.Errorf("missing required environment variables for the MoneroPay payment backend")
+	}
+
 	return config, nil
 }
+
+// parseFixedRates reads a price list like "EUR=150.5,USD=162"
+func parseFixedRates(value string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		currency, rate, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || currency == "" {
+			return nil, fmt.Errorf("expected CURRENCY=RATE, got %q", pair)
+		}
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s: %s", currency, rate)
+		}
+		rates[strings.ToUpper(currency)] = parsed
+	}
+	return rates, nil
+}
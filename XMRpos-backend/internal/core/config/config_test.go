@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lwsAddress = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+
+// loadConfig runs LoadConfig with the minimal environment of a MoneroPay setup plus env
+func loadConfig(t *testing.T, env map[string]string) (*Config, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	for key, value := range map[string]string{
+		"ADMIN_NAME": "admin", "ADMIN_PASSWORD": "admin-password", "PORT": "8080",
+		"DB_HOST": "localhost", "DB_USER": "xmrpos", "DB_PASSWORD": "secret", "DB_NAME": "xmrpos", "DB_PORT": "5432",
+		"JWT_SECRET": "jwt", "JWT_REFRESH_SECRET": "refresh", "JWT_MONEROPAY_SECRET": "moneropay", "JWT_LWS_TOKEN": "lws",
+		"MONERO_WALLET_RPC_ENDPOINT": "http://localhost:18083/json_rpc",
+		"MONEROPAY_BASE_URL":         "http://localhost:5000", "MONEROPAY_CALLBACK_URL": "http://localhost:8080/callback",
+	} {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return LoadConfig()
+}
+
+func TestLwsAddressWithPerVendorAccounts(t *testing.T) {
+	t.Run("moneropay", func(t *testing.T) {
+		cfg, err := loadConfig(t, map[string]string{"LWS_ADDRESS": lwsAddress})
+		if err != nil {
+			t.Fatalf("LWS with MoneroPay: %v", err)
+		}
+		if cfg.LwsAddress != lwsAddress || cfg.PaymentBackend != PaymentBackendMoneroPay {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("walletrpc without lws", func(t *testing.T) {
+		if _, err := loadConfig(t, map[string]string{"PAYMENT_BACKEND": PaymentBackendWalletRPC}); err != nil {
+			t.Fatalf("wallet RPC without LWS: %v", err)
+		}
+	})
+
+	// Vendors would be paid out of accounts that never receive the payments to the LWS address
+	t.Run("walletrpc with lws", func(t *testing.T) {
+		_, err := loadConfig(t, map[string]string{"LWS_ADDRESS": lwsAddress, "PAYMENT_BACKEND": PaymentBackendWalletRPC})
+		if err == nil || !strings.Contains(err.Error(), "LWS_ADDRESS") {
+			t.Fatalf("LWS with per-vendor wallet accounts: got %v, want an LWS_ADDRESS error", err)
+		}
+	})
+}
@@ -3,10 +3,12 @@ package db
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/ledger"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -48,9 +50,22 @@ func NewPostgresClient(cfg *config.Config) (*gorm.DB, error) {
 		&models.Invite{},
 		&models.Transaction{},
 		&models.SubTransaction{},
+		&models.LineItem{},
 		&models.Pos{},
 		&models.Vendor{},
+		&models.ConfirmationTier{},
+		&models.PayoutDestination{},
+		&models.PayoutAddressChange{},
 		&models.Transfer{},
+		&models.TransferDestination{},
+		&models.LedgerEntry{},
+		&models.Refund{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.APIKey{},
+		&models.IdempotencyKey{},
+		&models.ProductCategory{},
+		&models.Product{},
 	)
 	if err != nil {
 		return nil, err
@@ -60,6 +75,22 @@ func NewPostgresClient(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	if err := backfillTransactionStatus(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill transaction status: %w", err)
+	}
+
+	if err := backfillTransactionExpiry(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill transaction expiry: %w", err)
+	}
+
+	if err := backfillExchangeRates(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill exchange rates: %w", err)
+	}
+
+	if err := backfillLedger(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill ledger: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -123,6 +154,83 @@ func ensureNameIndexes(db *gorm.DB) error {
 	return nil
 }
 
+// backfillTransactionStatus marks transactions confirmed before payment states existed as paid in full
+func backfillTransactionStatus(db *gorm.DB) error {
+	return db.Model(&models.Transaction{}).
+		Where("confirmed = ? AND amount_received = ?", true, 0).
+		Updates(map[string]interface{}{
+			"status":          models.TransactionStatusPaid,
+			"amount_received": gorm.Expr("amount"),
+		}).Error
+}
+
+// backfillTransactionExpiry gives transactions created before invoices expired the
+// 2 hour window after which the pending cleanup used to delete them
+func backfillTransactionExpiry(db *gorm.DB) error {
+	return db.Model(&models.Transaction{}).
+		Where("expires_at IS NULL").
+		Update("expires_at", gorm.Expr("created_at + interval '2 hours'")).Error
+}
+
+// backfillExchangeRates records the rate implied by the POS request on transactions
+// created before the backend recorded exchange rates
+func backfillExchangeRates(db *gorm.DB) error {
+	return db.Model(&models.Transaction{}).
+		Where("exchange_rate = 0 AND amount > 0 AND amount_in_currency > 0").
+		Updates(map[string]interface{}{
+			"exchange_rate":        gorm.Expr("amount_in_currency / (amount / 1e12)"),
+			"exchange_rate_source": models.ExchangeRateSourcePos,
+		}).Error
+}
+
+// backfillLedger posts the payments and payouts recorded before the ledger existed.
+// Only rows without a posting are loaded, so this is cheap once the ledger is complete.
+func backfillLedger(db *gorm.DB) error {
+	var transactions []*models.Transaction
+	if err := db.Where("confirmed = ? AND NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.transaction_id = transactions.id AND ledger_entries.kind = ?)",
+		true, models.LedgerKindPayment).
+		Find(&transactions).Error; err != nil {
+		return err
+	}
+
+	var transfers []*models.Transfer
+	if err := db.Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.transfer_id = transfers.id AND ledger_entries.kind = ?)",
+		models.LedgerKindPayout).
+		Find(&transfers).Error; err != nil {
+		return err
+	}
+
+	entries := ledgerBackfill(transactions, transfers)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(entries); start += 500 {
+			end := min(start+500, len(entries))
+			if err := ledger.Create(tx, entries[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ledgerBackfill builds the postings for confirmed payments and payouts without one. Held payments
+// stay confirmed while they wait for an admin or another confirmation, they are credited on resolution.
+func ledgerBackfill(transactions []*models.Transaction, transfers []*models.Transfer) []*models.LedgerEntry {
+	var entries []*models.LedgerEntry
+	for _, transaction := range transactions {
+		if slices.Contains(models.HeldTransactionStatuses, transaction.Status) {
+			continue
+		}
+		entries = append(entries, ledger.PaymentReceived(transaction)...)
+	}
+	for _, transfer := range transfers {
+		entries = append(entries, ledger.PayoutCreated(transfer)...)
+		if transfer.Completed && transfer.AmountTransferred != nil {
+			entries = append(entries, ledger.PayoutFee(transfer, *transfer.AmountTransferred)...)
+		}
+	}
+	return entries
+}
+
 func quoteIdentifier(name string) string {
 	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 }
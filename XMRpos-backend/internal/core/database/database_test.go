@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// The backfill runs on every start, a payment still waiting for an admin or another
+// confirmation must not be credited by it
+func TestLedgerBackfillSkipsHeldPayments(t *testing.T) {
+	const xmr = int64(1_000_000_000_000)
+	transactions := []*models.Transaction{
+		{VendorID: 1, Amount: xmr, AmountReceived: xmr, Status: models.TransactionStatusPaid, Confirmed: true},
+		{VendorID: 1, Amount: xmr, AmountReceived: xmr + xmr/10, AmountOverpaid: xmr / 10, Status: models.TransactionStatusOverpaid, Confirmed: true},
+		{VendorID: 1, Amount: 2 * xmr, AmountReceived: 2 * xmr, Status: models.TransactionStatusAtRisk, Confirmed: true},
+		{VendorID: 1, Amount: 3 * xmr, AmountReceived: 3 * xmr, Status: models.TransactionStatusReversed, Confirmed: true},
+		{VendorID: 1, Amount: 4 * xmr, AmountReceived: 4 * xmr, Status: models.TransactionStatusLatePayment, Confirmed: true},
+		{VendorID: 1, Amount: 5 * xmr, AmountReceived: xmr, Status: models.TransactionStatusRefunded, Confirmed: true},
+		{VendorID: 2, Amount: xmr, AmountReceived: xmr, Status: models.TransactionStatusPaid, Confirmed: true},
+	}
+	for i, transaction := range transactions {
+		transaction.ID = uint(i + 1)
+	}
+	transferred := xmr/2 - 1_000
+	transfers := []*models.Transfer{
+		{Model: gorm.Model{ID: 1}, VendorID: 1, Amount: xmr / 2, Completed: true, AmountTransferred: &transferred},
+	}
+
+	balances := make(map[uint]int64)
+	var fees int64
+	for _, entry := range ledgerBackfill(transactions, transfers) {
+		switch entry.Account {
+		case models.LedgerAccountVendor:
+			balances[entry.VendorID] += entry.Amount
+		case models.LedgerAccountFees:
+			fees += entry.Amount
+		}
+	}
+
+	if want := 2*xmr - xmr/2; balances[1] != want {
+		t.Fatalf("vendor 1 balance: got %d, want %d", balances[1], want)
+	}
+	if balances[2] != xmr {
+		t.Fatalf("vendor 2 balance: got %d, want %d", balances[2], xmr)
+	}
+	if fees != 1_000 {
+		t.Fatalf("payout fee: got %d, want 1000", fees)
+	}
+}
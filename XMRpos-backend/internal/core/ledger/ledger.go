@@ -0,0 +1,112 @@
+// Package ledger builds the balanced postings recorded in the ledger_entries table.
+// A vendor's balance is the sum of its entries on the vendor account.
+package ledger
+
+import (
+	"fmt"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentReceived credits the vendor with a confirmed payment. The vendor gets what was received,
+// except for an overpayment which is kept apart to be refunded to the customer.
+func PaymentReceived(transaction *models.Transaction) []*models.LedgerEntry {
+	id := transaction.ID
+	amount := transaction.Amount
+	// transactions confirmed before the received amount was tracked have no AmountReceived
+	if transaction.AmountReceived > 0 {
+		amount = transaction.AmountReceived - transaction.AmountOverpaid
+	}
+	return posting(fmt.Sprintf("payment:%d", id), transaction.VendorID, models.LedgerKindPayment,
+		models.LedgerAccountVendor, models.LedgerAccountIncoming, amount,
+		func(e *models.LedgerEntry) { e.TransactionID = &id })
+}
+
+// PaymentReversed takes back the credit PaymentReceived booked for the transaction. It has to be built
+// from the transaction as it was credited, before the received amounts are updated.
+func PaymentReversed(transaction *models.Transaction) []*models.LedgerEntry {
+	entries := PaymentReceived(transaction)
+	id := transaction.ID
+	return posting(fmt.Sprintf("reversal:%d", id), transaction.VendorID, models.LedgerKindReversal,
+		models.LedgerAccountIncoming, models.LedgerAccountVendor, entries[0].Amount,
+		func(e *models.LedgerEntry) { e.TransactionID = &id })
+}
+
+// PayoutCreated debits the vendor with the full amount of a payout as soon as it is requested
+func PayoutCreated(transfer *models.Transfer) []*models.LedgerEntry {
+	id := transfer.ID
+	return posting(fmt.Sprintf("payout:%d", id), transfer.VendorID, models.LedgerKindPayout,
+		models.LedgerAccountPayout, models.LedgerAccountVendor, transfer.Amount,
+		func(e *models.LedgerEntry) { e.TransferID = &id })
+}
+
+// PayoutFee moves the network fee that was subtracted from a completed payout to the fees account.
+// It returns nil when the whole amount reached the vendor.
+func PayoutFee(transfer *models.Transfer, amountTransferred int64) []*models.LedgerEntry {
+	fee := transfer.Amount - amountTransferred
+	if fee <= 0 {
+		return nil
+	}
+	id := transfer.ID
+	return posting(fmt.Sprintf("fee:%d", id), transfer.VendorID, models.LedgerKindFee,
+		models.LedgerAccountFees, models.LedgerAccountPayout, fee,
+		func(e *models.LedgerEntry) { e.TransferID = &id })
+}
+
+// RefundIssued debits the vendor with the part of a refund that was credited to them.
+// It returns nil when the refund only returns an overpayment that never reached the vendor balance.
+func RefundIssued(refund *models.Refund, vendorAmount int64) []*models.LedgerEntry {
+	if vendorAmount <= 0 {
+		return nil
+	}
+	transactionID := refund.TransactionID
+	return posting(fmt.Sprintf("refund:%d", refund.ID), refund.VendorID, models.LedgerKindRefund,
+		models.LedgerAccountRefunds, models.LedgerAccountVendor, vendorAmount,
+		func(e *models.LedgerEntry) { e.TransactionID = &transactionID })
+}
+
+// RefundFailed credits back the debit RefundIssued booked for a refund that was never sent
+func RefundFailed(refund *models.Refund, vendorAmount int64) []*models.LedgerEntry {
+	if vendorAmount <= 0 {
+		return nil
+	}
+	transactionID := refund.TransactionID
+	return posting(fmt.Sprintf("refund-failed:%d", refund.ID), refund.VendorID, models.LedgerKindRefund,
+		models.LedgerAccountVendor, models.LedgerAccountRefunds, vendorAmount,
+		func(e *models.LedgerEntry) { e.TransactionID = &transactionID })
+}
+
+// Adjustment books a manual correction, a positive amount credits the vendor
+func Adjustment(vendorID uint, amount int64, description string) ([]*models.LedgerEntry, error) {
+	ref, err := gonanoid.New()
+	if err != nil {
+		return nil, err
+	}
+	return posting("adjustment:"+ref, vendorID, models.LedgerKindAdjustment,
+		models.LedgerAccountVendor, models.LedgerAccountAdjustments, amount,
+		func(e *models.LedgerEntry) { e.Description = &description }), nil
+}
+
+// Create inserts the entries in a single statement. Postings that were already recorded are skipped,
+// so retries and the startup backfill never book the same payment or payout twice.
+func Create(db *gorm.DB, entries []*models.LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(entries).Error
+}
+
+// posting adds amount to the credited account and takes it from the debited one
+func posting(reference string, vendorID uint, kind string, credited string, debited string, amount int64, apply func(*models.LedgerEntry)) []*models.LedgerEntry {
+	entries := []*models.LedgerEntry{
+		{Reference: reference, Account: credited, VendorID: vendorID, Kind: kind, Amount: amount},
+		{Reference: reference, Account: debited, VendorID: vendorID, Kind: kind, Amount: -amount},
+	}
+	for _, entry := range entries {
+		apply(entry)
+	}
+	return entries
+}
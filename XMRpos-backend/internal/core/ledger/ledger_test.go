@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+func balances(t *testing.T, entries []*models.LedgerEntry) map[string]int64 {
+	t.Helper()
+	accounts := map[string]int64{}
+	var sum int64
+	for _, entry := range entries {
+		if entry.Reference != entries[0].Reference {
+			t.Fatalf("posting mixes references %q and %q", entries[0].Reference, entry.Reference)
+		}
+		accounts[entry.Account] += entry.Amount
+		sum += entry.Amount
+	}
+	if sum != 0 {
+		t.Fatalf("posting %s does not balance: %d", entries[0].Reference, sum)
+	}
+	return accounts
+}
+
+func TestPaymentReceivedLeavesOutOverpayment(t *testing.T) {
+	transaction := &models.Transaction{VendorID: 3, Amount: 1000, AmountReceived: 1300, AmountOverpaid: 300}
+	transaction.ID = 7
+	entries := PaymentReceived(transaction)
+	if got := balances(t, entries)[models.LedgerAccountVendor]; got != 1000 {
+		t.Fatalf("vendor credit: got %d, want 1000", got)
+	}
+	if entries[0].Reference != "payment:7" || *entries[0].TransactionID != 7 || entries[0].VendorID != 3 {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+
+	// Payments confirmed before the received amount was tracked are credited with the requested amount
+	legacy := &models.Transaction{Amount: 500}
+	legacy.ID = 8
+	if got := balances(t, PaymentReceived(legacy))[models.LedgerAccountVendor]; got != 500 {
+		t.Fatalf("legacy vendor credit: got %d, want 500", got)
+	}
+}
+
+func TestPaymentReversedUndoesCredit(t *testing.T) {
+	transaction := &models.Transaction{Amount: 1000, AmountReceived: 1300, AmountOverpaid: 300}
+	transaction.ID = 7
+	accounts := balances(t, PaymentReceived(transaction))
+	for account, amount := range balances(t, PaymentReversed(transaction)) {
+		accounts[account] += amount
+	}
+	for account, amount := range accounts {
+		if amount != 0 {
+			t.Fatalf("account %s left at %d after the reversal", account, amount)
+		}
+	}
+}
+
+func TestPayoutFee(t *testing.T) {
+	transfer := &models.Transfer{VendorID: 3, Amount: 1000}
+	transfer.ID = 4
+	if entries := PayoutFee(transfer, 1000); entries != nil {
+		t.Fatalf("fee booked for a payout without fee: %+v", entries)
+	}
+	accounts := balances(t, PayoutFee(transfer, 990))
+	if accounts[models.LedgerAccountFees] != 10 || accounts[models.LedgerAccountPayout] != -10 {
+		t.Fatalf("unexpected fee posting: %+v", accounts)
+	}
+}
+
+func TestRefundPostings(t *testing.T) {
+	refund := &models.Refund{TransactionID: 7, VendorID: 3}
+	refund.ID = 2
+	if entries := RefundIssued(refund, 0); entries != nil {
+		t.Fatalf("refund of an overpayment debited the vendor: %+v", entries)
+	}
+	issued := balances(t, RefundIssued(refund, 400))
+	if issued[models.LedgerAccountVendor] != -400 {
+		t.Fatalf("unexpected refund posting: %+v", issued)
+	}
+	failed := balances(t, RefundFailed(refund, 400))
+	if failed[models.LedgerAccountVendor] != 400 || failed[models.LedgerAccountRefunds] != -400 {
+		t.Fatalf("unexpected failed refund posting: %+v", failed)
+	}
+}
+
+func TestAdjustmentReferencesAreUnique(t *testing.T) {
+	first, err := Adjustment(3, -50, "correction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Adjustment(3, -50, "correction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first[0].Reference == second[0].Reference {
+		t.Fatalf("two adjustments share the reference %q", first[0].Reference)
+	}
+	if got := balances(t, first)[models.LedgerAccountVendor]; got != -50 || *first[0].Description != "correction" {
+		t.Fatalf("unexpected adjustment: %+v", first[0])
+	}
+}
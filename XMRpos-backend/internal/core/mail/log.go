@@ -0,0 +1,25 @@
+package mail
+
+import (
+	"context"
+	"log"
+)
+
+// LogSender only logs who a message would have gone to, for development without a mail server
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Name() string {
+	return "log"
+}
+
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	if err := ValidateAddress(msg.To); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s: %s (%d attachments)", msg.To, msg.Subject, len(msg.Attachments))
+	return nil
+}
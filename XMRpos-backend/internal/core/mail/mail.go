@@ -0,0 +1,169 @@
+// Package mail sends the emails of the backend: digital receipts to customers and
+// notifications to vendors. Messages are rendered from the templates in templates/.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Sender delivers messages. Senders are selected via config so deployments without a
+// mail server can run without one, and development setups can log messages instead.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is one email with a plain text and an HTML body
+type Message struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ValidateAddress accepts a bare address like customer@example.com, without display name
+func ValidateAddress(address string) error {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil || parsed.Address != address || len(address) > 254 {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// build encodes the message as multipart/alternative, inside multipart/mixed when it has attachments
+func build(from *netmail.Address, msg *Message, now time.Time) ([]byte, error) {
+	if err := ValidateAddress(msg.To); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	var contentType string
+	if len(msg.Attachments) == 0 {
+		boundary, err := writeAlternative(&body, msg)
+		if err != nil {
+			return nil, err
+		}
+		contentType = "multipart/alternative; boundary=" + boundary
+	} else {
+		mixed := multipart.NewWriter(&body)
+		var alternative bytes.Buffer
+		boundary, err := writeAlternative(&alternative, msg)
+		if err != nil {
+			return nil, err
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + boundary}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(alternative.Bytes()); err != nil {
+			return nil, err
+		}
+		for _, attachment := range msg.Attachments {
+			if err := writeAttachment(mixed, attachment); err != nil {
+				return nil, err
+			}
+		}
+		if err := mixed.Close(); err != nil {
+			return nil, err
+		}
+		contentType = "multipart/mixed; boundary=" + mixed.Boundary()
+	}
+
+	var out bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&out, "%s: %s\r\n", name, value)
+	}
+	header("From", from.String())
+	header("To", msg.To)
+	// Q-encoding also encodes control characters, a subject cannot inject headers
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+	header("Content-Type", contentType)
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// writeAlternative writes the text and HTML parts and returns their boundary
+func writeAlternative(w *bytes.Buffer, msg *Message) (string, error) {
+	alternative := multipart.NewWriter(w)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		writer, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return "", err
+		}
+		if err := encoder.Close(); err != nil {
+			return "", err
+		}
+	}
+	return alternative.Boundary(), alternative.Close()
+}
+
+// writeAttachment writes the file base64 encoded in lines of 76 characters
+func writeAttachment(w *multipart.Writer, attachment Attachment) error {
+	filename := mime.QEncoding.Encode("utf-8", attachment.Filename)
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(attachment.ContentType, map[string]string{"name": filename})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := fmt.Fprintf(part, "%s\r\n", encoded[:n]); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndexByte(from, '@'); at >= 0 {
+		domain = from[at+1:]
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
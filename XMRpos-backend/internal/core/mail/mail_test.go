@@ -0,0 +1,180 @@
+package mail_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/mail"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/receipt"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func paidReceipt(vendorName string) *receipt.Receipt {
+	return receipt.New(&models.Transaction{
+		Model:            gorm.Model{ID: 7, CreatedAt: time.Date(2024, 3, 1, 14, 3, 22, 0, time.UTC)},
+		Amount:           100_000_000_000,
+		Currency:         "EUR",
+		AmountInCurrency: 17,
+		LineItems:        []*models.LineItem{{Name: "Café crème", Quantity: 2, UnitPrice: 8.5, Total: 17}},
+		SubTransactions:  []*models.SubTransaction{{TxHash: strings.Repeat("ab", 32)}},
+	}, vendorName)
+}
+
+func TestSMTPSender(t *testing.T) {
+	sink := testutil.NewFakeSMTP(t)
+	sender := mail.NewSMTPSender(sink.Host, sink.Port, "", "", "Bäckerei <shop@example.com>")
+
+	msg, err := mail.ReceiptMessage("customer@example.com", paidReceipt("Bäckerei <Süd>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	got := messages[0]
+	if got.From != "shop@example.com" || len(got.To) != 1 || got.To[0] != "customer@example.com" {
+		t.Fatalf("unexpected envelope %q -> %v", got.From, got.To)
+	}
+	if subject := got.Header("Subject"); subject != "Your receipt from Bäckerei <Süd>" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if from := got.Header("From"); from != "Bäckerei <shop@example.com>" {
+		t.Fatalf("unexpected from %q", from)
+	}
+	text := string(got.Part("text/plain"))
+	for _, want := range []string{"Bäckerei <Süd>", "2 x Café crème", "TOTAL EUR", "17.00"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("text body misses %q:\n%s", want, text)
+		}
+	}
+	if html := string(got.Part("text/html")); !strings.Contains(html, "Bäckerei &lt;Süd&gt;") || strings.Contains(html, "<Süd>") {
+		t.Fatalf("vendor name is not escaped in the HTML body:\n%s", html)
+	}
+	if pdf := got.Part("application/pdf"); !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) {
+		t.Fatal("receipt PDF is not attached")
+	}
+}
+
+func TestSMTPSenderRejected(t *testing.T) {
+	sink := testutil.NewFakeSMTP(t)
+	sink.SetReject(true)
+	sender := mail.NewSMTPSender(sink.Host, sink.Port, "", "", "shop@example.com")
+
+	msg := &mail.Message{To: "customer@example.com", Subject: "Hi", Text: "Hello"}
+	if err := sender.Send(context.Background(), msg); err == nil {
+		t.Fatal("expected the rejected recipient to fail")
+	}
+	if len(sink.Messages()) != 0 {
+		t.Fatal("message delivered despite the rejection")
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	for _, address := range []string{"customer@example.com", "first.last+tag@shop.example.org"} {
+		if err := mail.ValidateAddress(address); err != nil {
+			t.Fatalf("%q rejected: %v", address, err)
+		}
+	}
+	for _, address := range []string{"", "customer", "Customer <customer@example.com>", "customer@example.com\r\nBcc: x@example.com", "a@example.com, b@example.com"} {
+		if err := mail.ValidateAddress(address); err == nil {
+			t.Fatalf("%q accepted", address)
+		}
+	}
+}
+
+func TestPayoutMessage(t *testing.T) {
+	msg, err := mail.PayoutMessage("vendor@example.com", mail.Payout{
+		VendorName:        "Shop",
+		TransferID:        3,
+		Amount:            500_000_000_000,
+		AmountTransferred: 499_970_000_000,
+		Address:           "8Address",
+		TxHash:            "feed",
+		Transactions:      2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Payout of 0.49997 XMR sent" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	for _, want := range []string{"payout #3", "0.5 XMR", "0.00003 XMR", "8Address", "feed"} {
+		if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+			t.Fatalf("message misses %q:\n%s\n%s", want, msg.Text, msg.HTML)
+		}
+	}
+
+	// A split payout lists its outputs instead of the single address
+	msg, err = mail.PayoutMessage("vendor@example.com", mail.Payout{
+		TransferID:        4,
+		Amount:            500_000_000_000,
+		AmountTransferred: 499_940_000_000,
+		Address:           "8First",
+		TxHash:            "beef",
+		Outputs: []mail.PayoutOutput{
+			{Label: "Supplier", Address: "8First", AmountTransferred: 99_970_000_000},
+			{Address: "8Second", AmountTransferred: 399_970_000_000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Supplier", "8First", "0.09997 XMR", "8Second", "0.39997 XMR"} {
+		if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+			t.Fatalf("split payout message misses %q:\n%s\n%s", want, msg.Text, msg.HTML)
+		}
+	}
+}
+
+func TestPayoutAddressChangeMessage(t *testing.T) {
+	msg, err := mail.PayoutAddressChangeMessage("vendor@example.com", mail.PayoutAddressChange{
+		VendorName: "Shop",
+		Status:     models.PayoutAddressChangeUnconfirmed,
+		OldAddress: "8Old",
+		NewAddress: "8New",
+		ConfirmURL: "https://xmrpos.test/confirm-payout-address.html?token=abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Confirm your payout address change" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	for _, want := range []string{"8Old", "8New", "https://xmrpos.test/confirm-payout-address.html?token=abc", "change your password"} {
+		if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+			t.Fatalf("message misses %q:\n%s\n%s", want, msg.Text, msg.HTML)
+		}
+	}
+
+	// A new split lists its shares, applied changes need no warning
+	msg, err = mail.PayoutAddressChangeMessage("vendor@example.com", mail.PayoutAddressChange{
+		VendorName:   "Shop",
+		Status:       models.PayoutAddressChangeApplied,
+		Destinations: []mail.PayoutShare{{Label: "Supplier", Address: "8First", Share: "0.2 XMR"}, {Address: "8Second", Share: "100%"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Payout address changed" || strings.Contains(msg.Text, "change your password") {
+		t.Fatalf("unexpected applied message %q:\n%s", msg.Subject, msg.Text)
+	}
+	for _, want := range []string{"Supplier", "8First", "0.2 XMR", "8Second", "100%"} {
+		if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+			t.Fatalf("split message misses %q:\n%s\n%s", want, msg.Text, msg.HTML)
+		}
+	}
+
+	if _, err := mail.PayoutAddressChangeMessage("vendor@example.com", mail.PayoutAddressChange{Status: "unknown"}); err == nil {
+		t.Fatal("expected an unknown status to fail")
+	}
+}
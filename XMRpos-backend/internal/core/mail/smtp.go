@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// DefaultSMTPPort is the submission port, upgraded to TLS with STARTTLS
+const DefaultSMTPPort = "587"
+
+// Port 465 speaks TLS from the first byte instead of upgrading with STARTTLS
+const implicitTLSPort = "465"
+
+const smtpDialTimeout = 10 * time.Second
+
+// SMTPSender submits messages to a mail server. STARTTLS is used whenever the server
+// offers it, credentials are only sent over TLS.
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     *netmail.Address
+}
+
+// NewSMTPSender expects from to be validated, like MAIL_FROM is by the config
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	if port == "" {
+		port = DefaultSMTPPort
+	}
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		sender = &netmail.Address{Address: from}
+	}
+	return &SMTPSender{host: host, port: port, username: username, password: password, from: sender}
+}
+
+func (s *SMTPSender) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	data, err := build(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(s.host, s.port)
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+	var conn net.Conn
+	if s.port == implicitTLSPort {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.port != implicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+				return fmt.Errorf("starttls: %w", err)
+			}
+		}
+	}
+	// PlainAuth refuses to send credentials over an unencrypted connection to anything but localhost
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
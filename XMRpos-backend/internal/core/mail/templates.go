@@ -0,0 +1,151 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/address"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/receipt"
+)
+
+// Every message has a NAME.txt and a NAME.html template
+//
+//go:embed templates
+var templateFS embed.FS
+
+var templateFuncs = map[string]any{
+	"xmr":  address.FormatXMR,
+	"date": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+}
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.html"))
+)
+
+// render executes both bodies of a message, HTML escaping only applies to the HTML one
+func render(name string, data any) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// ReceiptMessage is the digital receipt for a customer, the same layout as the printed one with the PDF attached
+func ReceiptMessage(to string, r *receipt.Receipt) (*Message, error) {
+	layout, _, err := receipt.Render(r, receipt.FormatText)
+	if err != nil {
+		return nil, err
+	}
+	pdf, contentType, err := receipt.Render(r, receipt.FormatPDF)
+	if err != nil {
+		return nil, err
+	}
+
+	text, html, err := render("receipt", struct {
+		VendorName string
+		Receipt    string
+	}{r.VendorName, string(layout)})
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: "Your receipt from " + r.VendorName,
+		Text:    text,
+		HTML:    html,
+		Attachments: []Attachment{{
+			Filename:    fmt.Sprintf("receipt-%d.pdf", r.TransactionID),
+			ContentType: contentType,
+			Data:        pdf,
+		}},
+	}, nil
+}
+
+// Payout is a completed transfer to a vendor, amounts in atomic units
+type Payout struct {
+	VendorName        string
+	TransferID        uint
+	Amount            int64
+	AmountTransferred int64 // Amount minus the network fee
+	Address           string
+	TxHash            string
+	Transactions      int
+	Outputs           []PayoutOutput // Set when the payout was split across several addresses
+}
+
+// PayoutOutput is the part of a split payout sent to one address
+type PayoutOutput struct {
+	Label             string
+	Address           string
+	AmountTransferred int64
+}
+
+func (p Payout) Fee() int64 {
+	return p.Amount - p.AmountTransferred
+}
+
+// PayoutMessage notifies a vendor that a payout was sent
+func PayoutMessage(to string, p Payout) (*Message, error) {
+	text, html, err := render("payout", p)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Payout of %s XMR sent", address.FormatXMR(p.AmountTransferred)),
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
+// PayoutAddressChange is a change of where a vendor's payouts go, Status is the one it just moved to
+type PayoutAddressChange struct {
+	VendorName   string
+	Status       string
+	OldAddress   string        // Address changes only
+	NewAddress   string        // Address changes only, empty for a new payout split
+	Destinations []PayoutShare // New payout split, empty when it goes back to the vendor's address
+	EffectiveAt  time.Time     // End of the cooling-off
+	ConfirmURL   string        // Set while the change waits for confirmation
+}
+
+// PayoutShare is one destination of a payout split, Share is e.g. "70%" or "0.2 XMR"
+type PayoutShare struct {
+	Label   string
+	Address string
+	Share   string
+}
+
+// PayoutAddressChangeMessage notifies a vendor of every step of a payout address change. A change
+// waiting for confirmation gets the confirmation link instead.
+func PayoutAddressChangeMessage(to string, c PayoutAddressChange) (*Message, error) {
+	var subject string
+	switch c.Status {
+	case models.PayoutAddressChangeUnconfirmed:
+		subject = "Confirm your payout address change"
+	case models.PayoutAddressChangePending:
+		subject = "Payout address change requested"
+	case models.PayoutAddressChangeApplied:
+		subject = "Payout address changed"
+	case models.PayoutAddressChangeCancelled:
+		subject = "Payout address change cancelled"
+	default:
+		return nil, fmt.Errorf("unknown payout address change status %q", c.Status)
+	}
+
+	text, html, err := render("payout_address_change", c)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{To: to, Subject: subject, Text: text, HTML: html}, nil
+}
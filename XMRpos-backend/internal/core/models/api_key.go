@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes a vendor can grant an API key
+const (
+	APIKeyScopeTransactionsRead = "transactions:read" // List and export transactions, read invoices and the balance
+	APIKeyScopeInvoicesWrite    = "invoices:write"    // Create invoices
+	APIKeyScopePayoutsWrite     = "payouts:write"     // Read the balance and initiate payouts
+)
+
+// APIKey lets a vendor's server call the API without logging in. Only the SHA-256 hash of
+// the key is stored, revoking a key deletes it.
+type APIKey struct {
+	gorm.Model
+	VendorID   uint       `gorm:"not null;index"` // Foreign key field
+	Name       string     `gorm:"not null;size:64"`
+	Prefix     string     `gorm:"not null;size:16"` // Start of the key, shown so vendors can tell their keys apart
+	KeyHash    string     `gorm:"not null;size:64;uniqueIndex"`
+	Scopes     string     `gorm:"not null;type:text"` // Comma separated
+	LastUsedAt *time.Time // Updated at most once a minute
+}
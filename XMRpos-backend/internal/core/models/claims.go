@@ -12,6 +12,7 @@ const (
 	ClaimsPasswordVersionKey ClaimsContextKey = "ClaimsPasswordVersion"
 	ClaimsPosIDKey           ClaimsContextKey = "ClaimsPosID"
 	ClaimsExpKey             ClaimsContextKey = "ClaimsExp"
+	ClaimsScopesKey          ClaimsContextKey = "ClaimsScopes"
 )
 
 // Claims represents the custom claims for the JWT token
@@ -20,5 +21,7 @@ type Claims struct {
 	Role            string `json:"role"`
 	PasswordVersion uint32 `json:"password_version"`
 	PosID           *uint  `json:"pos_id"`
+	// Scopes are only set when the request was authenticated with an API key, never from a token
+	Scopes []string `json:"-"`
 	jwt.RegisteredClaims
 }
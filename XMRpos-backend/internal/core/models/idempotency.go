@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey remembers the response to a mutating request sent with an Idempotency-Key
+// header, so a retry gets the original result instead of doing the work twice
+type IdempotencyKey struct {
+	gorm.Model
+	Scope        string    `gorm:"not null;size:64;uniqueIndex:idx_idempotency_scope_key,priority:1"` // Who sent it, e.g. "pos:1:2", keys of different callers never collide
+	Key          string    `gorm:"not null;size:255;uniqueIndex:idx_idempotency_scope_key,priority:2"`
+	RequestHash  string    `gorm:"not null;size:64"` // SHA-256 of method, path and body, a reused key must come with the same request
+	Completed    bool      `gorm:"not null;default:false"`
+	StatusCode   int       `gorm:"not null;default:0"`
+	ContentType  string    `gorm:"not null;size:128;default:''"`
+	ResponseBody []byte    `gorm:"type:bytea"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
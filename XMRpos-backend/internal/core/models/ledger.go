@@ -0,0 +1,39 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Ledger accounts. The vendor account holds what is owed to the vendor, the others are its counterparts.
+const (
+	LedgerAccountVendor      = "vendor"
+	LedgerAccountIncoming    = "incoming"
+	LedgerAccountPayout      = "payout"
+	LedgerAccountFees        = "fees"
+	LedgerAccountAdjustments = "adjustments"
+	LedgerAccountRefunds     = "refunds"
+)
+
+// Ledger entry kinds, one per kind of posting
+const (
+	LedgerKindPayment    = "payment"
+	LedgerKindPayout     = "payout"
+	LedgerKindFee        = "fee"
+	LedgerKindAdjustment = "adjustment"
+	LedgerKindRefund     = "refund"
+	LedgerKindReversal   = "reversal"
+)
+
+// LedgerEntry is one leg of a double-entry posting. The legs of a posting share a Reference
+// and sum to zero. Entries are append-only, corrections are booked as new postings.
+type LedgerEntry struct {
+	gorm.Model
+	Reference     string  `gorm:"not null;size:64;uniqueIndex:idx_ledger_reference_account,priority:1"` // e.g. "payment:42", makes postings idempotent
+	Account       string  `gorm:"not null;size:32;uniqueIndex:idx_ledger_reference_account,priority:2;index:idx_ledger_vendor_account,priority:2"`
+	VendorID      uint    `gorm:"not null;index:idx_ledger_vendor_account,priority:1"`
+	Kind          string  `gorm:"not null;size:32"`
+	Amount        int64   `gorm:"not null"` // Signed atomic units, positive increases the account
+	TransactionID *uint   `gorm:"index"`
+	TransferID    *uint   `gorm:"index"`
+	Description   *string `gorm:"type:text"`
+}
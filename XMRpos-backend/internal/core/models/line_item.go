@@ -0,0 +1,20 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// LineItem is one position of an itemized cart. Prices are in the currency of the transaction,
+// Tax and Total are computed by the server when the transaction is issued.
+type LineItem struct {
+	gorm.Model
+	TransactionID uint    `gorm:"not null;index"` // Foreign key field
+	SKU           *string `gorm:"size:64;index"`
+	Name          string  `gorm:"not null;size:128"`
+	Quantity      float64 `gorm:"not null"`
+	UnitPrice     float64 `gorm:"not null"`
+	TaxRate       float64 `gorm:"not null;default:0"` // Percent
+	Discount      float64 `gorm:"not null;default:0"` // Taken off the line before tax
+	Tax           float64 `gorm:"not null;default:0"` // Tax contained in Total
+	Total         float64 `gorm:"not null"`           // What the line adds to the transaction
+}
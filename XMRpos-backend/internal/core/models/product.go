@@ -0,0 +1,30 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProductCategory groups the products of a vendor's catalog on the POS
+type ProductCategory struct {
+	gorm.Model
+	VendorID uint   `gorm:"not null;index;uniqueIndex:idx_product_category_vendor_name,priority:1,where:deleted_at IS NULL"`
+	Name     string `gorm:"not null;size:64;uniqueIndex:idx_product_category_vendor_name,priority:2,where:deleted_at IS NULL"`
+	Position int    `gorm:"not null;default:0"` // Sort order on the POS, lowest first
+	Revision int64  `gorm:"not null;default:0"` // Catalog revision of the last change, see Vendor.CatalogRevision
+}
+
+// Product is an entry of a vendor's catalog. Deleted products stay soft deleted so POS devices
+// learn about the deletion on their next sync.
+type Product struct {
+	gorm.Model
+	VendorID   uint    `gorm:"not null;index;uniqueIndex:idx_product_vendor_sku,priority:1,where:deleted_at IS NULL"`
+	CategoryID *uint   `gorm:"index"`
+	SKU        *string `gorm:"size:64;uniqueIndex:idx_product_vendor_sku,priority:2,where:deleted_at IS NULL"`
+	Name       string  `gorm:"not null;size:128"`
+	Price      float64 `gorm:"not null"`
+	Currency   string  `gorm:"not null;size:8"`             // Fiat code or XMR
+	TaxClass   string  `gorm:"not null;size:32;default:''"` // Label such as "standard" or "reduced"
+	TaxRate    float64 `gorm:"not null;default:0"`          // Percent, goes into the line items of a sale
+	Active     bool    `gorm:"not null"`                    // Inactive products stay in the catalog but are hidden on the POS
+	Revision   int64   `gorm:"not null;default:0"`          // Catalog revision of the last change, see Vendor.CatalogRevision
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Refund states. A refund is recorded as pending before it is sent, so a refund whose
+// transfer went out is never lost, and it is only failed when nothing left the wallet.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusCompleted = "completed"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund returns funds of a confirmed transaction to the customer
+type Refund struct {
+	gorm.Model
+	TransactionID      uint    `gorm:"not null;index"` // Foreign key field
+	VendorID           uint    `gorm:"not null;index"`
+	Amount             int64   `gorm:"not null"`           // Amount sent back, the network fee is subtracted from it
+	AmountTransferred  *int64  `gorm:"default:null"`       // Amount that reached the customer (amount - fee)
+	Address            string  `gorm:"not null;type:text"` // Customer return address
+	Reason             *string `gorm:"type:text"`
+	TxHash             *string `gorm:"type:text"`
+	WalletAccountIndex uint32  `gorm:"not null;default:0"` // Wallet account the refund is spent from
+	Status             string  `gorm:"not null;size:20;default:completed;index"`
+	VendorAmount       int64   `gorm:"not null;default:0"` // Part of the amount debited from the vendor balance
+	SettledStatus      *string `gorm:"size:20"`            // Status of an uncredited transaction before the refund settled it
+}
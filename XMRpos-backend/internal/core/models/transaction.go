@@ -6,22 +6,66 @@ import (
 	"gorm.io/gorm"
 )
 
+// Payment states of a transaction
+const (
+	TransactionStatusPending       = "pending"        // Nothing received yet
+	TransactionStatusPartiallyPaid = "partially_paid" // Less than the amount received, the customer can still top up
+	TransactionStatusUnderpaid     = "underpaid"      // The payment window closed before the full amount arrived
+	TransactionStatusPaid          = "paid"           // The amount was received within the tolerance
+	TransactionStatusOverpaid      = "overpaid"       // More than the amount was received, the surplus is due back to the customer
+	TransactionStatusExpired       = "expired"        // The quote window closed before anything was received
+	TransactionStatusLatePayment   = "late_payment"   // Funds arrived after the invoice expired, an admin has to resolve it
+	TransactionStatusAtRisk        = "at_risk"        // A payment was flagged as a double spend or its block was reorganized, it is held until it confirms again
+	TransactionStatusReversed      = "reversed"       // A payment disappeared from the chain, a credit it earned was taken back
+	TransactionStatusRefunded      = "refunded"       // Funds that were never credited were sent back in full, the invoice is closed
+)
+
+// HeldTransactionStatuses are the states in which a confirmed payment is neither credited
+// to the vendor nor paid out, until an admin resolves it or it confirms again
+var HeldTransactionStatuses = []string{TransactionStatusLatePayment, TransactionStatusAtRisk, TransactionStatusReversed, TransactionStatusRefunded}
+
+// ExchangeRateSourcePos marks rates implied by the amounts in the POS request rather than fetched by the server
+const ExchangeRateSourcePos = "pos"
+
 type Transaction struct {
 	gorm.Model
 	VendorID              uint              `gorm:"not null;index"` // Foreign key field
 	Vendor                Vendor            `gorm:"foreignKey:VendorID"`
-	PosID                 uint              `gorm:"not null;index"` // Foreign key field
-	Pos                   Pos               `gorm:"foreignKey:PosID"`
+	PosID                 *uint             `gorm:"index"` // Foreign key field, nil for invoices created through the invoice API
+	Pos                   *Pos              `gorm:"foreignKey:PosID"`
 	Amount                int64             `gorm:"not null"`
 	RequiredConfirmations int64             `gorm:"not null"`
+	PayableConfirmations  int64             `gorm:"not null;default:10"` // Confirmations before the payment counts as confirmed, from the vendor's policy
 	Currency              string            `gorm:"not null"`
 	AmountInCurrency      float64           `gorm:"not null"`
+	TipAmount             int64             `gorm:"not null;default:0"` // Part of Amount the customer added as a tip
+	TipInCurrency         float64           `gorm:"not null;default:0"` // Part of AmountInCurrency the customer added as a tip
 	Description           *string           `gorm:"type:text"`
+	LineItems             []*LineItem       `gorm:"foreignKey:TransactionID"`
+	TaxIncluded           bool              `gorm:"not null;default:false"` // The unit prices of the line items already contain their tax
 	SubAddress            *string           `gorm:"type:text"`
+	PaymentID             *string           `gorm:"size:16;uniqueIndex"` // Set for integrated addresses handed out for a light-wallet server
+	PaymentURI            *string           `gorm:"type:text"`           // monero: URI with the amount, recipient and description, shown as QR code
+	OrderID               *string           `gorm:"size:128;index"`      // The shop's order reference of an invoice
+	RedirectURL           *string           `gorm:"type:text"`           // Where the checkout page sends the customer once the invoice is accepted
+	CheckoutToken         *string           `gorm:"size:64;uniqueIndex"` // Secret in the public checkout page URL of an invoice
 	Accepted              bool              `gorm:"not null;default:false"`
 	Confirmed             bool              `gorm:"not null;default:false"`
 	Transferred           bool              `gorm:"not null;default:false"`
+	Status                string            `gorm:"not null;size:32;default:pending"`
+	AmountReceived        int64             `gorm:"not null;default:0"`
+	AmountOutstanding     int64             `gorm:"not null;default:0"`          // What the customer still has to send
+	AmountOverpaid        int64             `gorm:"not null;default:0"`          // Surplus recorded for a refund
+	AmountRefunded        int64             `gorm:"not null;default:0"`          // Sum of the refunds sent back to the customer
+	ExpiresAt             *time.Time        `gorm:"index"`                       // End of the window the quoted amount is valid for
+	ExchangeRate          float64           `gorm:"not null;default:0"`          // Price of one XMR in Currency the amount was quoted at
+	ExchangeRateSource    string            `gorm:"not null;size:32;default:''"` // Rate provider, or "pos" when the rate came from the POS request
+	ExchangeRateAt        *time.Time        // When the rate was fetched
+	ConfirmedAt           *time.Time        `gorm:"index"`              // When the payment first confirmed, recent ones are still watched for reorgs
+	RiskReason            *string           `gorm:"type:text"`          // Why the transaction was last marked at risk or reversed
+	RiskEpisodes          int               `gorm:"not null;default:0"` // How often the transaction was marked at risk
 	SubTransactions       []*SubTransaction `gorm:"foreignKey:TransactionID"`
+	Refunds               []*Refund         `gorm:"foreignKey:TransactionID"`
 	TransferID            *uint             `gorm:"index"` // Foreign key, nullable if not all transactions are transferred
 	Transfer              *Transfer         `gorm:"foreignKey:TransferID"`
 }
@@ -38,4 +82,5 @@ type SubTransaction struct {
 	TxHash          string    `gorm:"not null"`
 	UnlockTime      int64     `gorm:"not null"`
 	Locked          bool      `gorm:"not null"`
+	Reversed        bool      `gorm:"not null;default:false"` // The payment backend no longer reports the transfer
 }
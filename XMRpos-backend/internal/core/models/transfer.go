@@ -6,12 +6,25 @@ import (
 
 type Transfer struct {
 	gorm.Model
-	VendorID          uint           `gorm:"not null;index"` // Foreign key field
-	Vendor            Vendor         `gorm:"foreignKey:VendorID"`
-	Amount            int64          `gorm:"not null"`     // Amount to be transferred
-	AmountTransferred *int64         `gorm:"default:null"` // Amount that has been transferred (amount - fee)
-	Address           string         `gorm:"not null;type:text"`
-	TxHash            *string        `gorm:"type:text"`
-	Transactions      []*Transaction `gorm:"foreignKey:TransferID"`
-	Completed         bool           `gorm:"not null;default:false"` // Indicates if the transfer is completed
+	VendorID           uint           `gorm:"not null;index"` // Foreign key field
+	Vendor             Vendor         `gorm:"foreignKey:VendorID"`
+	Amount             int64          `gorm:"not null"`     // Amount to be transferred
+	AmountTransferred  *int64         `gorm:"default:null"` // Amount that has been transferred (amount - fee)
+	Address            string         `gorm:"not null;type:text"`
+	TxHash             *string        `gorm:"type:text"`
+	Transactions       []*Transaction `gorm:"foreignKey:TransferID"`
+	Completed          bool           `gorm:"not null;default:false"` // Indicates if the transfer is completed
+	WalletAccountIndex uint32         `gorm:"not null;default:0"`     // Wallet account the payout is spent from
+	// Outputs of a payout split across several addresses, empty when all of it goes to Address
+	Destinations []*TransferDestination `gorm:"foreignKey:TransferID"`
+}
+
+// TransferDestination is one output of a split payout
+type TransferDestination struct {
+	gorm.Model
+	TransferID        uint   `gorm:"not null;index"`
+	Address           string `gorm:"not null;type:text"`
+	Label             string `gorm:"not null;size:100;default:''"`
+	Amount            int64  `gorm:"not null"`
+	AmountTransferred *int64 `gorm:"default:null"` // Amount minus its share of the network fee
 }
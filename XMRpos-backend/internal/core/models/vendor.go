@@ -1,18 +1,98 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Vendor struct {
 	gorm.Model
-	Name             string        `gorm:"not null;uniqueIndex:idx_vendor_name,where:deleted_at IS NULL"`
-	Email            string        `gorm:"type:varchar(255)"`
-	PasswordHash     string        `gorm:"not null"`
-	PasswordVersion  uint32        `gorm:"not null;default:1"`
-	MoneroSubaddress string        `gorm:"not null"`
-	Pos              []Pos         `gorm:"foreignKey:VendorID"` // One-to-many relationship with Pos
-	Balance          int64         `gorm:"not null;default:0"`
-	Transactions     []Transaction `gorm:"foreignKey:VendorID"` // One-to-many relationship with Transactions
-	/* WalletAddress   string        `gorm:"not null"` */ // TODO: this will be useful when MoneroPay has implemented mutiple wallets per instance
+	Name               string        `gorm:"not null;uniqueIndex:idx_vendor_name,where:deleted_at IS NULL"`
+	Email              string        `gorm:"type:varchar(255)"`
+	PasswordHash       string        `gorm:"not null"`
+	PasswordVersion    uint32        `gorm:"not null;default:1"`
+	MoneroSubaddress   string        `gorm:"not null"`
+	Pos                []Pos         `gorm:"foreignKey:VendorID"` // One-to-many relationship with Pos
+	Balance            int64         `gorm:"not null;default:0"`
+	Transactions       []Transaction `gorm:"foreignKey:VendorID"` // One-to-many relationship with Transactions
+	WalletAccountIndex uint32        `gorm:"not null;default:0"`  // Wallet account holding the vendor's funds, 0 is shared by MoneroPay and older vendors
+	CatalogRevision    int64         `gorm:"not null;default:0"`  // Bumped in the transaction of every catalog change, POS devices sync from it
+
+	// Confirmation policy, enforced when a POS creates a transaction
+	ConfirmationCurrency string             `gorm:"not null;size:16;default:''"` // Currency the tier amounts are denominated in
+	DefaultConfirmations int64              `gorm:"not null;default:0"`          // Confirmations before acceptance when no tier matches
+	PayableConfirmations int64              `gorm:"not null;default:10"`         // Confirmations before a payment is confirmed and can be paid out
+	ConfirmationTiers    []ConfirmationTier `gorm:"foreignKey:VendorID"`
+
+	// Payout settings, automatic payouts are created by the payout scheduler
+	PayoutSchedule      string     `gorm:"not null;size:16;default:''"` // PayoutScheduleDaily, PayoutScheduleWeekly or empty for none
+	PayoutWeekday       int        `gorm:"not null;default:1"`          // Day of weekly payouts, 0 is Sunday
+	PayoutHour          int        `gorm:"not null;default:0"`          // Hour of the day in UTC scheduled payouts are due
+	PayoutThreshold     int64      `gorm:"not null;default:0"`          // Pay out as soon as the balance exceeds it, 0 disables
+	MinPayoutAmount     int64      `gorm:"not null;default:3000000000"` // Smallest payout, manual or automatic
+	LastScheduledPayout *time.Time // Scheduled payouts are due at the first slot after it
+}
+
+// Supported values for Vendor.PayoutSchedule
+const (
+	PayoutScheduleDaily  = "daily"
+	PayoutScheduleWeekly = "weekly"
+)
+
+// Payouts below 0.003 XMR lose too much to the network fee, vendors may still go down to 0.0001 XMR
+const (
+	DefaultMinPayoutAmount int64 = 3_000_000_000
+	MinPayoutAmountFloor   int64 = 100_000_000
+)
+
+// Received funds stay locked in the wallet for 10 blocks, a payment can't be paid out sooner
+const MinPayableConfirmations = 10
+
+// PayoutDestination is one address a vendor's payouts are split to. Fixed amounts are paid first,
+// what is left is divided between the percentage shares.
+type PayoutDestination struct {
+	gorm.Model
+	VendorID    uint    `gorm:"not null;index"` // Foreign key field
+	Address     string  `gorm:"not null;type:text"`
+	Label       string  `gorm:"not null;size:100;default:''"`
+	Percent     float64 `gorm:"not null;default:0"` // Share of the payout left after the fixed amounts
+	FixedAmount int64   `gorm:"not null;default:0"` // Atomic units
+}
+
+// PayoutAddressChange is a requested change of where a vendor's payouts go. Changes are kept
+// as the vendor's change history and only take effect after a cooling-off period.
+type PayoutAddressChange struct {
+	gorm.Model
+	VendorID         uint       `gorm:"not null;index"` // Foreign key field
+	Kind             string     `gorm:"not null;size:16"`
+	OldAddress       string     `gorm:"not null;type:text;default:''"` // Address changes only
+	NewAddress       string     `gorm:"not null;type:text;default:''"` // Address changes only
+	Destinations     string     `gorm:"not null;type:text;default:''"` // New payout split as JSON, destination changes only
+	Status           string     `gorm:"not null;size:16;index"`
+	ConfirmTokenHash string     `gorm:"not null;size:64;index;default:''"` // SHA-256 of the emailed confirmation token
+	EffectiveAt      *time.Time // End of the cooling-off, set once the change is confirmed
+	ResolvedAt       *time.Time // When the change was applied or cancelled
+}
+
+// Supported values for PayoutAddressChange.Kind
+const (
+	PayoutAddressChangeAddress      = "address"
+	PayoutAddressChangeDestinations = "destinations"
+)
+
+// Statuses of a PayoutAddressChange. Payouts are frozen while a change is pending.
+const (
+	PayoutAddressChangeUnconfirmed = "unconfirmed" // Waiting for the emailed confirmation link
+	PayoutAddressChangePending     = "pending"     // In the cooling-off period
+	PayoutAddressChangeApplied     = "applied"
+	PayoutAddressChangeCancelled   = "cancelled"
+)
+
+// ConfirmationTier sets the confirmations needed to accept transactions below an amount
+type ConfirmationTier struct {
+	gorm.Model
+	VendorID      uint    `gorm:"not null;index"` // Foreign key field
+	BelowAmount   float64 `gorm:"not null"`       // In the vendor's ConfirmationCurrency
+	Confirmations int64   `gorm:"not null"`
 }
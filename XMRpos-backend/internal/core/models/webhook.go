@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Events a vendor can subscribe a webhook endpoint to
+const (
+	WebhookEventTransactionCreated   = "transaction.created"
+	WebhookEventTransactionAccepted  = "transaction.accepted"
+	WebhookEventTransactionConfirmed = "transaction.confirmed"
+	WebhookEventTransactionExpired   = "transaction.expired"
+	WebhookEventTransactionAtRisk    = "transaction.at_risk"
+	WebhookEventTransactionReversed  = "transaction.reversed"
+	WebhookEventTransferCompleted    = "transfer.completed"
+)
+
+// Delivery states of a queued webhook event
+const (
+	WebhookDeliveryPending   = "pending"   // Waiting for the first attempt or a retry
+	WebhookDeliveryDelivered = "delivered" // The endpoint answered with a 2xx status
+	WebhookDeliveryFailed    = "failed"    // Retries were exhausted or the endpoint was removed
+)
+
+type WebhookEndpoint struct {
+	gorm.Model
+	VendorID uint   `gorm:"not null;index"` // Foreign key field
+	URL      string `gorm:"not null;type:text"`
+	Secret   string `gorm:"not null"`                      // HMAC key the payloads are signed with
+	Events   string `gorm:"not null;type:text;default:''"` // Comma separated event types, empty subscribes to all
+}
+
+// WebhookDelivery is one event queued for one endpoint. It doubles as the delivery log.
+type WebhookDelivery struct {
+	gorm.Model
+	EndpointID       uint            `gorm:"not null;uniqueIndex:idx_webhook_delivery_event,priority:1"` // Foreign key field
+	Endpoint         WebhookEndpoint `gorm:"foreignKey:EndpointID"`
+	VendorID         uint            `gorm:"not null;index"`
+	EventKey         string          `gorm:"not null;size:128;uniqueIndex:idx_webhook_delivery_event,priority:2"` // e.g. "transaction.confirmed:42", makes events idempotent
+	EventType        string          `gorm:"not null;size:64"`
+	Payload          string          `gorm:"not null;type:text"`
+	Status           string          `gorm:"not null;size:16;default:pending;index:idx_webhook_delivery_due,priority:1"`
+	Attempts         int             `gorm:"not null;default:0"`
+	NextAttemptAt    time.Time       `gorm:"not null;index:idx_webhook_delivery_due,priority:2"`
+	LastResponseCode int             `gorm:"not null;default:0"`
+	LastError        *string         `gorm:"type:text"`
+	DeliveredAt      *time.Time
+}
@@ -0,0 +1,152 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+// MoneroPayBackend adapts the MoneroPay v2 API to PaymentBackend
+type MoneroPayBackend struct {
+	client *moneropay.MoneroPayAPIClient
+}
+
+func NewMoneroPayBackend(client *moneropay.MoneroPayAPIClient) *MoneroPayBackend {
+	return &MoneroPayBackend{client: client}
+}
+
+func (b *MoneroPayBackend) Name() string {
+	return "moneropay"
+}
+
+func (b *MoneroPayBackend) CreateReceive(ctx context.Context, req ReceiveRequest) (*ReceiveResponse, error) {
+	if req.AccountIndex != 0 {
+		return nil, ErrAccountsNotSupported
+	}
+	resp, err := b.client.PostReceive(ctx, &moneropay.ReceiveRequest{
+		Amount:      req.Amount,
+		Description: req.Description,
+		CallbackUrl: req.CallbackURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiveResponse{Address: resp.Address, CreatedAt: resp.CreatedAt}, nil
+}
+
+func (b *MoneroPayBackend) GetReceiveStatus(ctx context.Context, address string) (*ReceiveStatus, error) {
+	resp, err := b.client.GetReceiveAddress(ctx, address, &moneropay.GetReceiveAddressParams{})
+	if err != nil {
+		return nil, err
+	}
+	status := MoneroPayReceiveStatus(*resp)
+	return &status, nil
+}
+
+func (b *MoneroPayBackend) Transfer(ctx context.Context, transfer TransferRequest) (*TransferResult, error) {
+	if transfer.AccountIndex != 0 {
+		return nil, fmt.Errorf("%w: %w", ErrTransferRejected, ErrAccountsNotSupported)
+	}
+
+	destinations := transfer.Destinations
+	req := &moneropay.TransferRequest{
+		Destinations:           make([]moneropay.Destination, len(destinations)),
+		SubtractFeeFromOutputs: make([]uint, len(destinations)),
+	}
+	for i, dest := range destinations {
+		req.Destinations[i] = moneropay.Destination{Amount: dest.Amount, Address: dest.Address}
+		req.SubtractFeeFromOutputs[i] = uint(i)
+	}
+
+	resp, err := b.client.PostTransfer(ctx, req)
+	if err != nil {
+		// MoneroPay refuses invalid transfers before they reach the wallet, a server error may come after the relay
+		var statusErr *moneropay.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusBadRequest && statusErr.StatusCode < http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: %w", ErrTransferRejected, err)
+		}
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("MoneroPay transfer returned nil response")
+	}
+
+	txHash := resp.TxHash
+	if txHash == "" && len(resp.TxHashList) > 0 {
+		txHash = resp.TxHashList[0]
+	}
+	if txHash == "" {
+		return nil, fmt.Errorf("MoneroPay transfer returned empty tx hash")
+	}
+
+	amounts := make([]int64, len(resp.Destinations))
+	for i, dest := range resp.Destinations {
+		amounts[i] = dest.Amount
+	}
+	if len(amounts) == 0 {
+		amounts = make([]int64, len(destinations))
+		for i, dest := range destinations {
+			amounts[i] = dest.Amount
+		}
+	}
+
+	return &TransferResult{TxHash: txHash, Amounts: amounts}, nil
+}
+
+// CreateAccount is not supported, MoneroPay creates every subaddress in account 0
+func (b *MoneroPayBackend) CreateAccount(ctx context.Context, label string) (uint32, error) {
+	return 0, ErrAccountsNotSupported
+}
+
+func (b *MoneroPayBackend) GetBalance(ctx context.Context, accountIndex uint32) (*Balance, error) {
+	if accountIndex != 0 {
+		return nil, ErrAccountsNotSupported
+	}
+	resp, err := b.client.GetBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Balance{Total: resp.Total, Unlocked: resp.Unlocked}, nil
+}
+
+func (b *MoneroPayBackend) GetHealth(ctx context.Context) (*Health, error) {
+	resp, err := b.client.GetHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Health{
+		Status: resp.Status,
+		Services: map[string]bool{
+			"walletrpc":  resp.Services.Walletrpc,
+			"postgresql": resp.Services.Postgresql,
+		},
+	}, nil
+}
+
+// MoneroPayReceiveStatus converts a MoneroPay receive (or callback) payload into a ReceiveStatus
+func MoneroPayReceiveStatus(resp moneropay.ReceiveAddressResponse) ReceiveStatus {
+	status := ReceiveStatus{
+		Expected:        resp.Amount.Expected,
+		CoveredTotal:    resp.Amount.Covered.Total,
+		CoveredUnlocked: resp.Amount.Covered.Unlocked,
+		Complete:        resp.Complete,
+		Transfers:       make([]IncomingTransfer, len(resp.Transactions)),
+	}
+	for i, tx := range resp.Transactions {
+		status.Transfers[i] = IncomingTransfer{
+			Amount:          tx.Amount,
+			Confirmations:   tx.Confirmations,
+			DoubleSpendSeen: tx.DoubleSpendSeen,
+			Fee:             tx.Fee,
+			Height:          tx.Height,
+			Timestamp:       tx.Timestamp,
+			TxHash:          tx.TxHash,
+			UnlockTime:      tx.UnlockTime,
+			Locked:          tx.Locked,
+		}
+	}
+	return status
+}
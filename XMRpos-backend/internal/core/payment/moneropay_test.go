@@ -0,0 +1,65 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestMoneroPayBackend(t *testing.T) {
+	fake := testutil.NewFakeMoneroPay(t)
+	fake.TransferFee = 1_000
+	backend := fake.Backend()
+	ctx := context.Background()
+
+	receive, err := backend.CreateReceive(ctx, payment.ReceiveRequest{Amount: 5_000, Description: "tea", CallbackURL: "http://backend.test/cb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Receives()[0].Request; got.CallbackUrl != "http://backend.test/cb" || got.Description != "tea" {
+		t.Fatalf("unexpected receive request: %+v", got)
+	}
+
+	fake.SetPayments(receive.Address, testutil.Payment("a", 2_000, 10), testutil.Payment("b", 3_000, 1))
+	status, err := backend.GetReceiveStatus(ctx, receive.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Expected != 5_000 || status.CoveredTotal != 5_000 || status.CoveredUnlocked != 2_000 || !status.Complete {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if len(status.Transfers) != 2 || status.Transfers[1].TxHash != "b" || !status.Transfers[1].Locked {
+		t.Fatalf("unexpected transfers: %+v", status.Transfers)
+	}
+
+	result, err := backend.Transfer(ctx, payment.TransferRequest{
+		Destinations: []payment.Destination{{Amount: 10_000, Address: testutil.Subaddress(1)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TxHash != "moneropay-transfer-1" || len(result.Amounts) != 1 || result.Amounts[0] != 9_000 {
+		t.Fatalf("unexpected transfer result: %+v", result)
+	}
+	if sent := fake.Transfers()[0]; len(sent.SubtractFeeFromOutputs) != 1 {
+		t.Fatalf("fee not subtracted from outputs: %+v", sent)
+	}
+
+	if _, err := backend.CreateReceive(ctx, payment.ReceiveRequest{AccountIndex: 2, Amount: 1}); !errors.Is(err, payment.ErrAccountsNotSupported) {
+		t.Fatalf("expected ErrAccountsNotSupported for a vendor account, got %v", err)
+	}
+	if _, err := backend.CreateAccount(ctx, "vendor"); !errors.Is(err, payment.ErrAccountsNotSupported) {
+		t.Fatalf("expected ErrAccountsNotSupported, got %v", err)
+	}
+
+	health, err := backend.GetHealth(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.Healthy() || !health.Services["walletrpc"] || !health.Services["postgresql"] {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+}
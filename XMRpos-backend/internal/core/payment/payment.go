@@ -0,0 +1,114 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAccountsNotSupported is returned by backends that keep all funds in wallet account 0
+var ErrAccountsNotSupported = errors.New("payment backend does not support wallet accounts")
+
+// ErrTransferRejected is wrapped by Transfer errors when the backend refused the transfer, so nothing
+// was sent. Any other Transfer error may have come after the transfer was relayed.
+var ErrTransferRejected = errors.New("transfer rejected")
+
+// PaymentBackend is the source of receive addresses and the sink for payouts.
+// Feature packages depend on this interface only, so MoneroPay, a direct
+// monero-wallet-rpc connection or a simulator can be swapped in via config.
+type PaymentBackend interface {
+	// Name identifies the backend in logs and health responses
+	Name() string
+
+	// CreateReceive hands out a fresh address for a single payment
+	CreateReceive(ctx context.Context, req ReceiveRequest) (*ReceiveResponse, error)
+
+	// GetReceiveStatus reports everything received on an address so far
+	GetReceiveStatus(ctx context.Context, address string) (*ReceiveStatus, error)
+
+	// Transfer sends funds from a wallet account to the destinations, subtracting the fee from the outputs
+	Transfer(ctx context.Context, req TransferRequest) (*TransferResult, error)
+
+	// CreateAccount creates a wallet account so a vendor's funds are kept apart from everyone else's
+	CreateAccount(ctx context.Context, label string) (uint32, error)
+
+	GetBalance(ctx context.Context, accountIndex uint32) (*Balance, error)
+	GetHealth(ctx context.Context) (*Health, error)
+}
+
+type ReceiveRequest struct {
+	// AccountIndex is the wallet account the receive address is created under
+	AccountIndex uint32
+	Amount       int64
+	Description  string
+	// CallbackURL is used by backends that push payment updates, it may be ignored otherwise
+	CallbackURL string
+}
+
+type ReceiveResponse struct {
+	Address   string
+	CreatedAt time.Time
+}
+
+// ReceiveStatus is the payment state of a receive address
+type ReceiveStatus struct {
+	Expected        int64
+	CoveredTotal    int64
+	CoveredUnlocked int64
+	Complete        bool
+	Transfers       []IncomingTransfer
+	// Partial is set when Transfers only holds the transfer an event was raised for, so a
+	// transfer missing from it has not necessarily disappeared
+	Partial bool
+}
+
+// IncomingTransfer is a single on-chain transfer to a receive address
+type IncomingTransfer struct {
+	Amount          int64
+	Confirmations   int64
+	DoubleSpendSeen bool
+	Fee             int64
+	Height          int64
+	Timestamp       time.Time
+	TxHash          string
+	UnlockTime      int64
+	Locked          bool
+}
+
+type TransferRequest struct {
+	AccountIndex uint32
+	Destinations []Destination
+}
+
+type Destination struct {
+	Amount  int64
+	Address string
+}
+
+// TransferResult holds the tx hash and the amount that reached each destination after fees
+type TransferResult struct {
+	TxHash  string
+	Amounts []int64
+}
+
+// OutgoingTransfer is a transfer the wallet sent, with the amount that reached each destination
+type OutgoingTransfer struct {
+	TxHash       string
+	Timestamp    time.Time
+	Destinations []Destination
+}
+
+type Balance struct {
+	Total    int64 `json:"total"`
+	Unlocked int64 `json:"unlocked"`
+}
+
+// Health keeps the shape of the MoneroPay health response which the POS app already parses
+type Health struct {
+	Status   int             `json:"status"`
+	Services map[string]bool `json:"services"`
+}
+
+func (h *Health) Healthy() bool {
+	return h != nil && h.Status == 200
+}
@@ -0,0 +1,298 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rpc"
+)
+
+const WalletRPCBackendName = "walletrpc"
+
+// Number of confirmations after which wallet outputs become spendable
+const walletUnlockConfirmations = 10
+
+// WalletRPCBackend talks to monero-wallet-rpc directly. Receive addresses are
+// subaddresses of the requested wallet account and payments are found by polling
+// get_transfers, so no MoneroPay instance or callback is needed.
+type WalletRPCBackend struct {
+	client *rpc.Client
+}
+
+func NewWalletRPCBackend(client *rpc.Client) *WalletRPCBackend {
+	return &WalletRPCBackend{client: client}
+}
+
+func (b *WalletRPCBackend) Name() string {
+	return WalletRPCBackendName
+}
+
+func (b *WalletRPCBackend) CreateReceive(ctx context.Context, req ReceiveRequest) (*ReceiveResponse, error) {
+	params := struct {
+		AccountIndex uint32 `json:"account_index"`
+		Label        string `json:"label,omitempty"`
+	}{AccountIndex: req.AccountIndex, Label: req.Description}
+
+	var result struct {
+		Address      string `json:"address"`
+		AddressIndex uint32 `json:"address_index"`
+	}
+	if err := b.client.Call(ctx, "create_address", params, &result); err != nil {
+		return nil, err
+	}
+	if result.Address == "" {
+		return nil, fmt.Errorf("create_address returned empty address")
+	}
+
+	return &ReceiveResponse{Address: result.Address, CreatedAt: time.Now().UTC()}, nil
+}
+
+type walletTransfer struct {
+	Amount          int64  `json:"amount"`
+	Confirmations   int64  `json:"confirmations"`
+	DoubleSpendSeen bool   `json:"double_spend_seen"`
+	Fee             int64  `json:"fee"`
+	Height          int64  `json:"height"`
+	Locked          bool   `json:"locked"`
+	Timestamp       int64  `json:"timestamp"`
+	TxID            string `json:"txid"`
+	UnlockTime      int64  `json:"unlock_time"`
+}
+
+// GetReceiveStatus sums the confirmed and mempool transfers to the subaddress.
+// The wallet does not know the requested amount, so Expected and Complete are left empty.
+func (b *WalletRPCBackend) GetReceiveStatus(ctx context.Context, address string) (*ReceiveStatus, error) {
+	var index struct {
+		Index struct {
+			Major uint32 `json:"major"`
+			Minor uint32 `json:"minor"`
+		} `json:"index"`
+	}
+	if err := b.client.Call(ctx, "get_address_index", map[string]string{"address": address}, &index); err != nil {
+		return nil, err
+	}
+
+	params := struct {
+		In             bool     `json:"in"`
+		Pool           bool     `json:"pool"`
+		AccountIndex   uint32   `json:"account_index"`
+		SubaddrIndices []uint32 `json:"subaddr_indices"`
+	}{In: true, Pool: true, AccountIndex: index.Index.Major, SubaddrIndices: []uint32{index.Index.Minor}}
+
+	var result struct {
+		In   []walletTransfer `json:"in"`
+		Pool []walletTransfer `json:"pool"`
+	}
+	if err := b.client.Call(ctx, "get_transfers", params, &result); err != nil {
+		return nil, err
+	}
+
+	status := &ReceiveStatus{Transfers: make([]IncomingTransfer, 0, len(result.In)+len(result.Pool))}
+	seen := make(map[string]bool)
+	for _, tx := range append(result.In, result.Pool...) {
+		// a transfer can briefly show up in both lists while it is being mined
+		if seen[tx.TxID] {
+			continue
+		}
+		seen[tx.TxID] = true
+
+		locked := tx.Locked || tx.Confirmations < walletUnlockConfirmations
+		status.Transfers = append(status.Transfers, IncomingTransfer{
+			Amount:          tx.Amount,
+			Confirmations:   tx.Confirmations,
+			DoubleSpendSeen: tx.DoubleSpendSeen,
+			Fee:             tx.Fee,
+			Height:          tx.Height,
+			Timestamp:       time.Unix(tx.Timestamp, 0).UTC(),
+			TxHash:          tx.TxID,
+			UnlockTime:      tx.UnlockTime,
+			Locked:          locked,
+		})
+		status.CoveredTotal += tx.Amount
+		if !locked {
+			status.CoveredUnlocked += tx.Amount
+		}
+	}
+
+	return status, nil
+}
+
+// Transfer dry-runs the transfer first to ensure it fits in a single transaction, then relays it.
+// Only outputs of the requested account are spent.
+func (b *WalletRPCBackend) Transfer(ctx context.Context, req TransferRequest) (*TransferResult, error) {
+	destinations := req.Destinations
+
+	type rpcDestination struct {
+		Amount  int64  `json:"amount"`
+		Address string `json:"address"`
+	}
+
+	type transferParams struct {
+		Destinations           []rpcDestination `json:"destinations"`
+		AccountIndex           uint32           `json:"account_index"`
+		SubtractFeeFromOutputs []uint           `json:"subtract_fee_from_outputs,omitempty"`
+		DoNotRelay             bool             `json:"do_not_relay,omitempty"`
+		Priority               uint             `json:"priority,omitempty"`
+	}
+
+	params := transferParams{
+		Destinations:           make([]rpcDestination, len(destinations)),
+		AccountIndex:           req.AccountIndex,
+		SubtractFeeFromOutputs: make([]uint, 0, len(destinations)),
+		DoNotRelay:             true,
+		Priority:               0,
+	}
+
+	for i, dest := range destinations {
+		params.Destinations[i] = rpcDestination{
+			Amount:  dest.Amount,
+			Address: dest.Address,
+		}
+		params.SubtractFeeFromOutputs = append(params.SubtractFeeFromOutputs, uint(i))
+	}
+
+	type transferResult struct {
+		Amount        int64 `json:"amount"`
+		AmountsByDest struct {
+			Amounts []int64 `json:"amounts"`
+		} `json:"amounts_by_dest"`
+		Fee    int64  `json:"fee"`
+		TxHash string `json:"tx_hash"`
+	}
+
+	var dryRun transferResult
+	callCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	err := b.client.Call(callCtx, "transfer", params, &dryRun)
+	cancel()
+	if err != nil {
+		return nil, walletTransferError(err)
+	}
+
+	params.DoNotRelay = false
+
+	var result transferResult
+	callCtx2, cancel2 := context.WithTimeout(ctx, 15*time.Second)
+	err = b.client.Call(callCtx2, "transfer", params, &result)
+	cancel2()
+	if err != nil {
+		return nil, walletTransferError(err)
+	}
+
+	if result.TxHash == "" {
+		return nil, fmt.Errorf("wallet RPC transfer returned empty tx hash")
+	}
+
+	amounts := result.AmountsByDest.Amounts
+	if len(amounts) == 0 {
+		amounts = make([]int64, len(destinations))
+		for i, dest := range destinations {
+			amounts[i] = dest.Amount
+		}
+	}
+
+	return &TransferResult{TxHash: result.TxHash, Amounts: amounts}, nil
+}
+
+// walletTransferError marks the error of a transfer call as a rejection when the wallet answered with one.
+// A dropped connection or a timeout leaves open whether the wallet relayed the transfer.
+func walletTransferError(err error) error {
+	var rpcErr *rpc.Error
+	if errors.As(err, &rpcErr) {
+		return fmt.Errorf("%w: %w", ErrTransferRejected, err)
+	}
+	return err
+}
+
+// GetOutgoingTransfers lists the transfers sent from a wallet account, including those still in the pool.
+// Only the wallet that relayed a transfer knows its destinations.
+func (b *WalletRPCBackend) GetOutgoingTransfers(ctx context.Context, accountIndex uint32) ([]OutgoingTransfer, error) {
+	params := struct {
+		Out          bool   `json:"out"`
+		Pending      bool   `json:"pending"`
+		Pool         bool   `json:"pool"`
+		AccountIndex uint32 `json:"account_index"`
+	}{Out: true, Pending: true, Pool: true, AccountIndex: accountIndex}
+
+	type rpcTransfer struct {
+		Destinations []struct {
+			Amount  int64  `json:"amount"`
+			Address string `json:"address"`
+		} `json:"destinations"`
+		Timestamp int64  `json:"timestamp"`
+		TxID      string `json:"txid"`
+	}
+	var result struct {
+		Out     []rpcTransfer `json:"out"`
+		Pending []rpcTransfer `json:"pending"`
+		Pool    []rpcTransfer `json:"pool"`
+	}
+	if err := b.client.Call(ctx, "get_transfers", params, &result); err != nil {
+		return nil, err
+	}
+
+	transfers := make([]OutgoingTransfer, 0, len(result.Out)+len(result.Pending))
+	seen := make(map[string]bool)
+	for _, tx := range append(append(result.Out, result.Pending...), result.Pool...) {
+		if seen[tx.TxID] {
+			continue
+		}
+		seen[tx.TxID] = true
+
+		transfer := OutgoingTransfer{
+			TxHash:       tx.TxID,
+			Timestamp:    time.Unix(tx.Timestamp, 0).UTC(),
+			Destinations: make([]Destination, len(tx.Destinations)),
+		}
+		for i, dest := range tx.Destinations {
+			transfer.Destinations[i] = Destination{Amount: dest.Amount, Address: dest.Address}
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+func (b *WalletRPCBackend) CreateAccount(ctx context.Context, label string) (uint32, error) {
+	params := struct {
+		Label string `json:"label,omitempty"`
+	}{Label: label}
+
+	var result struct {
+		AccountIndex uint32 `json:"account_index"`
+		Address      string `json:"address"`
+	}
+	if err := b.client.Call(ctx, "create_account", params, &result); err != nil {
+		return 0, err
+	}
+	return result.AccountIndex, nil
+}
+
+func (b *WalletRPCBackend) GetBalance(ctx context.Context, accountIndex uint32) (*Balance, error) {
+	params := struct {
+		AccountIndex uint32 `json:"account_index"`
+	}{AccountIndex: accountIndex}
+
+	var result struct {
+		Balance         int64 `json:"balance"`
+		UnlockedBalance int64 `json:"unlocked_balance"`
+	}
+	if err := b.client.Call(ctx, "get_balance", params, &result); err != nil {
+		return nil, err
+	}
+
+	return &Balance{Total: result.Balance, Unlocked: result.UnlockedBalance}, nil
+}
+
+// GetHealth reports the wallet as unhealthy instead of failing so the health endpoint can show which service is down
+func (b *WalletRPCBackend) GetHealth(ctx context.Context) (*Health, error) {
+	var version struct {
+		Version uint32 `json:"version"`
+	}
+	if err := b.client.Call(ctx, "get_version", nil, &version); err != nil {
+		return &Health{Status: http.StatusServiceUnavailable, Services: map[string]bool{"walletrpc": false}}, nil
+	}
+	return &Health{Status: http.StatusOK, Services: map[string]bool{"walletrpc": true}}, nil
+}
@@ -0,0 +1,162 @@
+package payment_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestWalletRPCBackendReceive(t *testing.T) {
+	wallet := testutil.NewFakeWalletRPC(t)
+	backend := payment.NewWalletRPCBackend(wallet.Client())
+	ctx := context.Background()
+
+	receive, err := backend.CreateReceive(ctx, payment.ReceiveRequest{Amount: 5_000, Description: "tea"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := backend.CreateReceive(ctx, payment.ReceiveRequest{Amount: 1_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receive.Address == other.Address {
+		t.Fatal("expected a fresh subaddress per receive")
+	}
+
+	wallet.SetPayments(receive.Address, testutil.Payment("mined", 2_000, 12), testutil.Payment("pool", 3_000, 0))
+	wallet.SetPayments(other.Address, testutil.Payment("unrelated", 1_000, 12))
+
+	status, err := backend.GetReceiveStatus(ctx, receive.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.CoveredTotal != 5_000 || status.CoveredUnlocked != 2_000 || len(status.Transfers) != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	for _, tx := range status.Transfers {
+		if tx.TxHash == "pool" && (!tx.Locked || tx.Confirmations != 0) {
+			t.Fatalf("pool transfer should be locked: %+v", tx)
+		}
+	}
+
+	if _, err := backend.GetReceiveStatus(ctx, testutil.Subaddress(1)); err == nil {
+		t.Fatal("expected error for an address outside the wallet")
+	}
+}
+
+func TestWalletRPCBackendTransfer(t *testing.T) {
+	wallet := testutil.NewFakeWalletRPC(t)
+	wallet.TransferFee = 100
+	backend := payment.NewWalletRPCBackend(wallet.Client())
+
+	result, err := backend.Transfer(context.Background(), payment.TransferRequest{
+		AccountIndex: 3,
+		Destinations: []payment.Destination{
+			{Amount: 1_000, Address: testutil.Subaddress(1)},
+			{Amount: 2_000, Address: testutil.Subaddress(2)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TxHash != "wallet-transfer-1" || len(result.Amounts) != 2 || result.Amounts[1] != 1_900 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	calls := wallet.Calls("transfer")
+	if len(calls) != 2 {
+		t.Fatalf("expected dry run and relay, got %d calls", len(calls))
+	}
+	var params struct {
+		AccountIndex           uint32 `json:"account_index"`
+		SubtractFeeFromOutputs []uint `json:"subtract_fee_from_outputs"`
+	}
+	if err := json.Unmarshal(calls[1].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.AccountIndex != 3 || len(params.SubtractFeeFromOutputs) != 2 || strings.Contains(string(calls[1].Params), "do_not_relay") {
+		t.Fatalf("unexpected relay params: %s", calls[1].Params)
+	}
+
+	sent, err := backend.GetOutgoingTransfers(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].TxHash != "wallet-transfer-1" || len(sent[0].Destinations) != 2 || sent[0].Destinations[1].Amount != 1_900 {
+		t.Fatalf("unexpected outgoing transfers: %+v", sent)
+	}
+	if other, err := backend.GetOutgoingTransfers(context.Background(), 4); err != nil || len(other) != 0 {
+		t.Fatalf("transfers of another account: %+v, %v", other, err)
+	}
+}
+
+func TestWalletRPCBackendTransferRejected(t *testing.T) {
+	wallet := testutil.NewFakeWalletRPC(t)
+	wallet.Handle("transfer", func(json.RawMessage) (any, *testutil.RPCError) {
+		return nil, &testutil.RPCError{Code: -4, Message: "not enough unlocked money"}
+	})
+	backend := payment.NewWalletRPCBackend(wallet.Client())
+	req := payment.TransferRequest{Destinations: []payment.Destination{{Amount: 1_000, Address: testutil.Subaddress(1)}}}
+
+	if _, err := backend.Transfer(context.Background(), req); !errors.Is(err, payment.ErrTransferRejected) {
+		t.Fatalf("wallet error: got %v, want a rejection", err)
+	}
+
+	// Without an answer from the wallet the transfer may have been relayed
+	wallet.Server.Close()
+	if _, err := backend.Transfer(context.Background(), req); err == nil || errors.Is(err, payment.ErrTransferRejected) {
+		t.Fatalf("unreachable wallet: got %v, want an error that is not a rejection", err)
+	}
+}
+
+func TestWalletRPCBackendAccounts(t *testing.T) {
+	wallet := testutil.NewFakeWalletRPC(t)
+	backend := payment.NewWalletRPCBackend(wallet.Client())
+	ctx := context.Background()
+
+	accountIndex, err := backend.CreateAccount(ctx, "vendor:bakery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountIndex == 0 {
+		t.Fatal("expected a new account, got the shared account 0")
+	}
+
+	if _, err := backend.CreateReceive(ctx, payment.ReceiveRequest{AccountIndex: accountIndex, Amount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := wallet.Calls("create_address"); !strings.Contains(string(calls[0].Params), `"account_index":1`) {
+		t.Fatalf("subaddress not created under the vendor account: %s", calls[0].Params)
+	}
+
+	wallet.SetBalance(0, 7_000, 7_000)
+	wallet.SetBalance(accountIndex, 5_000, 2_000)
+	balance, err := backend.GetBalance(ctx, accountIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Total != 5_000 || balance.Unlocked != 2_000 {
+		t.Fatalf("unexpected account balance: %+v", balance)
+	}
+}
+
+func TestWalletRPCBackendHealth(t *testing.T) {
+	wallet := testutil.NewFakeWalletRPC(t)
+	backend := payment.NewWalletRPCBackend(wallet.Client())
+
+	health, err := backend.GetHealth(context.Background())
+	if err != nil || !health.Healthy() {
+		t.Fatalf("expected healthy wallet, got %+v, %v", health, err)
+	}
+
+	wallet.Server.Close()
+	health, err = backend.GetHealth(context.Background())
+	if err != nil || health.Healthy() || health.Services["walletrpc"] {
+		t.Fatalf("expected unhealthy wallet, got %+v, %v", health, err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package qr renders payment URIs as QR codes, so every client shows the same code.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Bounds of the rendered width and height in pixels
+const (
+	DefaultSize = 256
+	MinSize     = 64
+	MaxSize     = 1024
+)
+
+var ErrInvalidOptions = errors.New("invalid QR code options")
+
+type Options struct {
+	Format string
+	Size   int
+}
+
+// ParseOptions reads the format and size query parameters, both optional
+func ParseOptions(query url.Values) (Options, error) {
+	opts := Options{Format: FormatPNG, Size: DefaultSize}
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != FormatPNG && format != FormatSVG {
+			return opts, fmt.Errorf("%w: format must be png or svg", ErrInvalidOptions)
+		}
+		opts.Format = format
+	}
+	if size := query.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil || value < MinSize || value > MaxSize {
+			return opts, fmt.Errorf("%w: size must be between %d and %d", ErrInvalidOptions, MinSize, MaxSize)
+		}
+		opts.Size = value
+	}
+	return opts, nil
+}
+
+// Render encodes content and returns the image with its content type
+func Render(content string, opts Options) ([]byte, string, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if opts.Format == FormatSVG {
+		return svg(code.Bitmap(), opts.Size), "image/svg+xml", nil
+	}
+	png, err := code.PNG(opts.Size)
+	if err != nil {
+		return nil, "", err
+	}
+	return png, "image/png", nil
+}
+
+// svg draws one unit square per dark module, the quiet zone is part of the bitmap
+func svg(bitmap [][]bool, size int) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap))
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&sb, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+	return []byte(sb.String())
+}
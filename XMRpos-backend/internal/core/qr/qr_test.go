@@ -0,0 +1,59 @@
+package qr_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/qr"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := qr.ParseOptions(url.Values{})
+	if err != nil || opts != (qr.Options{Format: qr.FormatPNG, Size: qr.DefaultSize}) {
+		t.Fatalf("defaults: got %+v, %v", opts, err)
+	}
+	opts, err = qr.ParseOptions(url.Values{"format": {"SVG"}, "size": {"512"}})
+	if err != nil || opts != (qr.Options{Format: qr.FormatSVG, Size: 512}) {
+		t.Fatalf("svg at 512: got %+v, %v", opts, err)
+	}
+
+	for _, query := range []url.Values{
+		{"format": {"gif"}},
+		{"size": {"big"}},
+		{"size": {"63"}},
+		{"size": {"1025"}},
+	} {
+		if _, err := qr.ParseOptions(query); !errors.Is(err, qr.ErrInvalidOptions) {
+			t.Errorf("%v: got %v, want ErrInvalidOptions", query, err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	const uri = "monero:888tNkZrPN6JsEgekjMnABU4TBzc2Dt29EPAvkRxbANsAnjyPbb3iQ1YBRk1UXcdRsiKc9dhwMVgN5S9cQUiyoogDavup3H?tx_amount=1.5"
+
+	data, contentType, err := qr.Render(uri, qr.Options{Format: qr.FormatPNG, Size: 300})
+	if err != nil || contentType != "image/png" {
+		t.Fatalf("png: %s, %v", contentType, err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+		t.Fatalf("png size: got %v, want 300x300", bounds)
+	}
+
+	data, contentType, err = qr.Render(uri, qr.Options{Format: qr.FormatSVG, Size: 300})
+	if err != nil || contentType != "image/svg+xml" {
+		t.Fatalf("svg: %s, %v", contentType, err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, `width="300" height="300"`) || !strings.Contains(svg, "h1v1h-1z") {
+		t.Fatalf("unexpected svg: %.200s", svg)
+	}
+}
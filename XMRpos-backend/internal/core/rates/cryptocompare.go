@@ -0,0 +1,72 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultCryptoCompareURL is the API the POS app used to query directly
+const DefaultCryptoCompareURL = "https://min-api.cryptocompare.com/data"
+
+// CryptoCompareProvider reads prices from the CryptoCompare price endpoint
+type CryptoCompareProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewCryptoCompareProvider(baseURL string) *CryptoCompareProvider {
+	if baseURL == "" {
+		baseURL = DefaultCryptoCompareURL
+	}
+	return &CryptoCompareProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *CryptoCompareProvider) Name() string {
+	return "cryptocompare"
+}
+
+func (p *CryptoCompareProvider) Rates(ctx context.Context, currencies []string) (map[string]float64, error) {
+	query := url.Values{}
+	query.Set("fsym", "XMR")
+	query.Set("tsyms", strings.Join(currencies, ","))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/price?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cryptocompare returned status %d", resp.StatusCode)
+	}
+
+	// Prices come back as {"EUR": 150.1}, failures as {"Response": "Error", "Message": "..."}
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body["Response"] == "Error" {
+		return nil, fmt.Errorf("cryptocompare error: %v", body["Message"])
+	}
+
+	result := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		if rate, ok := body[currency].(float64); ok {
+			result[currency] = rate
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package rates
+
+import (
+	"context"
+	"strings"
+)
+
+// FixedProvider quotes a static price list, for tests and deployments without internet access
+type FixedProvider struct {
+	rates map[string]float64
+}
+
+func NewFixedProvider(rates map[string]float64) *FixedProvider {
+	normalized := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	return &FixedProvider{rates: normalized}
+}
+
+func (p *FixedProvider) Name() string {
+	return "fixed"
+}
+
+func (p *FixedProvider) Rates(ctx context.Context, currencies []string) (map[string]float64, error) {
+	result := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		if rate, ok := p.rates[currency]; ok {
+			result[currency] = rate
+		}
+	}
+	return result, nil
+}
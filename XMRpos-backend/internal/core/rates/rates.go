@@ -0,0 +1,129 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const atomicUnitsPerXMR = 1_000_000_000_000
+
+// How long a cached rate may still be served while the provider is unreachable
+const maxStaleAge = time.Hour
+
+// ErrUnsupportedCurrency is returned when the provider has no rate for a currency
+var ErrUnsupportedCurrency = errors.New("no exchange rate for currency")
+
+// Provider is a source of XMR prices. Providers are selected via config so the
+// public API can be swapped for fixed rates in tests or offline deployments.
+type Provider interface {
+	// Name identifies the provider in logs and in the rate source recorded on transactions
+	Name() string
+
+	// Rates returns the price of one XMR in each currency, keyed by upper-case currency code.
+	// Currencies the provider does not know are left out of the result.
+	Rates(ctx context.Context, currencies []string) (map[string]float64, error)
+}
+
+// Quote is the price of one XMR in a fiat currency at a point in time
+type Quote struct {
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Service caches provider quotes so creating an invoice does not hit the rate API every time
+type Service struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]Quote
+}
+
+func NewService(provider Provider, ttl time.Duration) *Service {
+	return &Service{provider: provider, ttl: ttl, cache: make(map[string]Quote)}
+}
+
+// Rate returns the quote for a single currency
+func (s *Service) Rate(ctx context.Context, currency string) (Quote, error) {
+	quotes, err := s.Rates(ctx, []string{currency})
+	if err != nil {
+		return Quote{}, err
+	}
+	return quotes[0], nil
+}
+
+// Rates returns a quote per currency in the requested order. Fresh quotes come from the
+// cache, the rest is fetched in a single provider call. When the provider fails a cached
+// quote younger than maxStaleAge is served instead.
+func (s *Service) Rates(ctx context.Context, currencies []string) ([]Quote, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	codes := make([]string, len(currencies))
+	for i, currency := range currencies {
+		codes[i] = strings.ToUpper(strings.TrimSpace(currency))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var missing []string
+	for _, code := range codes {
+		quote, ok := s.cache[code]
+		if !ok || now.Sub(quote.FetchedAt) > s.ttl {
+			missing = append(missing, code)
+		}
+	}
+
+	if len(missing) > 0 {
+		fetched, err := s.provider.Rates(ctx, missing)
+		if err != nil {
+			for _, code := range missing {
+				quote, ok := s.cache[code]
+				if !ok || now.Sub(quote.FetchedAt) > maxStaleAge {
+					return nil, fmt.Errorf("fetch %s rate from %s: %w", code, s.provider.Name(), err)
+				}
+			}
+		}
+		for code, rate := range fetched {
+			if rate > 0 {
+				s.cache[code] = Quote{Currency: code, Rate: rate, Source: s.provider.Name(), FetchedAt: now}
+			}
+		}
+	}
+
+	quotes := make([]Quote, len(codes))
+	for i, code := range codes {
+		quote, ok := s.cache[code]
+		if !ok {
+			return nil, fmt.Errorf("%w %s", ErrUnsupportedCurrency, code)
+		}
+		quotes[i] = quote
+	}
+	return quotes, nil
+}
+
+// ToAtomic converts a fiat amount to atomic XMR units at the given rate
+func ToAtomic(amountInCurrency float64, rate float64) int64 {
+	if rate <= 0 {
+		return 0
+	}
+	return int64(math.Round(amountInCurrency / rate * atomicUnitsPerXMR))
+}
+
+// ImpliedRate is the price of one XMR that turns the atomic amount into the fiat amount
+func ImpliedRate(amount int64, amountInCurrency float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	return amountInCurrency / (float64(amount) / atomicUnitsPerXMR)
+}
@@ -0,0 +1,99 @@
+package rates_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rates"
+)
+
+// flakyProvider counts calls and fails once down is set
+type flakyProvider struct {
+	calls atomic.Int32
+	down  atomic.Bool
+}
+
+func (p *flakyProvider) Name() string { return "flaky" }
+
+func (p *flakyProvider) Rates(ctx context.Context, currencies []string) (map[string]float64, error) {
+	p.calls.Add(1)
+	if p.down.Load() {
+		return nil, errors.New("provider down")
+	}
+	return map[string]float64{"EUR": 150}, nil
+}
+
+func TestServiceCachesRates(t *testing.T) {
+	provider := &flakyProvider{}
+	service := rates.NewService(provider, time.Hour)
+	ctx := context.Background()
+
+	quote, err := service.Rate(ctx, "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Currency != "EUR" || quote.Rate != 150 || quote.Source != "flaky" {
+		t.Fatalf("unexpected quote: %+v", quote)
+	}
+	if _, err := service.Rate(ctx, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Fatalf("expected one provider call, got %d", calls)
+	}
+
+	if _, err := service.Rate(ctx, "USD"); !errors.Is(err, rates.ErrUnsupportedCurrency) {
+		t.Fatalf("expected unsupported currency, got %v", err)
+	}
+}
+
+func TestServiceServesStaleRateWhileProviderIsDown(t *testing.T) {
+	provider := &flakyProvider{}
+	service := rates.NewService(provider, time.Nanosecond)
+	ctx := context.Background()
+
+	if _, err := service.Rate(ctx, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	provider.down.Store(true)
+	quote, err := service.Rate(ctx, "EUR")
+	if err != nil || quote.Rate != 150 {
+		t.Fatalf("expected the cached quote, got %+v, %v", quote, err)
+	}
+
+	if _, err := rates.NewService(provider, time.Hour).Rate(ctx, "EUR"); err == nil {
+		t.Fatal("expected an error without a cached quote")
+	}
+}
+
+func TestCryptoCompareProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/price" || r.URL.Query().Get("fsym") != "XMR" || r.URL.Query().Get("tsyms") != "EUR,CHF" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Write([]byte(`{"EUR":150.5,"CHF":140}`))
+	}))
+	defer server.Close()
+
+	result, err := rates.NewCryptoCompareProvider(server.URL).Rates(context.Background(), []string{"EUR", "CHF"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["EUR"] != 150.5 || result["CHF"] != 140 {
+		t.Fatalf("unexpected rates: %v", result)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	if got := rates.ToAtomic(75, 150); got != 500_000_000_000 {
+		t.Fatalf("ToAtomic: got %d", got)
+	}
+	if got := rates.ImpliedRate(500_000_000_000, 75); got != 150 {
+		t.Fatalf("ImpliedRate: got %g", got)
+	}
+}
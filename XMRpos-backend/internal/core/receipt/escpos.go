@@ -0,0 +1,99 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ESC/POS commands understood by the MJ-Q50 printer
+var (
+	escInit       = []byte{0x1b, 0x40}       // ESC @, reset
+	escCodePage   = []byte{0x1b, 0x74, 0x10} // ESC t 16, WPC1252 like the app's default charset
+	escAlignLeft  = []byte{0x1b, 0x61, 0x00} // ESC a 0
+	escAlignCtr   = []byte{0x1b, 0x61, 0x01} // ESC a 1
+	escBoldOn     = []byte{0x1b, 0x45, 0x01} // ESC E 1
+	escBoldOff    = []byte{0x1b, 0x45, 0x00} // ESC E 0
+	escSizeNormal = []byte{0x1d, 0x21, 0x00} // GS ! 0
+	escSizeLarge  = []byte{0x1d, 0x21, 0x01} // GS ! 1, double height
+	escFeed       = []byte{0x1b, 0x64, 0x04} // ESC d 4, feed the receipt past the cutter
+	escCut        = []byte{0x1d, 0x56, 0x42, 0x00}
+)
+
+// QR codes are printed with 6 dot modules, a transaction hash needs 37 of them: 222 of the 384 dots
+const (
+	qrModuleSize   = 6
+	qrErrorLevelM  = 0x31
+	qrModel2       = 0x32
+	maxQRDataBytes = 7089
+)
+
+// escpos encodes the layout for the printer. Text is sent in code page 1252, characters it
+// lacks are printed as ?
+func escpos(lines []line) ([]byte, error) {
+	encoder := encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder())
+
+	var buf bytes.Buffer
+	buf.Write(escInit)
+	buf.Write(escCodePage)
+	for _, l := range lines {
+		if l.qr != "" {
+			if err := writeQRCode(&buf, l.qr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if l.center {
+			buf.Write(escAlignCtr)
+		} else {
+			buf.Write(escAlignLeft)
+		}
+		if l.bold {
+			buf.Write(escBoldOn)
+		}
+		if l.large {
+			buf.Write(escSizeLarge)
+		}
+		encoded, err := encoder.String(l.text)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(encoded)
+		buf.WriteByte('\n')
+		if l.large {
+			buf.Write(escSizeNormal)
+		}
+		if l.bold {
+			buf.Write(escBoldOff)
+		}
+	}
+	buf.Write(escAlignLeft)
+	buf.Write(escFeed)
+	buf.Write(escCut)
+	return buf.Bytes(), nil
+}
+
+// writeQRCode stores data in the printer's symbol buffer and prints it centered (GS ( k)
+func writeQRCode(buf *bytes.Buffer, data string) error {
+	if len(data) > maxQRDataBytes {
+		return fmt.Errorf("QR code data of %d bytes is too long", len(data))
+	}
+	qrCommand := func(fn byte, params ...byte) {
+		size := len(params) + 2
+		buf.Write([]byte{0x1d, 0x28, 0x6b, byte(size), byte(size >> 8), 0x31, fn})
+		buf.Write(params)
+	}
+
+	buf.Write(escAlignCtr)
+	qrCommand(0x41, qrModel2, 0x00)                   // Select the model
+	qrCommand(0x43, qrModuleSize)                     // Module size in dots
+	qrCommand(0x45, qrErrorLevelM)                    // Error correction level
+	qrCommand(0x50, append([]byte{0x30}, data...)...) // Store the data
+	qrCommand(0x51, 0x30)                             // Print the stored symbol
+	buf.WriteByte('\n')
+	buf.Write(escAlignLeft)
+	return nil
+}
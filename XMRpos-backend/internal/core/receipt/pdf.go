@@ -0,0 +1,126 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// The PDF is one page as wide as the paper roll and as long as the receipt, in points
+const (
+	pdfPageWidth    = 164.4 // 58 mm
+	pdfMargin       = 14.2  // (58 mm - 48 mm) / 2
+	pdfFontSize     = 7.0   // 32 Courier characters of 0.6 em fill the 48 mm
+	pdfLineHeight   = 9.0
+	pdfLargeHeight  = 16.0
+	pdfQRModuleSize = 2.13 // A 6 dot module at 203 dpi
+)
+
+// pdf draws the layout in Courier, QR codes as one filled square per dark module
+func pdf(lines []line) ([]byte, error) {
+	encoder := encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder())
+	charWidth := pdfFontSize * 0.6
+
+	qrCodes := make(map[int][][]bool)
+	height := 2 * pdfMargin
+	for i, l := range lines {
+		switch {
+		case l.qr != "":
+			code, err := qrcode.New(l.qr, qrcode.Medium)
+			if err != nil {
+				return nil, err
+			}
+			qrCodes[i] = code.Bitmap()
+			height += float64(len(qrCodes[i])) * pdfQRModuleSize
+		case l.large:
+			height += pdfLargeHeight
+		default:
+			height += pdfLineHeight
+		}
+	}
+
+	var content bytes.Buffer
+	y := height - pdfMargin
+	for i, l := range lines {
+		if bitmap, ok := qrCodes[i]; ok {
+			size := float64(len(bitmap)) * pdfQRModuleSize
+			left := (pdfPageWidth - size) / 2
+			for row, modules := range bitmap {
+				for col, dark := range modules {
+					if dark {
+						fmt.Fprintf(&content, "%.2f %.2f %.2f %.2f re\n",
+							left+float64(col)*pdfQRModuleSize, y-float64(row+1)*pdfQRModuleSize, pdfQRModuleSize, pdfQRModuleSize)
+					}
+				}
+			}
+			content.WriteString("f\n")
+			y -= size
+			continue
+		}
+
+		lineHeight, scaleY := pdfLineHeight, pdfFontSize
+		if l.large {
+			lineHeight, scaleY = pdfLargeHeight, 2*pdfFontSize
+		}
+		y -= lineHeight
+		x := pdfMargin
+		if l.center {
+			x += float64(Columns-utf8.RuneCountInString(l.text)) * charWidth / 2
+		}
+		font := "F1"
+		if l.bold {
+			font = "F2"
+		}
+		encoded, err := encoder.String(l.text)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&content, "BT /%s 1 Tf %.2f 0 0 %.2f %.2f %.2f Tm (%s) Tj ET\n", font, pdfFontSize, scaleY, x, y+2, pdfString(encoded))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pdfPageWidth, height),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes(), nil
+}
+
+// pdfString escapes a code page 1252 string for a PDF literal, bytes outside ASCII as octal
+func pdfString(s string) string {
+	var sb bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&sb, "\\%03o", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,303 @@
+// Package receipt lays out the receipt of a paid transaction and renders it for the MJ-Q50
+// thermal printer (ESC/POS), as PDF or as plain text, so every reprint looks the same.
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+const (
+	FormatESCPOS = "escpos"
+	FormatPDF    = "pdf"
+	FormatText   = "txt"
+)
+
+// The MJ-Q50 prints on 58 mm paper, 48 mm of it are printable: 384 dots at 203 dpi,
+// or 32 characters of the 12x24 dot font
+const (
+	Columns   = 32
+	PrintDots = 384
+)
+
+const moneroAtomicUnitsPerXMR int64 = 1_000_000_000_000
+
+var ErrInvalidFormat = errors.New("invalid receipt format")
+
+// Receipt holds what is printed for one transaction
+type Receipt struct {
+	VendorName       string
+	TransactionID    uint
+	OrderID          string
+	Time             time.Time
+	Currency         string // Fiat currency the sale was priced in, empty or XMR for sales in XMR
+	AmountInCurrency float64
+	Amount           int64 // Atomic units
+	ExchangeRate     float64
+	TipAmount        int64
+	TipInCurrency    float64
+	AmountRefunded   int64
+	TaxIncluded      bool
+	LineItems        []*models.LineItem
+	TxHashes         []string
+}
+
+// New builds the receipt of a transaction, the hashes are those of the payments it received
+func New(transaction *models.Transaction, vendorName string) *Receipt {
+	r := &Receipt{
+		VendorName:       vendorName,
+		TransactionID:    transaction.ID,
+		Time:             transaction.CreatedAt.UTC(),
+		Currency:         strings.ToUpper(transaction.Currency),
+		AmountInCurrency: transaction.AmountInCurrency,
+		Amount:           transaction.Amount,
+		ExchangeRate:     transaction.ExchangeRate,
+		TipAmount:        transaction.TipAmount,
+		TipInCurrency:    transaction.TipInCurrency,
+		AmountRefunded:   transaction.AmountRefunded,
+		TaxIncluded:      transaction.TaxIncluded,
+		LineItems:        transaction.LineItems,
+	}
+	if transaction.OrderID != nil {
+		r.OrderID = *transaction.OrderID
+	}
+	for _, sub := range transaction.SubTransactions {
+		if sub.TxHash != "" && !sub.Reversed {
+			r.TxHashes = append(r.TxHashes, sub.TxHash)
+		}
+	}
+	return r
+}
+
+// ParseFormat reads the format query parameter, escpos by default
+func ParseFormat(query url.Values) (string, error) {
+	switch format := strings.ToLower(query.Get("format")); format {
+	case "":
+		return FormatESCPOS, nil
+	case FormatESCPOS, FormatPDF, FormatText:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: format must be escpos, pdf or txt", ErrInvalidFormat)
+}
+
+// Render returns the receipt in the format with its content type
+func Render(r *Receipt, format string) ([]byte, string, error) {
+	switch format {
+	case FormatESCPOS:
+		data, err := escpos(r.layout())
+		return data, "application/vnd.escpos", err
+	case FormatPDF:
+		data, err := pdf(r.layout())
+		return data, "application/pdf", err
+	case FormatText:
+		return []byte(text(r.layout())), "text/plain; charset=utf-8", nil
+	}
+	return nil, "", ErrInvalidFormat
+}
+
+// line is one printed line of the layout, or a QR code when qr is set
+type line struct {
+	text   string
+	center bool
+	bold   bool
+	large  bool // Double height
+	qr     string
+}
+
+func (r *Receipt) inXMR() bool {
+	return r.Currency == "" || r.Currency == "XMR"
+}
+
+// money formats an amount in the currency of the sale
+func (r *Receipt) money(amount float64) string {
+	if r.inXMR() {
+		return formatXMR(int64(math.Round(amount * float64(moneroAtomicUnitsPerXMR))))
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// layout is shared by all formats, text lines are at most Columns characters wide
+func (r *Receipt) layout() []line {
+	separator := line{text: strings.Repeat("-", Columns)}
+	var lines []line
+
+	for _, text := range wrap(r.VendorName, Columns) {
+		lines = append(lines, line{text: text, center: true, bold: true, large: true})
+	}
+	lines = append(lines, separator)
+	lines = append(lines, line{text: row("Date:", r.Time.Format("2006-01-02"))})
+	lines = append(lines, line{text: row("Time:", r.Time.Format("15:04:05")+" UTC")})
+	lines = append(lines, line{text: row("Receipt:", fmt.Sprintf("#%d", r.TransactionID))})
+	if r.OrderID != "" {
+		lines = append(lines, rows("Order:", r.OrderID)...)
+	}
+	lines = append(lines, separator)
+	lines = append(lines, line{text: "PURCHASE", center: true, bold: true})
+
+	if len(r.LineItems) > 0 {
+		var tax float64
+		for _, item := range r.LineItems {
+			lines = append(lines, rows(fmt.Sprintf("%g x %s", item.Quantity, item.Name), r.money(item.Total))...)
+			detail := "  @ " + r.money(item.UnitPrice)
+			if item.Discount > 0 {
+				detail += " -" + r.money(item.Discount)
+			}
+			if item.TaxRate > 0 {
+				detail += fmt.Sprintf(" tax %g%%", item.TaxRate)
+			}
+			if item.Quantity != 1 || item.Discount > 0 || item.TaxRate > 0 {
+				lines = append(lines, line{text: truncate(detail, Columns)})
+			}
+			tax += item.Tax
+		}
+		lines = append(lines, separator)
+		taxLabel := "Tax"
+		if r.TaxIncluded {
+			taxLabel = "Incl. tax"
+		}
+		lines = append(lines, line{text: row(taxLabel, r.money(tax))})
+	}
+
+	if r.inXMR() {
+		if r.TipAmount > 0 {
+			lines = append(lines, line{text: row("Subtotal XMR", formatXMR(r.Amount-r.TipAmount))})
+			lines = append(lines, line{text: row("Tip XMR", formatXMR(r.TipAmount))})
+		}
+		lines = append(lines, line{text: row("TOTAL XMR", formatXMR(r.Amount)), bold: true})
+	} else {
+		if r.TipInCurrency > 0 {
+			lines = append(lines, line{text: row("Subtotal "+r.Currency, r.money(r.AmountInCurrency-r.TipInCurrency))})
+			lines = append(lines, line{text: row("Tip "+r.Currency, r.money(r.TipInCurrency))})
+		}
+		lines = append(lines, line{text: row("TOTAL "+r.Currency, r.money(r.AmountInCurrency)), bold: true})
+		lines = append(lines, line{text: row("Paid XMR", formatXMR(r.Amount))})
+		if r.ExchangeRate > 0 {
+			lines = append(lines, line{text: row("Rate", fmt.Sprintf("%.2f %s/XMR", r.ExchangeRate, r.Currency))})
+		}
+	}
+	if r.AmountRefunded > 0 {
+		lines = append(lines, line{text: row("Refunded XMR", formatXMR(r.AmountRefunded))})
+	}
+
+	for _, hash := range r.TxHashes {
+		lines = append(lines, separator)
+		lines = append(lines, line{text: "TXID:"})
+		for _, text := range wrap(hash, Columns) {
+			lines = append(lines, line{text: text})
+		}
+		lines = append(lines, line{qr: hash})
+	}
+	lines = append(lines, separator)
+	lines = append(lines, line{text: "Thank you!", center: true})
+	lines = append(lines, line{text: "Paid with Monero", center: true})
+	return lines
+}
+
+// formatXMR prints atomic units with up to 12 decimals, at least 2
+func formatXMR(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	s := fmt.Sprintf("%s%d.%012d", sign, amount/moneroAtomicUnitsPerXMR, amount%moneroAtomicUnitsPerXMR)
+	for strings.HasSuffix(s, "0") && len(s)-strings.IndexByte(s, '.') > 3 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// row puts left and right on one line, the right part aligned to the edge
+func row(left, right string) string {
+	gap := Columns - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		gap = 1
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// rows is row for a left part that may not fit, it wraps and puts right on the last line
+func rows(left, right string) []line {
+	if utf8.RuneCountInString(left)+utf8.RuneCountInString(right)+1 <= Columns {
+		return []line{{text: row(left, right)}}
+	}
+	wrapped := wrap(left, Columns)
+	out := make([]line, 0, len(wrapped)+1)
+	for _, text := range wrapped {
+		out = append(out, line{text: text})
+	}
+	last := wrapped[len(wrapped)-1]
+	if utf8.RuneCountInString(last)+utf8.RuneCountInString(right)+1 <= Columns {
+		out[len(out)-1].text = row(last, right)
+	} else {
+		out = append(out, line{text: row("", right)})
+	}
+	return out
+}
+
+// wrap breaks text at spaces into lines of at most width characters, longer words are split
+func wrap(text string, width int) []string {
+	var out []string
+	var current []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > 0 {
+			space := 0
+			if len(current) > 0 {
+				space = 1
+			}
+			if len(current)+space+len(runes) <= width {
+				if space == 1 {
+					current = append(current, ' ')
+				}
+				current = append(current, runes...)
+				break
+			}
+			if len(current) > 0 {
+				out = append(out, string(current))
+				current = nil
+				continue
+			}
+			out = append(out, string(runes[:width]))
+			runes = runes[width:]
+		}
+	}
+	if len(current) > 0 || len(out) == 0 {
+		out = append(out, string(current))
+	}
+	return out
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width])
+}
+
+// text renders the layout as plain text, QR codes are left out since the hash is printed above them
+func text(lines []line) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		if l.qr != "" {
+			continue
+		}
+		content := l.text
+		if l.center {
+			if pad := (Columns - utf8.RuneCountInString(content)) / 2; pad > 0 {
+				content = strings.Repeat(" ", pad) + content
+			}
+		}
+		sb.WriteString(strings.TrimRight(content, " "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
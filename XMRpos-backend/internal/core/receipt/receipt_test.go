@@ -0,0 +1,109 @@
+package receipt_test
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/receipt"
+	"gorm.io/gorm"
+)
+
+var txHash = strings.Repeat("ab", 32)
+
+func paidTransaction() *models.Transaction {
+	return &models.Transaction{
+		Model:            gorm.Model{ID: 42, CreatedAt: time.Date(2024, 3, 1, 14, 3, 22, 0, time.UTC)},
+		Amount:           100_000_000_000,
+		Currency:         "EUR",
+		AmountInCurrency: 17,
+		ExchangeRate:     170,
+		TipAmount:        11_764_705_882,
+		TipInCurrency:    2,
+		LineItems: []*models.LineItem{
+			{Name: "Café crème with a very long name for the paper", Quantity: 2, UnitPrice: 5, TaxRate: 19, Tax: 1.9, Total: 11.9},
+			{Name: "Cake", Quantity: 1, UnitPrice: 3.1, Total: 3.1},
+		},
+		SubTransactions: []*models.SubTransaction{{TxHash: txHash}},
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	data, contentType, err := receipt.Render(receipt.New(paidTransaction(), "Bäckerei €uro"), receipt.FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+	text := string(data)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if utf8.RuneCountInString(line) > receipt.Columns {
+			t.Fatalf("line wider than the paper: %q", line)
+		}
+	}
+	for _, want := range []string{"Bäckerei €uro", "Receipt:", "#42", "2 x Café crème", "Tip EUR", "2.00", "TOTAL EUR", "17.00", "Paid XMR", "0.10", "170.00 EUR/XMR", txHash[:32], txHash[32:]} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("receipt misses %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestESCPOS(t *testing.T) {
+	data, _, err := receipt.Render(receipt.New(paidTransaction(), "€uro"), receipt.FormatESCPOS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0x1b, 0x40, 0x1b, 0x74, 0x10}) {
+		t.Fatalf("missing reset and code page: % x", data[:8])
+	}
+	if !bytes.HasSuffix(data, []byte{0x1d, 0x56, 0x42, 0x00}) {
+		t.Fatal("receipt is not cut")
+	}
+	// The euro sign is 0x80 in code page 1252
+	if !bytes.Contains(data, []byte{0x80, 'u', 'r', 'o', '\n'}) {
+		t.Fatal("vendor name not encoded in code page 1252")
+	}
+	store := append([]byte{0x1d, 0x28, 0x6b, byte(len(txHash) + 3), 0x00, 0x31, 0x50, 0x30}, txHash...)
+	if !bytes.Contains(data, store) {
+		t.Fatal("missing QR code of the transaction hash")
+	}
+	if !bytes.Contains(data, []byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x51, 0x30}) {
+		t.Fatal("QR code is not printed")
+	}
+}
+
+func TestPDF(t *testing.T) {
+	data, contentType, err := receipt.Render(receipt.New(paidTransaction(), "Shop (main)"), receipt.FormatPDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/pdf" || !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q", data[:16])
+	}
+	if !bytes.Contains(data, []byte(`(Shop \(main\)) Tj`)) {
+		t.Fatal("vendor name is not escaped")
+	}
+	trailer := data[bytes.LastIndex(data, []byte("startxref\n"))+len("startxref\n"):]
+	offset, err := strconv.Atoi(string(trailer[:bytes.IndexByte(trailer, '\n')]))
+	if err != nil || !bytes.HasPrefix(data[offset:], []byte("xref\n")) {
+		t.Fatalf("startxref does not point at the cross-reference table: %v", err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for query, want := range map[string]string{"": receipt.FormatESCPOS, "format=PDF": receipt.FormatPDF, "format=txt": receipt.FormatText} {
+		values, _ := url.ParseQuery(query)
+		if got, err := receipt.ParseFormat(values); err != nil || got != want {
+			t.Fatalf("%q: got %q, %v, want %q", query, got, err, want)
+		}
+	}
+	if _, err := receipt.ParseFormat(url.Values{"format": {"html"}}); err == nil {
+		t.Fatal("accepted an unknown format")
+	}
+}
@@ -44,6 +44,16 @@ type rpcResponse struct {
 	} `json:"error,omitempty"`
 }
 
+// Error is a JSON-RPC error the wallet answered with. The request reached the wallet and was refused.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
 // Call sends a JSON-RPC request and unmarshals the result into the provided result pointer.
 func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
 	reqBody, err := json.Marshal(rpcRequest{
@@ -88,7 +98,7 @@ func (c *Client) Call(ctx context.Context, method string, params interface{}, re
 
 	if rpcResp.Error != nil {
 		log.Printf("[RPC] RPC error: %d %s", rpcResp.Error.Code, rpcResp.Error.Message)
-		return fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return &Error{Code: rpcResp.Error.Code, Message: rpcResp.Error.Message}
 	}
 	if result != nil && rpcResp.Result != nil {
 		if err := json.Unmarshal(*rpcResp.Result, result); err != nil {
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/apikey"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/auth"
+)
+
+// How stale the last-used time of a key may get, so busy integrations do not write on every request
+const apiKeyLastUsedResolution = time.Minute
+
+// authenticateAPIKey resolves a vendor API key to vendor claims carrying the key's scopes.
+// Routes registered without scopes do not take API keys at all.
+func authenticateAPIKey(ctx context.Context, repo auth.AuthRepository, key string, scopes []string) (*models.Claims, *models.HTTPError) {
+	if len(scopes) == 0 {
+		return nil, models.NewHTTPError(http.StatusForbidden, "API keys cannot be used for this endpoint")
+	}
+
+	apiKey, err := repo.FindAPIKeyByHash(ctx, apikey.Hash(key))
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+	vendor, err := repo.FindVendorByID(ctx, apiKey.VendorID)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusUnauthorized, "Vendor not found")
+	}
+
+	granted := strings.Split(apiKey.Scopes, ",")
+	if !slices.ContainsFunc(scopes, func(scope string) bool { return slices.Contains(granted, scope) }) {
+		return nil, models.NewHTTPError(http.StatusForbidden, "API key lacks the "+strings.Join(scopes, " or ")+" scope")
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyLastUsedResolution {
+		// Tracking is best effort, a failed write must not fail the request
+		_ = repo.UpdateAPIKeyLastUsed(ctx, apiKey.ID, now)
+	}
+
+	return &models.Claims{
+		VendorID:        &vendor.ID,
+		Role:            "vendor",
+		PasswordVersion: vendor.PasswordVersion,
+		Scopes:          granted,
+	}, nil
+}
@@ -11,12 +11,15 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/apikey"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/auth"
 )
 
 type contextKey string
 
-func AuthMiddleware(cfg *config.Config, repo auth.AuthRepository) func(next http.Handler) http.Handler {
+// AuthMiddleware accepts bearer JWTs. Vendor API keys are accepted instead when scopes are
+// given, the key has to hold one of them.
+func AuthMiddleware(cfg *config.Config, repo auth.AuthRepository, scopes ...string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -35,6 +38,16 @@ func AuthMiddleware(cfg *config.Config, repo auth.AuthRepository) func(next http
 				return
 			}
 
+			if strings.HasPrefix(tokenString, apikey.KeyPrefix) {
+				claims, httpErr := authenticateAPIKey(authCtx, repo, tokenString, scopes)
+				if httpErr != nil {
+					http.Error(w, httpErr.Message, httpErr.Code)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(AddClaimsToContext(r.Context(), claims)))
+				return
+			}
+
 			claims := &models.Claims{}
 			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
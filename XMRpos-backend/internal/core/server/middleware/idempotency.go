@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/idempotency"
+)
+
+// IdempotencyKeyHeader is sent by clients that may retry a request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response that was stored for an earlier request with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// Idempotency answers a retried mutating request with the response of the first one when both
+// carry the same Idempotency-Key. It runs after AuthMiddleware since keys are scoped to the caller.
+func Idempotency(service *idempotency.IdempotencyService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scope, ok := callerScope(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, replay, httpErr := service.Begin(r.Context(), scope, key, requestHash(r, body))
+			if httpErr != nil {
+				http.Error(w, httpErr.Message, httpErr.Code)
+				return
+			}
+			if replay {
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(record.StatusCode)
+				_, _ = w.Write(record.ResponseBody)
+				return
+			}
+
+			// The outcome is stored even when the client gave up waiting for it
+			storeCtx := context.WithoutCancel(r.Context())
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				if p := recover(); p != nil {
+					release(storeCtx, service, record)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				release(storeCtx, service, record)
+				return
+			}
+			contentType, body := recorder.Header().Get("Content-Type"), recorder.body.Bytes()
+			if recorder.secret {
+				contentType, body = "", nil
+			}
+			ctx, cancel := context.WithTimeout(storeCtx, 5*time.Second)
+			defer cancel()
+			if err := service.Complete(ctx, record, recorder.status, contentType, body); err != nil {
+				log.Printf("idempotency: storing the response for key %q failed: %v", key, err)
+			}
+		})
+	}
+}
+
+// SecretResponse marks a route whose response carries credentials, such as tokens or a key shown only
+// once. Idempotency then stores just the status code and a retry gets it back without a body.
+func SecretResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorder, ok := w.(*responseRecorder); ok {
+			recorder.secret = true
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func release(ctx context.Context, service *idempotency.IdempotencyService, record *models.IdempotencyKey) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := service.Release(ctx, record); err != nil {
+		log.Printf("idempotency: releasing key %q failed: %v", record.Key, err)
+	}
+}
+
+// callerScope names the authenticated caller, the same key sent by different callers never collides
+func callerScope(ctx context.Context) (string, bool) {
+	role, ok := utils.GetClaimFromContext(ctx, models.ClaimsRoleKey)
+	if !ok {
+		return "", false
+	}
+	vendorID, _ := ctx.Value(models.ClaimsVendorIDKey).(*uint)
+	posID, _ := ctx.Value(models.ClaimsPosIDKey).(*uint)
+
+	switch role {
+	case "admin":
+		return "admin", true
+	case "vendor":
+		if vendorID == nil {
+			return "", false
+		}
+		return fmt.Sprintf("vendor:%d", *vendorID), true
+	case "pos":
+		if vendorID == nil || posID == nil {
+			return "", false
+		}
+		return fmt.Sprintf("pos:%d:%d", *vendorID, *posID), true
+	default:
+		return "", false
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes the response through and keeps a copy to store
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	// secret is set by SecretResponse, the body is passed through but not stored
+	secret bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
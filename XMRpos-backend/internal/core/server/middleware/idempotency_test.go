@@ -0,0 +1,85 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestIdempotencyKeys(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	sale := map[string]any{"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0}
+	withKey := func(key string) http.Header { return http.Header{"Idempotency-Key": {key}} }
+
+	// A retried sale returns the first transaction instead of creating a second one
+	code, header, first := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, sale, withKey("sale-1"))
+	if code != http.StatusOK || header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: status %d: %s", code, first)
+	}
+	code, header, retry := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, sale, withKey("sale-1"))
+	if code != http.StatusOK || header.Get("Idempotent-Replayed") != "true" || !bytes.Equal(first, retry) {
+		t.Fatalf("retry: status %d, replayed %q: %s", code, header.Get("Idempotent-Replayed"), retry)
+	}
+	if receives := env.MoneroPay.Receives(); len(receives) != 1 {
+		t.Fatalf("expected one MoneroPay receive, got %d", len(receives))
+	}
+
+	// The key cannot be reused for another request, but other callers have their own keys
+	other := map[string]any{"amount": 2 * oneXMR, "amount_in_currency": 300.0, "currency": "EUR", "required_confirmations": 0}
+	if code, _, _ := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, other, withKey("sale-1")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key: got status %d, want 422", code)
+	}
+	invoice := map[string]any{"order_id": "o-1", "amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "redirect_url": "https://shop.example"}
+	if code, header, _ := env.DoWithHeaders(t, http.MethodPost, "/vendor/invoices", vendorToken, invoice, withKey("sale-1")); code != http.StatusOK || header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("same key from another caller: status %d", code)
+	}
+	if code, _, _ := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, sale, withKey(strings.Repeat("k", 256))); code != http.StatusBadRequest {
+		t.Fatalf("oversized key: got status %d, want 400", code)
+	}
+
+	// A retried payout does not start a second transfer
+	env.Store.AddTransaction(models.Transaction{VendorID: env.Vendor.ID, PosID: &env.Pos.ID, Amount: oneXMR, Currency: "EUR", Accepted: true, Confirmed: true})
+	for i := 0; i < 2; i++ {
+		if code, _, body := env.DoWithHeaders(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil, withKey("payout-1")); code != http.StatusOK {
+			t.Fatalf("payout %d: status %d: %s", i, code, body)
+		}
+	}
+	if transfers := env.Store.Transfers(); len(transfers) != 1 {
+		t.Fatalf("expected one transfer, got %d", len(transfers))
+	}
+
+	// Server errors release the key so the request can be tried again
+	env.MoneroPay.FailReceives(true)
+	if code, _, _ := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, sale, withKey("sale-2")); code != http.StatusInternalServerError {
+		t.Fatalf("failing backend: got status %d, want 500", code)
+	}
+	env.MoneroPay.FailReceives(false)
+	if code, header, _ := env.DoWithHeaders(t, http.MethodPost, "/pos/create-transaction", posToken, sale, withKey("sale-2")); code != http.StatusOK || header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after server error: status %d", code)
+	}
+
+	// A response carrying credentials is not stored, a retry only gets the status code back
+	apiKey := map[string]any{"name": "shop", "scopes": []string{models.APIKeyScopeInvoicesWrite}}
+	code, _, created := env.DoWithHeaders(t, http.MethodPost, "/vendor/api-keys", vendorToken, apiKey, withKey("api-key-1"))
+	if code != http.StatusOK || !strings.Contains(string(created), `"key":"`) {
+		t.Fatalf("creating an API key: status %d: %s", code, created)
+	}
+	code, header, replayed := env.DoWithHeaders(t, http.MethodPost, "/vendor/api-keys", vendorToken, apiKey, withKey("api-key-1"))
+	if code != http.StatusOK || header.Get("Idempotent-Replayed") != "true" || len(replayed) != 0 {
+		t.Fatalf("retried API key creation: status %d, replayed %q: %s", code, header.Get("Idempotent-Replayed"), replayed)
+	}
+	for _, key := range env.Store.IdempotencyKeys() {
+		if key.Key == "api-key-1" && (len(key.ResponseBody) != 0 || key.ContentType != "") {
+			t.Fatalf("API key response stored: %s", key.ResponseBody)
+		}
+	}
+}
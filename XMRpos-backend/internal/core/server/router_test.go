@@ -0,0 +1,478 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/server"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestLogin(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	if token := env.LoginVendor(t); token == "" {
+		t.Fatal("expected vendor access token")
+	}
+	if token := env.LoginPos(t); token == "" {
+		t.Fatal("expected pos access token")
+	}
+
+	var admin testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-admin", "", map[string]any{"name": "admin", "password": "admin-password"}, &admin)
+	if admin.AccessToken == "" {
+		t.Fatal("expected admin access token")
+	}
+
+	status, _ := env.Do(t, http.MethodPost, "/auth/login-vendor", "", map[string]any{"name": env.Vendor.Name, "password": "wrong-password"})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("wrong vendor password: got status %d, want 401", status)
+	}
+
+	status, _ = env.Do(t, http.MethodPost, "/auth/login-pos", "", map[string]any{"vendor_id": env.Vendor.ID + 100, "name": env.Pos.Name, "password": testutil.PosPassword})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("pos login for other vendor: got status %d, want 401", status)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	env := testutil.NewEnv(t)
+	vendorToken := env.LoginVendor(t)
+	posToken := env.LoginPos(t)
+
+	cases := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "missing header", header: "", want: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Token " + vendorToken, want: http.StatusUnauthorized},
+		{name: "garbage token", header: "Bearer not-a-jwt", want: http.StatusUnauthorized},
+		{name: "valid vendor token", header: "Bearer " + vendorToken, want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, env.Server.URL+"/vendor/balance", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+
+	// A POS token must not be accepted on vendor routes
+	if status, _ := env.Do(t, http.MethodGet, "/vendor/balance", posToken, nil); status != http.StatusUnauthorized {
+		t.Fatalf("pos token on vendor route: got status %d, want 401", status)
+	}
+
+	// Changing the password invalidates previously issued tokens
+	env.Store.BumpVendorPasswordVersion(env.Vendor.ID)
+	if status, _ := env.Do(t, http.MethodGet, "/vendor/balance", vendorToken, nil); status != http.StatusUnauthorized {
+		t.Fatalf("outdated token: got status %d, want 401", status)
+	}
+}
+
+func TestTransactionCallbackFlow(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount":                 oneXMR,
+		"description":            "coffee",
+		"amount_in_currency":     150.0,
+		"currency":               "EUR",
+		"required_confirmations": 0,
+	}, &created)
+
+	receives := env.MoneroPay.Receives()
+	if len(receives) != 1 {
+		t.Fatalf("expected one MoneroPay receive, got %d", len(receives))
+	}
+	if receives[0].Address != created.Address {
+		t.Fatalf("address mismatch: api=%s moneropay=%s", created.Address, receives[0].Address)
+	}
+	if receives[0].Request.Amount != oneXMR || receives[0].Request.Description != "coffee" {
+		t.Fatalf("unexpected receive request: %+v", receives[0].Request)
+	}
+	jwt := testutil.CallbackJWT(t, receives[0])
+
+	if code := env.SendCallback(t, "invalid-token", moneropay.ReceiveAddressResponse{}, testutil.Payment("bad", oneXMR, 0)); code == http.StatusOK {
+		t.Fatal("callback with invalid token was accepted")
+	}
+
+	// Half the amount in the mempool: not accepted yet
+	first := testutil.Payment("hash-1", oneXMR/2, 0)
+	status := env.MoneroPay.SetPayments(created.Address, first)
+	if code := env.SendCallback(t, jwt, status, first); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+	tx, _ := env.Store.Transaction(created.ID)
+	if tx.Accepted || tx.Confirmed {
+		t.Fatalf("partial payment should not be accepted: %+v", tx)
+	}
+
+	// Remainder arrives: accepted with 0 required confirmations, but not confirmed
+	second := testutil.Payment("hash-2", oneXMR/2, 0)
+	status = env.MoneroPay.SetPayments(created.Address, first, second)
+	if code := env.SendCallback(t, jwt, status, second); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+	var fetched models.Transaction
+	env.MustDo(t, http.MethodGet, fmt.Sprintf("/pos/transaction/%d", created.ID), posToken, nil, &fetched)
+	if !fetched.Accepted || fetched.Confirmed {
+		t.Fatalf("expected accepted and unconfirmed, got accepted=%t confirmed=%t", fetched.Accepted, fetched.Confirmed)
+	}
+	if len(fetched.SubTransactions) != 2 {
+		t.Fatalf("expected 2 subtransactions, got %d", len(fetched.SubTransactions))
+	}
+
+	var balance struct {
+		Balance int64 `json:"balance"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != 0 {
+		t.Fatalf("unconfirmed payment counted in balance: %d", balance.Balance)
+	}
+
+	// Both transfers unlock: confirmed, and the existing subtransactions are updated in place
+	first, second = testutil.Payment("hash-1", oneXMR/2, 10), testutil.Payment("hash-2", oneXMR/2, 12)
+	status = env.MoneroPay.SetPayments(created.Address, first, second)
+	if code := env.SendCallback(t, jwt, status, second); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+	tx, _ = env.Store.Transaction(created.ID)
+	if !tx.Accepted || !tx.Confirmed {
+		t.Fatalf("expected confirmed transaction, got %+v", tx)
+	}
+	if len(tx.SubTransactions) != 2 || tx.SubTransactions[1].Confirmations != 12 {
+		t.Fatalf("subtransactions not updated: %+v", tx.SubTransactions)
+	}
+
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != oneXMR {
+		t.Fatalf("balance: got %d, want %d", balance.Balance, oneXMR)
+	}
+
+	// A repeated callback must not credit the vendor twice
+	if code := env.SendCallback(t, jwt, status, second); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != oneXMR {
+		t.Fatalf("balance after repeated callback: got %d, want %d", balance.Balance, oneXMR)
+	}
+
+	// Another POS of the same vendor must not see the transaction
+	other := env.Store.AddPos(env.Vendor.ID, "till-2", testutil.PosPassword)
+	var otherTokens testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-pos", "", map[string]any{"vendor_id": env.Vendor.ID, "name": other.Name, "password": testutil.PosPassword}, &otherTokens)
+	if code, _ := env.Do(t, http.MethodGet, fmt.Sprintf("/pos/transaction/%d", created.ID), otherTokens.AccessToken, nil); code != http.StatusForbidden {
+		t.Fatalf("other pos: got status %d, want 403", code)
+	}
+}
+
+func TestConfirmationCheckerPollsMoneroPay(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.ConfirmationCheckInterval = 20 * time.Millisecond
+	})
+
+	posToken := env.LoginPos(t)
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 1,
+	}, &created)
+
+	env.MoneroPay.SetPayments(created.Address, testutil.Payment("polled", oneXMR, 10))
+	testutil.WaitFor(t, "confirmation checker", func() bool {
+		tx, _ := env.Store.Transaction(created.ID)
+		return tx.Accepted && tx.Confirmed
+	})
+}
+
+func TestWalletRPCPaymentBackend(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.PaymentBackend = config.PaymentBackendWalletRPC
+		cfg.ConfirmationCheckInterval = 20 * time.Millisecond
+	})
+
+	posToken := env.LoginPos(t)
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR, "description": "bread", "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+
+	if len(env.MoneroPay.Receives()) != 0 {
+		t.Fatal("MoneroPay must not be used in wallet RPC mode")
+	}
+	calls := env.Wallet.Calls("create_address")
+	if len(calls) != 1 || !strings.Contains(string(calls[0].Params), `"label":"bread"`) {
+		t.Fatalf("unexpected create_address calls: %+v", calls)
+	}
+
+	// In the mempool: accepted with 0 required confirmations
+	env.Wallet.SetPayments(created.Address, testutil.Payment("pool-tx", oneXMR, 0))
+	testutil.WaitFor(t, "payment in the pool", func() bool {
+		tx, _ := env.Store.Transaction(created.ID)
+		return tx.Accepted
+	})
+	if tx, _ := env.Store.Transaction(created.ID); tx.Confirmed {
+		t.Fatal("pool transfer must not confirm the transaction")
+	}
+
+	env.Wallet.SetPayments(created.Address, testutil.Payment("pool-tx", oneXMR, 10))
+	testutil.WaitFor(t, "unlocked payment", func() bool {
+		tx, _ := env.Store.Transaction(created.ID)
+		return tx.Confirmed
+	})
+	tx, _ := env.Store.Transaction(created.ID)
+	if len(tx.SubTransactions) != 1 || tx.SubTransactions[0].TxHash != "pool-tx" || tx.SubTransactions[0].Confirmations != 10 {
+		t.Fatalf("unexpected subtransactions: %+v", tx.SubTransactions)
+	}
+
+	var health struct {
+		Services struct {
+			MoneroPay payment.Health `json:"MoneroPay"`
+		} `json:"services"`
+	}
+	env.MustDo(t, http.MethodGet, "/misc/health", "", nil, &health)
+	if !health.Services.MoneroPay.Healthy() || !health.Services.MoneroPay.Services["walletrpc"] {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+}
+
+func TestTransferCreationAndCompletion(t *testing.T) {
+	env := testutil.NewEnv(t)
+	env.Wallet.TransferFee = 1_000_000
+	vendorToken := env.LoginVendor(t)
+
+	for i := 0; i < 2; i++ {
+		env.Store.AddTransaction(models.Transaction{
+			VendorID:  env.Vendor.ID,
+			PosID:     &env.Pos.ID,
+			Amount:    oneXMR,
+			Currency:  "EUR",
+			Accepted:  true,
+			Confirmed: true,
+		})
+	}
+	pendingID := env.Store.AddTransaction(models.Transaction{VendorID: env.Vendor.ID, PosID: &env.Pos.ID, Amount: oneXMR, Currency: "EUR"})
+
+	env.MustDo(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil, nil)
+	testutil.WaitFor(t, "transfer completion", func() bool {
+		transfers := env.Store.Transfers()
+		return len(transfers) == 1 && transfers[0].Completed
+	})
+
+	transfer := env.Store.Transfers()[0]
+	if transfer.Amount != 2*oneXMR || transfer.Address != env.Vendor.MoneroSubaddress {
+		t.Fatalf("unexpected transfer: %+v", transfer)
+	}
+	if transfer.TxHash == nil || *transfer.TxHash != "wallet-transfer-1" {
+		t.Fatalf("unexpected tx hash: %v", transfer.TxHash)
+	}
+	if transfer.AmountTransferred == nil || *transfer.AmountTransferred != 2*oneXMR-1_000_000 {
+		t.Fatalf("unexpected amount transferred: %v", transfer.AmountTransferred)
+	}
+	if len(transfer.Transactions) != 2 {
+		t.Fatalf("expected 2 linked transactions, got %d", len(transfer.Transactions))
+	}
+	for _, tx := range transfer.Transactions {
+		if !tx.Transferred {
+			t.Fatalf("transaction %d not marked transferred", tx.ID)
+		}
+	}
+	if pending, _ := env.Store.Transaction(pendingID); pending.Transferred {
+		t.Fatal("pending transaction was transferred")
+	}
+
+	// dry run first, then the relayed transfer
+	calls := env.Wallet.Calls("transfer")
+	if len(calls) != 2 || !strings.Contains(string(calls[0].Params), `"do_not_relay":true`) || strings.Contains(string(calls[1].Params), `"do_not_relay"`) {
+		t.Fatalf("unexpected wallet transfer calls: %+v", calls)
+	}
+	if len(env.MoneroPay.Transfers()) != 0 {
+		t.Fatal("MoneroPay should not be used when wallet RPC succeeds")
+	}
+
+	var balance struct {
+		Balance int64 `json:"balance"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != 0 {
+		t.Fatalf("balance after transfer: got %d, want 0", balance.Balance)
+	}
+}
+
+func TestTransferFallsBackToMoneroPay(t *testing.T) {
+	env := testutil.NewEnv(t)
+	env.Wallet.Handle("transfer", func(json.RawMessage) (any, *testutil.RPCError) {
+		return nil, &testutil.RPCError{Code: -4, Message: "not enough unlocked money"}
+	})
+	vendorToken := env.LoginVendor(t)
+	env.Store.AddTransaction(models.Transaction{VendorID: env.Vendor.ID, PosID: &env.Pos.ID, Amount: oneXMR, Accepted: true, Confirmed: true})
+
+	env.MustDo(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil, nil)
+	testutil.WaitFor(t, "transfer completion", func() bool {
+		transfers := env.Store.Transfers()
+		return len(transfers) == 1 && transfers[0].Completed
+	})
+
+	mpTransfers := env.MoneroPay.Transfers()
+	if len(mpTransfers) != 1 || mpTransfers[0].Destinations[0].Address != env.Vendor.MoneroSubaddress {
+		t.Fatalf("unexpected MoneroPay transfers: %+v", mpTransfers)
+	}
+	if hash := env.Store.Transfers()[0].TxHash; hash == nil || *hash != "moneropay-transfer-1" {
+		t.Fatalf("unexpected tx hash: %v", hash)
+	}
+}
+
+func TestFailedTransferIsRolledBack(t *testing.T) {
+	env := testutil.NewEnv(t)
+	env.Wallet.Handle("transfer", func(json.RawMessage) (any, *testutil.RPCError) {
+		return nil, &testutil.RPCError{Code: -4, Message: "not enough unlocked money"}
+	})
+	env.MoneroPay.FailTransfers(true)
+	vendorToken := env.LoginVendor(t)
+	txID := env.Store.AddTransaction(models.Transaction{VendorID: env.Vendor.ID, PosID: &env.Pos.ID, Amount: oneXMR, Accepted: true, Confirmed: true})
+
+	env.MustDo(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil, nil)
+	testutil.WaitFor(t, "transfer attempts", func() bool { return len(env.Wallet.Calls("transfer")) >= 2 })
+
+	transfers := env.Store.Transfers()
+	if len(transfers) != 1 || transfers[0].Completed {
+		t.Fatalf("transfer should stay pending: %+v", transfers)
+	}
+	if tx, _ := env.Store.Transaction(txID); tx.Transferred {
+		t.Fatal("transaction marked transferred although the payout failed")
+	}
+}
+
+// stubBackend is a minimal in-memory payment backend, standing in for anything that is not MoneroPay
+type stubBackend struct {
+	mu       sync.Mutex
+	statuses map[string]*payment.ReceiveStatus
+}
+
+func (b *stubBackend) Name() string { return "stub" }
+
+func (b *stubBackend) CreateReceive(ctx context.Context, req payment.ReceiveRequest) (*payment.ReceiveResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	address := testutil.Subaddress(byte(200 + len(b.statuses)))
+	b.statuses[address] = &payment.ReceiveStatus{Expected: req.Amount}
+	return &payment.ReceiveResponse{Address: address, CreatedAt: time.Now()}, nil
+}
+
+func (b *stubBackend) GetReceiveStatus(ctx context.Context, address string) (*payment.ReceiveStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status, ok := b.statuses[address]
+	if !ok {
+		return nil, fmt.Errorf("unknown address %s", address)
+	}
+	copied := *status
+	return &copied, nil
+}
+
+func (b *stubBackend) pay(address string, amount, confirmations int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := b.statuses[address]
+	status.Transfers = append(status.Transfers, payment.IncomingTransfer{Amount: amount, Confirmations: confirmations, TxHash: fmt.Sprintf("stub-%d", len(status.Transfers))})
+	status.CoveredTotal += amount
+	if confirmations >= 10 {
+		status.CoveredUnlocked += amount
+	}
+}
+
+func (b *stubBackend) Transfer(ctx context.Context, req payment.TransferRequest) (*payment.TransferResult, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (b *stubBackend) CreateAccount(ctx context.Context, label string) (uint32, error) {
+	return 0, payment.ErrAccountsNotSupported
+}
+
+func (b *stubBackend) GetBalance(ctx context.Context, accountIndex uint32) (*payment.Balance, error) {
+	return &payment.Balance{}, nil
+}
+
+func (b *stubBackend) GetHealth(ctx context.Context) (*payment.Health, error) {
+	return &payment.Health{Status: http.StatusOK, Services: map[string]bool{"stub": true}}, nil
+}
+
+func TestAlternativePaymentBackend(t *testing.T) {
+	store := testutil.NewStore()
+	vendor := store.AddVendor("stub-vendor", testutil.VendorPassword, testutil.Subaddress(1))
+	till := store.AddPos(vendor.ID, "till-1", testutil.PosPassword)
+	backend := &stubBackend{statuses: make(map[string]*payment.ReceiveStatus)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config.Config{
+		JWTSecret:                 "jwt-secret",
+		JWTRefreshSecret:          "jwt-refresh-secret",
+		JWTMoneroPaySecret:        "jwt-moneropay-secret",
+		ConfirmationCheckInterval: 20 * time.Millisecond,
+		TransferCompleterInterval: time.Hour,
+	}
+	env := &testutil.Env{Cfg: cfg, Store: store, Vendor: vendor, Pos: till}
+	env.Server = httptest.NewServer(server.NewRouter(ctx, cfg, store.Repositories(), testutil.NewFakeWalletRPC(t).Client(), backend))
+	defer env.Server.Close()
+
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", env.LoginPos(t), map[string]any{
+		"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+
+	backend.pay(created.Address, oneXMR, 10)
+	testutil.WaitFor(t, "confirmation from stub backend", func() bool {
+		tx, _ := store.Transaction(created.ID)
+		return tx.Accepted && tx.Confirmed
+	})
+
+	var health struct {
+		Status   int `json:"status"`
+		Services struct {
+			MoneroPay payment.Health `json:"MoneroPay"`
+		} `json:"services"`
+	}
+	env.MustDo(t, http.MethodGet, "/misc/health", "", nil, &health)
+	if health.Status != http.StatusOK || !health.Services.MoneroPay.Services["stub"] {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+}
@@ -2,26 +2,75 @@ package server
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rpc"
 	localMiddleware "github.com/monerokon/xmrpos/xmrpos-backend/internal/core/server/middleware"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/admin"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/apikey"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/auth"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/callback"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/catalog"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/idempotency"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/invoice"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/misc"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/pos"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/vendor"
-	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/webhook"
 
 	"gorm.io/gorm"
 )
 
+const (
+	defaultConfirmationCheckInterval = 2 * time.Second  // Check for confirmations every 2 seconds
+	defaultTransferCompleterInterval = 30 * time.Second // Check every 30 seconds
+	defaultExpiryCheckInterval       = 30 * time.Second // Expire invoices every 30 seconds
+	defaultExchangeRateCacheTTL      = time.Minute      // Refetch exchange rates at most once a minute
+	defaultWebhookDispatchInterval   = 5 * time.Second  // Deliver queued webhooks every 5 seconds
+	idempotencyCleanupInterval       = time.Hour        // Drop expired idempotency keys every hour
+	defaultPayoutSchedulerInterval   = time.Minute      // Create due automatic payouts every minute
+)
+
+// Repositories groups the data access layer of every feature so the router can be
+// built on top of Postgres or on top of in-memory fakes in tests.
+type Repositories struct {
+	Admin       admin.AdminRepository
+	Auth        auth.AuthRepository
+	Vendor      vendor.VendorRepository
+	Pos         pos.PosRepository
+	Callback    callback.CallbackRepository
+	Misc        misc.MiscRepository
+	Webhook     webhook.WebhookRepository
+	Invoice     invoice.InvoiceRepository
+	APIKey      apikey.APIKeyRepository
+	Idempotency idempotency.IdempotencyRepository
+	Catalog     catalog.CatalogRepository
+}
+
+func NewRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Admin:       admin.NewAdminRepository(db),
+		Auth:        auth.NewAuthRepository(db),
+		Vendor:      vendor.NewVendorRepository(db),
+		Pos:         pos.NewPosRepository(db),
+		Callback:    callback.NewCallbackRepository(db),
+		Misc:        misc.NewMiscRepository(db),
+		Webhook:     webhook.NewWebhookRepository(db),
+		Invoice:     invoice.NewInvoiceRepository(db),
+		APIKey:      apikey.NewAPIKeyRepository(db),
+		Idempotency: idempotency.NewIdempotencyRepository(db),
+		Catalog:     catalog.NewCatalogRepository(db),
+	}
+}
+
 // Accept a context tied to server lifecycle to stop background loops on shutdown
-func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *rpc.Client, moneroPayClient *moneropay.MoneroPayAPIClient) *chi.Mux {
+func NewRouter(ctx context.Context, cfg *config.Config, repos Repositories, rpcClient *rpc.Client, payments payment.PaymentBackend) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware
@@ -30,13 +79,6 @@ func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	if moneroPayClient == nil {
-		moneroPayClient = moneropay.NewMoneroPayAPIClient()
-		if cfg.MoneroPayBaseURL != "" {
-			moneroPayClient.BaseURL = cfg.MoneroPayBaseURL
-		}
-	}
-
 	if rpcClient == nil {
 		rpcClient = rpc.NewClient(
 			cfg.MoneroWalletRPCEndpoint,
@@ -45,24 +87,51 @@ func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *
 		)
 	}
 
-	// Initialize repositories
-	adminRepository := admin.NewAdminRepository(db)
-	authRepository := auth.NewAuthRepository(db)
-	vendorRepository := vendor.NewVendorRepository(db)
-	posRepository := pos.NewPosRepository(db)
-	callbackRepository := callback.NewCallbackRepository(db)
-	miscRepository := misc.NewMiscRepository(db)
+	if payments == nil {
+		payments = newPaymentBackend(cfg, rpcClient)
+	}
+
+	confirmationCheckInterval := cfg.ConfirmationCheckInterval
+	if confirmationCheckInterval <= 0 {
+		confirmationCheckInterval = defaultConfirmationCheckInterval
+	}
+	transferCompleterInterval := cfg.TransferCompleterInterval
+	if transferCompleterInterval <= 0 {
+		transferCompleterInterval = defaultTransferCompleterInterval
+	}
+	expiryCheckInterval := cfg.ExpiryCheckInterval
+	if expiryCheckInterval <= 0 {
+		expiryCheckInterval = defaultExpiryCheckInterval
+	}
+	webhookDispatchInterval := cfg.WebhookDispatchInterval
+	if webhookDispatchInterval <= 0 {
+		webhookDispatchInterval = defaultWebhookDispatchInterval
+	}
+	payoutSchedulerInterval := cfg.PayoutSchedulerInterval
+	if payoutSchedulerInterval <= 0 {
+		payoutSchedulerInterval = defaultPayoutSchedulerInterval
+	}
+
+	mailer := newMailSender(cfg)
 
 	// Initialize services
-	vendorService := vendor.NewVendorService(vendorRepository, db, cfg, rpcClient, moneroPayClient)
-	vendorService.StartTransferCompleter(ctx, 30*time.Second) // Check every 30 seconds
-	adminService := admin.NewAdminService(adminRepository, cfg, vendorService)
-	authService := auth.NewAuthService(authRepository, cfg)
-	posService := pos.NewPosService(posRepository, cfg, moneroPayClient)
-	posService.StartPendingCleanup(ctx, 15*time.Minute, 2*time.Hour)
-	callbackService := callback.NewCallbackService(callbackRepository, cfg, moneroPayClient)
-	callbackService.StartConfirmationChecker(ctx, 2*time.Second) // Check for confirmations every 2 seconds
-	miscService := misc.NewMiscService(miscRepository, cfg, moneroPayClient)
+	webhookService := webhook.NewWebhookService(repos.Webhook, cfg)
+	webhookService.StartDispatcher(ctx, webhookDispatchInterval)
+	vendorService := vendor.NewVendorService(repos.Vendor, cfg, rpcClient, payments, webhookService, mailer)
+	vendorService.StartTransferCompleter(ctx, transferCompleterInterval)
+	vendorService.StartPayoutScheduler(ctx, payoutSchedulerInterval)
+	adminService := admin.NewAdminService(repos.Admin, cfg, vendorService)
+	authService := auth.NewAuthService(repos.Auth, cfg)
+	posService := pos.NewPosService(repos.Pos, cfg, payments, newExchangeRateService(cfg), webhookService, mailer)
+	posService.StartExpiryChecker(ctx, expiryCheckInterval)
+	callbackService := callback.NewCallbackService(repos.Callback, cfg, payments, webhookService)
+	callbackService.StartConfirmationChecker(ctx, confirmationCheckInterval)
+	miscService := misc.NewMiscService(repos.Misc, cfg, payments)
+	invoiceService := invoice.NewInvoiceService(repos.Invoice, cfg, posService)
+	apiKeyService := apikey.NewAPIKeyService(repos.APIKey)
+	idempotencyService := idempotency.NewIdempotencyService(repos.Idempotency, cfg)
+	idempotencyService.StartCleaner(ctx, idempotencyCleanupInterval)
+	catalogService := catalog.NewCatalogService(repos.Catalog)
 
 	// Initialize handlers
 	adminHandler := admin.NewAdminHandler(adminService, vendorService)
@@ -71,6 +140,10 @@ func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *
 	posHandler := pos.NewPosHandler(posService)
 	callbackHandler := callback.NewCallbackHandler(callbackService)
 	miscHandler := misc.NewMiscHandler(miscService)
+	webhookHandler := webhook.NewWebhookHandler(webhookService)
+	invoiceHandler := invoice.NewInvoiceHandler(invoiceService)
+	apiKeyHandler := apikey.NewAPIKeyHandler(apiKeyService)
+	catalogHandler := catalog.NewCatalogHandler(catalogService)
 
 	// Public routes
 	r.Group(func(r chi.Router) {
@@ -82,6 +155,8 @@ func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *
 
 		// Vendor routes
 		r.Post("/vendor/create", vendorHandler.CreateVendor)
+		// The emailed token authorizes it
+		r.Post("/vendor/payout-address/confirm", vendorHandler.ConfirmPayoutAddressChange)
 
 		// Callback routes
 		r.Post("/callback/receive/{jwt}", callbackHandler.ReceiveTransaction)
@@ -90,36 +165,100 @@ func NewRouter(ctx context.Context, cfg *config.Config, db *gorm.DB, rpcClient *
 
 		// Miscellaneous routes
 		r.Get("/misc/health", miscHandler.GetHealth)
+
+		// Checkout routes, the token in the path authorizes them
+		r.Get("/checkout/{token}", invoiceHandler.GetCheckout)
+		r.Get("/checkout/{token}/qr", invoiceHandler.GetCheckoutQRCode)
+		r.HandleFunc("/checkout/{token}/ws", invoiceHandler.CheckoutWS)
+	})
+
+	// Vendor routes that also take API keys, the key needs one of the scopes they are registered with
+	r.Group(func(r chi.Router) {
+		idempotent := localMiddleware.Idempotency(idempotencyService)
+		withScopes := func(scopes ...string) func(http.Handler) http.Handler {
+			authenticate := localMiddleware.AuthMiddleware(cfg, repos.Auth, scopes...)
+			return func(next http.Handler) http.Handler {
+				return authenticate(idempotent(next))
+			}
+		}
+
+		r.With(withScopes(models.APIKeyScopeTransactionsRead, models.APIKeyScopePayoutsWrite)).Get("/vendor/balance", vendorHandler.GetAccountBalance)
+		r.With(withScopes(models.APIKeyScopePayoutsWrite)).Post("/vendor/transfer-balance", vendorHandler.TransferBalance)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/transactions", vendorHandler.ListTransactions)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/transaction/{id}/qr", vendorHandler.GetTransactionQRCode)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/transaction/{id}/receipt", vendorHandler.GetTransactionReceipt)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/export", vendorHandler.ExportTransactions)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/reports/items", vendorHandler.ReportItems)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/reports/tips", vendorHandler.ReportTips)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead)).Get("/vendor/reports/tip-pool", vendorHandler.ReportTipPool)
+		r.With(withScopes(models.APIKeyScopeInvoicesWrite)).Post("/vendor/invoices", invoiceHandler.CreateInvoice)
+		r.With(withScopes(models.APIKeyScopeTransactionsRead, models.APIKeyScopeInvoicesWrite)).Get("/vendor/invoices/{id}", invoiceHandler.GetInvoice)
 	})
 
-	// Protected routes
+	// Protected routes. Only POST routes are idempotent, the recorder would break the websocket upgrade.
+	// SecretResponse keeps responses that carry credentials out of the idempotency store.
 	r.Group(func(r chi.Router) {
-		r.Use(localMiddleware.AuthMiddleware(cfg, authRepository))
+		r.Use(localMiddleware.AuthMiddleware(cfg, repos.Auth))
+		idempotent := localMiddleware.Idempotency(idempotencyService)
 
 		// Auth routes
-		r.Post("/auth/update-password", authHandler.UpdatePassword)
+		r.With(idempotent, localMiddleware.SecretResponse).Post("/auth/update-password", authHandler.UpdatePassword)
 
 		// Admin routes
-		r.Post("/admin/invite", adminHandler.CreateInvite)
+		r.With(idempotent, localMiddleware.SecretResponse).Post("/admin/invite", adminHandler.CreateInvite)
 		r.Get("/admin/vendors", adminHandler.ListVendors)
 		r.Get("/admin/balance", adminHandler.GetWalletBalance)
-		r.Post("/admin/transfer-balance", adminHandler.TransferBalance)
-		r.Post("/admin/delete", adminHandler.DeleteVendor)
+		r.With(idempotent).Post("/admin/transfer-balance", adminHandler.TransferBalance)
+		r.With(idempotent).Post("/admin/delete", adminHandler.DeleteVendor)
+		r.With(idempotent).Post("/admin/adjust-balance", adminHandler.AdjustBalance)
+		r.Get("/admin/late-payments", adminHandler.ListLatePayments)
+		r.With(idempotent).Post("/admin/resolve-late-payment", adminHandler.ResolveLatePayment)
+		r.Get("/admin/pending-refunds", adminHandler.ListPendingRefunds)
+		r.With(idempotent).Post("/admin/resolve-refund", adminHandler.ResolveRefund)
 
 		// Vendor routes
-		r.Post("/vendor/delete", vendorHandler.DeleteVendor)
-		r.Post("/vendor/create-pos", vendorHandler.CreatePos)
-		r.Get("/vendor/balance", vendorHandler.GetAccountBalance)
-		r.Post("/vendor/transfer-balance", vendorHandler.TransferBalance)
+		r.With(idempotent).Post("/vendor/delete", vendorHandler.DeleteVendor)
+		r.With(idempotent).Post("/vendor/create-pos", vendorHandler.CreatePos)
+		r.Get("/vendor/wallet-balance", vendorHandler.GetWalletBalance)
+		r.With(idempotent).Post("/vendor/refund", vendorHandler.RefundTransaction)
 		r.Get("/vendor/pos-list", vendorHandler.ListPosDevices)
-		r.Get("/vendor/transactions", vendorHandler.ListTransactions)
-		r.Get("/vendor/export", vendorHandler.ExportTransactions)
+		r.Get("/vendor/ledger", vendorHandler.ListLedger)
+		r.Get("/vendor/confirmation-policy", vendorHandler.GetConfirmationPolicy)
+		r.With(idempotent).Post("/vendor/confirmation-policy", vendorHandler.UpdateConfirmationPolicy)
+		r.Get("/vendor/payout-settings", vendorHandler.GetPayoutSettings)
+		r.With(idempotent).Post("/vendor/payout-settings", vendorHandler.UpdatePayoutSettings)
+		r.Get("/vendor/payout-destinations", vendorHandler.GetPayoutDestinations)
+		r.With(idempotent).Post("/vendor/payout-destinations", vendorHandler.UpdatePayoutDestinations)
+		r.Get("/vendor/payout-address", vendorHandler.GetPayoutAddress)
+		r.With(idempotent).Post("/vendor/payout-address", vendorHandler.ChangePayoutAddress)
+		r.With(idempotent).Post("/vendor/payout-address/cancel", vendorHandler.CancelPayoutAddressChange)
+		r.With(idempotent, localMiddleware.SecretResponse).Post("/vendor/webhooks", webhookHandler.CreateEndpoint)
+		r.Get("/vendor/webhooks", webhookHandler.ListEndpoints)
+		r.With(idempotent).Post("/vendor/webhooks/delete", webhookHandler.DeleteEndpoint)
+		r.Get("/vendor/webhooks/deliveries", webhookHandler.ListDeliveries)
+		r.With(idempotent, localMiddleware.SecretResponse).Post("/vendor/api-keys", apiKeyHandler.CreateKey)
+		r.Get("/vendor/api-keys", apiKeyHandler.ListKeys)
+		r.With(idempotent).Post("/vendor/api-keys/revoke", apiKeyHandler.RevokeKey)
+		r.With(idempotent).Post("/vendor/products", catalogHandler.CreateProduct)
+		r.Get("/vendor/products", catalogHandler.ListProducts)
+		r.With(idempotent).Post("/vendor/products/update", catalogHandler.UpdateProduct)
+		r.With(idempotent).Post("/vendor/products/delete", catalogHandler.DeleteProduct)
+		r.With(idempotent).Post("/vendor/products/import", catalogHandler.ImportProducts)
+		r.With(idempotent).Post("/vendor/products/categories", catalogHandler.CreateCategory)
+		r.Get("/vendor/products/categories", catalogHandler.ListCategories)
+		r.With(idempotent).Post("/vendor/products/categories/update", catalogHandler.UpdateCategory)
+		r.With(idempotent).Post("/vendor/products/categories/delete", catalogHandler.DeleteCategory)
 
 		// POS routes
-		r.Post("/pos/create-transaction", posHandler.CreateTransaction)
+		r.With(idempotent).Post("/pos/create-transaction", posHandler.CreateTransaction)
 		r.Get("/pos/transaction/{id}", posHandler.GetTransaction)
+		r.Get("/pos/transaction/{id}/qr", posHandler.GetTransactionQRCode)
+		r.Get("/pos/transaction/{id}/receipt", posHandler.GetTransactionReceipt)
+		r.With(idempotent).Post("/pos/email-receipt", posHandler.EmailReceipt)
 		r.Get("/pos/transactions", posHandler.ListTransactions)
+		r.Get("/pos/exchange-rates", posHandler.GetExchangeRates)
 		r.Get("/pos/export", posHandler.ExportTransactions)
+		r.Get("/pos/catalog", catalogHandler.PosCatalog)
 		r.HandleFunc("/pos/ws/transaction", posHandler.TransactionWS)
 	})
 
@@ -11,6 +11,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/mail"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rates"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rpc"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
 	"gorm.io/gorm"
@@ -22,7 +25,7 @@ type Server struct {
 	router    *chi.Mux
 	walletRPC *rpc.Client
 	daemonRPC *rpc.Client
-	moneroPay *moneropay.MoneroPayAPIClient
+	payments  payment.PaymentBackend
 }
 
 func NewServer(cfg *config.Config, db *gorm.DB) *Server {
@@ -30,12 +33,8 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 		config:    cfg,
 		db:        db,
 		walletRPC: rpc.NewClient(cfg.MoneroWalletRPCEndpoint, cfg.MoneroWalletRPCUsername, cfg.MoneroWalletRPCPassword),
-		moneroPay: moneropay.NewMoneroPayAPIClient(),
-	}
-
-	if s.moneroPay != nil && cfg.MoneroPayBaseURL != "" {
-		s.moneroPay.BaseURL = cfg.MoneroPayBaseURL
 	}
+	s.payments = newPaymentBackend(cfg, s.walletRPC)
 
 	if cfg.MoneroDaemonRPCEndpoint != "" {
 		s.daemonRPC = rpc.NewClient(cfg.MoneroDaemonRPCEndpoint, "", "")
@@ -46,6 +45,43 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 	return s
 }
 
+func newPaymentBackend(cfg *config.Config, walletRPC *rpc.Client) payment.PaymentBackend {
+	switch cfg.PaymentBackend {
+	case config.PaymentBackendWalletRPC:
+		return payment.NewWalletRPCBackend(walletRPC)
+	default:
+		return payment.NewMoneroPayBackend(moneropay.NewMoneroPayAPIClient(cfg.MoneroPayBaseURL))
+	}
+}
+
+// newExchangeRateService returns nil when no provider is configured and POS supplied rates are trusted
+func newExchangeRateService(cfg *config.Config) *rates.Service {
+	ttl := cfg.ExchangeRateCacheTTL
+	if ttl <= 0 {
+		ttl = defaultExchangeRateCacheTTL
+	}
+	switch cfg.ExchangeRateProvider {
+	case config.ExchangeRateProviderCryptoCompare:
+		return rates.NewService(rates.NewCryptoCompareProvider(cfg.ExchangeRateAPIURL), ttl)
+	case config.ExchangeRateProviderFixed:
+		return rates.NewService(rates.NewFixedProvider(cfg.FixedExchangeRates), ttl)
+	default:
+		return nil
+	}
+}
+
+// newMailSender returns nil when no sender is configured and the backend sends no email
+func newMailSender(cfg *config.Config) mail.Sender {
+	switch cfg.MailSender {
+	case config.MailSenderSMTP:
+		return mail.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case config.MailSenderLog:
+		return mail.NewLogSender()
+	default:
+		return nil
+	}
+}
+
 func (s *Server) Start() error {
 	// Root context for router and background workers
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -53,7 +89,7 @@ func (s *Server) Start() error {
 
 	s.runStartupSequence(ctx)
 
-	s.router = NewRouter(ctx, s.config, s.db, s.walletRPC, s.moneroPay)
+	s.router = NewRouter(ctx, s.config, NewRepositories(s.db), s.walletRPC, s.payments)
 
 	server := &http.Server{
 		Addr:              "0.0.0.0:" + s.config.Port,
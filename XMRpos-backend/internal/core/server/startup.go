@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,7 +18,7 @@ func (s *Server) runStartupSequence(ctx context.Context) {
 
 	s.logMoneroNodeInfo(ctx)
 	s.ensureWalletReady(ctx)
-	s.logMoneroPayHealth(ctx)
+	s.logPaymentBackendHealth(ctx)
 }
 
 func (s *Server) logMoneroNodeInfo(parentCtx context.Context) {
@@ -163,27 +164,28 @@ func (s *Server) configureAutoRefresh(parentCtx context.Context) error {
 	return nil
 }
 
-func (s *Server) logMoneroPayHealth(parentCtx context.Context) {
-	if s.moneroPay == nil {
-		log.Println("MoneroPay client not configured; skipping health check")
+func (s *Server) logPaymentBackendHealth(parentCtx context.Context) {
+	if s.payments == nil {
+		log.Println("Payment backend not configured; skipping health check")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(parentCtx, 5*time.Second)
 	defer cancel()
 
-	health, err := s.moneroPay.GetHealth(ctx)
+	health, err := s.payments.GetHealth(ctx)
 	if err != nil {
-		log.Printf("Failed to fetch MoneroPay health: %v", err)
+		log.Printf("Failed to fetch %s health: %v", s.payments.Name(), err)
 		return
 	}
 
-	log.Printf(
-		"MoneroPay health: status=%d wallet_rpc=%t postgresql=%t",
-		health.Status,
-		health.Services.Walletrpc,
-		health.Services.Postgresql,
-	)
+	services := make([]string, 0, len(health.Services))
+	for name, ok := range health.Services {
+		services = append(services, fmt.Sprintf("%s=%t", name, ok))
+	}
+	sort.Strings(services)
+
+	log.Printf("Payment backend %s health: status=%d %s", s.payments.Name(), health.Status, strings.Join(services, " "))
 }
 
 func formatAtomic(amount uint64) string {
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
 )
@@ -13,3 +14,19 @@ func GetClaimFromContext(ctx context.Context, key models.ClaimsContextKey) (valu
 	}
 	return value, true
 }
+
+// VendorID returns the ID of the authenticated vendor, or false after writing a 401
+func VendorID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	role, ok := GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "vendor" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	id, ok := GetClaimFromContext(r.Context(), models.ClaimsVendorIDKey)
+	if !ok {
+		http.Error(w, "Unauthorized: vendorID not found", http.StatusUnauthorized)
+		return 0, false
+	}
+	return *(id.(*uint)), true
+}
@@ -40,6 +40,39 @@ type transferBalanceRequest struct {
 	VendorID uint   `json:"vendor_id"`
 }
 
+type adjustBalanceRequest struct {
+	VendorID    uint   `json:"vendor_id"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+}
+
+type adjustBalanceResponse struct {
+	Success bool  `json:"success"`
+	Balance int64 `json:"balance"`
+}
+
+type resolveLatePaymentRequest struct {
+	TransactionID uint `json:"transaction_id"`
+}
+
+type resolveLatePaymentResponse struct {
+	Success bool   `json:"success"`
+	ID      uint   `json:"id"`
+	Status  string `json:"status"`
+}
+
+type resolveRefundRequest struct {
+	RefundID uint `json:"refund_id"`
+}
+
+type resolveRefundResponse struct {
+	Success           bool   `json:"success"`
+	ID                uint   `json:"id"`
+	Status            string `json:"status"`
+	AmountTransferred int64  `json:"amount_transferred"`
+	TxHash            string `json:"tx_hash"`
+}
+
 type deleteVendorRequest struct {
 	VendorID uint `json:"vendor_id"`
 }
@@ -129,7 +162,7 @@ func (h *AdminHandler) GetWalletBalance(w http.ResponseWriter, r *http.Request)
 	defer cancel()
 	r = r.WithContext(ctx)
 
-	balance, httpErr := h.vendorService.GetBalance(ctx, 0)
+	balance, httpErr := h.vendorService.GetWalletBalance(ctx)
 	if httpErr != nil {
 		http.Error(w, httpErr.Message, httpErr.Code)
 		return
@@ -191,6 +224,177 @@ func (h *AdminHandler) TransferBalance(w http.ResponseWriter, r *http.Request) {
 	io.Copy(io.Discard, r.Body)
 }
 
+// AdjustBalance books a manual credit (positive amount) or debit (negative amount) on a vendor's ledger
+func (h *AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "admin" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req adjustBalanceRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	balance, httpErr := h.service.AdjustVendorBalance(ctx, req.VendorID, req.Amount, req.Description)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	resp := adjustBalanceResponse{
+		Success: true,
+		Balance: balance,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	io.Copy(io.Discard, r.Body)
+}
+
+// ListLatePayments returns the payments to expired invoices waiting for manual resolution
+func (h *AdminHandler) ListLatePayments(w http.ResponseWriter, r *http.Request) {
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "admin" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	transactions, httpErr := h.service.ListLatePayments(ctx)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	resp := struct {
+		Transactions []vendorfeature.LatePaymentSummary `json:"transactions"`
+	}{Transactions: transactions}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ResolveLatePayment accepts a confirmed late payment and credits the vendor with it
+func (h *AdminHandler) ResolveLatePayment(w http.ResponseWriter, r *http.Request) {
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "admin" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req resolveLatePaymentRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	transaction, httpErr := h.service.ResolveLatePayment(ctx, req.TransactionID)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	resp := resolveLatePaymentResponse{
+		Success: true,
+		ID:      transaction.ID,
+		Status:  transaction.Status,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	io.Copy(io.Discard, r.Body)
+}
+
+// ListPendingRefunds returns the refunds whose transfer may or may not have left the wallet
+func (h *AdminHandler) ListPendingRefunds(w http.ResponseWriter, r *http.Request) {
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "admin" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	refunds, httpErr := h.service.ListPendingRefunds(ctx)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	resp := struct {
+		Refunds []vendorfeature.PendingRefundSummary `json:"refunds"`
+	}{Refunds: refunds}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ResolveRefund completes or fails a pending refund depending on whether the wallet sent its transfer
+func (h *AdminHandler) ResolveRefund(w http.ResponseWriter, r *http.Request) {
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "admin" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req resolveRefundRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	refund, httpErr := h.service.ResolveRefund(ctx, req.RefundID)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	resp := resolveRefundResponse{
+		Success: true,
+		ID:      refund.ID,
+		Status:  refund.Status,
+	}
+	if refund.AmountTransferred != nil {
+		resp.AmountTransferred = *refund.AmountTransferred
+	}
+	if refund.TxHash != nil {
+		resp.TxHash = *refund.TxHash
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	io.Copy(io.Discard, r.Body)
+}
+
 func (h *AdminHandler) DeleteVendor(w http.ResponseWriter, r *http.Request) {
 	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
 	if !ok || role != "admin" {
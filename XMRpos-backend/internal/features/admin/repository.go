@@ -38,8 +38,8 @@ func (r *adminRepository) ListVendorsWithBalances(ctx context.Context) ([]Vendor
 	var results []VendorSummary
 	err := r.db.WithContext(ctx).
 		Model(&models.Vendor{}).
-		Select("vendors.id AS id, vendors.name AS name, vendors.monero_subaddress AS monero_subaddress, COALESCE(SUM(CASE WHEN transactions.confirmed = ? AND transactions.transferred = ? THEN transactions.amount ELSE 0 END), 0) AS balance", true, false).
-		Joins("LEFT JOIN transactions ON transactions.vendor_id = vendors.id").
+		Select("vendors.id AS id, vendors.name AS name, vendors.monero_subaddress AS monero_subaddress, COALESCE(SUM(ledger_entries.amount), 0) AS balance").
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.vendor_id = vendors.id AND ledger_entries.account = ? AND ledger_entries.deleted_at IS NULL", models.LedgerAccountVendor).
 		Group("vendors.id, vendors.name, vendors.monero_subaddress").
 		Order("vendors.id ASC").
 		Scan(&results).Error
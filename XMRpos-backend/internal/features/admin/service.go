@@ -77,3 +77,75 @@ func (s *AdminService) DeleteVendor(ctx context.Context, vendorID uint) (httpErr
 
 	return s.vendorService.DeleteVendor(ctx, vendorID)
 }
+
+func (s *AdminService) AdjustVendorBalance(ctx context.Context, vendorID uint, amount int64, description string) (int64, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.vendorService == nil {
+		return 0, models.NewHTTPError(http.StatusInternalServerError, "vendor service not configured")
+	}
+
+	if vendorID == 0 {
+		return 0, models.NewHTTPError(http.StatusBadRequest, "vendor_id is required")
+	}
+
+	return s.vendorService.AdjustBalance(ctx, vendorID, amount, description)
+}
+
+func (s *AdminService) ListLatePayments(ctx context.Context) ([]vendorfeature.LatePaymentSummary, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.vendorService == nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "vendor service not configured")
+	}
+
+	return s.vendorService.ListLatePayments(ctx)
+}
+
+func (s *AdminService) ResolveLatePayment(ctx context.Context, transactionID uint) (*models.Transaction, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.vendorService == nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "vendor service not configured")
+	}
+
+	if transactionID == 0 {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "transaction_id is required")
+	}
+
+	return s.vendorService.ResolveLatePayment(ctx, transactionID)
+}
+
+func (s *AdminService) ListPendingRefunds(ctx context.Context) ([]vendorfeature.PendingRefundSummary, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.vendorService == nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "vendor service not configured")
+	}
+
+	return s.vendorService.ListPendingRefunds(ctx)
+}
+
+func (s *AdminService) ResolveRefund(ctx context.Context, refundID uint) (*models.Refund, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.vendorService == nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "vendor service not configured")
+	}
+
+	if refundID == 0 {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "refund_id is required")
+	}
+
+	return s.vendorService.ResolveRefund(ctx, refundID)
+}
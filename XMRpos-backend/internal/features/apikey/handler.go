@@ -0,0 +1,116 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
+)
+
+type APIKeyHandler struct {
+	service *APIKeyService
+}
+
+func NewAPIKeyHandler(service *APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+type createKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type createKeyResponse struct {
+	KeySummary
+	Key string `json:"key"`
+}
+
+type revokeKeyRequest struct {
+	ID uint `json:"id"`
+}
+
+type listKeysResponse struct {
+	Keys []KeySummary `json:"keys"`
+}
+
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req createKeyRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	key, plain, httpErr := h.service.CreateKey(ctx, id, req.Name, req.Scopes)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(createKeyResponse{KeySummary: *key, Key: plain})
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, httpErr := h.service.ListKeys(ctx, id)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listKeysResponse{Keys: keys})
+}
+
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req revokeKeyRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	if httpErr := h.service.RevokeKey(ctx, id, req.ID); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	io.Copy(io.Discard, r.Body)
+}
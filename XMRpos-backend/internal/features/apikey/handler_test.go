@@ -0,0 +1,91 @@
+package apikey_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestAPIKeys(t *testing.T) {
+	env := testutil.NewEnv(t)
+	vendorToken := env.LoginVendor(t)
+
+	var created struct {
+		ID     uint     `json:"id"`
+		Name   string   `json:"name"`
+		Prefix string   `json:"prefix"`
+		Scopes []string `json:"scopes"`
+		Key    string   `json:"key"`
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/api-keys", vendorToken, map[string]any{
+		"name": "shop backend", "scopes": []string{"transactions:read", "invoices:write"},
+	}, &created)
+	if !strings.HasPrefix(created.Key, "xpk_") || !strings.HasPrefix(created.Key, created.Prefix) || len(created.Scopes) != 2 {
+		t.Fatalf("unexpected key: %+v", created)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"unknown scope": {"name": "k", "scopes": []string{"admin"}},
+		"no scopes":     {"name": "k", "scopes": []string{}},
+		"no name":       {"name": " ", "scopes": []string{"invoices:write"}},
+	} {
+		if code, _ := env.Do(t, http.MethodPost, "/vendor/api-keys", vendorToken, body); code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400", name, code)
+		}
+	}
+
+	// The key works on the routes its scopes cover and nowhere else
+	env.MustDo(t, http.MethodGet, "/vendor/transactions", created.Key, nil, nil)
+	env.MustDo(t, http.MethodPost, "/vendor/invoices", created.Key, map[string]any{
+		"order_id": "api-1", "amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "redirect_url": "https://shop.example/done",
+	}, nil)
+	for path, method := range map[string]string{
+		"/vendor/transfer-balance": http.MethodPost,
+		"/vendor/api-keys":         http.MethodGet,
+		"/vendor/webhooks":         http.MethodGet,
+		"/pos/transactions":        http.MethodGet,
+	} {
+		if code, _ := env.Do(t, method, path, created.Key, nil); code != http.StatusForbidden {
+			t.Fatalf("%s %s with API key: got status %d, want 403", method, path, code)
+		}
+	}
+	if code, _ := env.Do(t, http.MethodGet, "/vendor/transactions", "xpk_"+strings.Repeat("0", 64), nil); code != http.StatusUnauthorized {
+		t.Fatalf("unknown API key: got status %d, want 401", code)
+	}
+
+	// Only the hash is stored, the listing shows when the key was last used
+	var listed struct {
+		Keys []struct {
+			ID         uint    `json:"id"`
+			Prefix     string  `json:"prefix"`
+			LastUsedAt *string `json:"last_used_at"`
+		} `json:"keys"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/api-keys", vendorToken, nil, &listed)
+	if len(listed.Keys) != 1 || listed.Keys[0].Prefix != created.Prefix || listed.Keys[0].LastUsedAt == nil {
+		t.Fatalf("unexpected key listing: %+v", listed)
+	}
+	if _, body := env.Do(t, http.MethodGet, "/vendor/api-keys", vendorToken, nil); bytes.Contains(body, []byte(created.Key)) {
+		t.Fatal("key listing exposes the key")
+	}
+
+	env.CreateVendor(t, "other-vendor", testutil.Subaddress(2))
+	var other testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-vendor", "", map[string]any{"name": "other-vendor", "password": testutil.VendorPassword}, &other)
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/api-keys/revoke", other.AccessToken, map[string]any{"id": created.ID}); code != http.StatusNotFound {
+		t.Fatalf("revoke another vendor's key: got status %d, want 404", code)
+	}
+
+	env.MustDo(t, http.MethodPost, "/vendor/api-keys/revoke", vendorToken, map[string]any{"id": created.ID}, nil)
+	if code, _ := env.Do(t, http.MethodGet, "/vendor/transactions", created.Key, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked API key: got status %d, want 401", code)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/api-keys", env.LoginPos(t), map[string]any{"name": "k", "scopes": []string{"invoices:write"}}); code != http.StatusUnauthorized {
+		t.Fatalf("API key from a POS token: got status %d, want 401", code)
+	}
+}
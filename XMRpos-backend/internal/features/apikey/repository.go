@@ -0,0 +1,51 @@
+package apikey
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	CreateKey(ctx context.Context, key *models.APIKey) error
+	FindKeysByVendorID(ctx context.Context, vendorID uint) ([]*models.APIKey, error)
+	DeleteKey(ctx context.Context, vendorID uint, keyID uint) (bool, error)
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) CreateKey(ctx context.Context, key *models.APIKey) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *apiKeyRepository) FindKeysByVendorID(ctx context.Context, vendorID uint) ([]*models.APIKey, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var keys []*models.APIKey
+	if err := r.db.WithContext(ctx).Where("vendor_id = ?", vendorID).Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) DeleteKey(ctx context.Context, vendorID uint, keyID uint) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := r.db.WithContext(ctx).Where("id = ? AND vendor_id = ?", keyID, vendorID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,155 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+// KeyPrefix starts every API key, the auth middleware tells keys from JWTs by it
+const KeyPrefix = "xpk_"
+
+const (
+	maxKeysPerVendor = 20
+	maxNameLength    = 64
+	displayPrefixLen = 12 // KeyPrefix and the first 8 hex digits
+)
+
+// Scopes lists everything a key can be granted
+var Scopes = []string{
+	models.APIKeyScopeTransactionsRead,
+	models.APIKeyScopeInvoicesWrite,
+	models.APIKeyScopePayoutsWrite,
+}
+
+type APIKeyService struct {
+	repo APIKeyRepository
+}
+
+func NewAPIKeyService(repo APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// Hash is what is stored and looked up instead of the key. The keys are random, so a plain
+// SHA-256 is enough and keeps the lookup a single indexed query.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type KeySummary struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	LastUsedAt *string  `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func newKeySummary(key *models.APIKey) KeySummary {
+	summary := KeySummary{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    strings.Split(key.Scopes, ","),
+		CreatedAt: key.CreatedAt.Format(time.RFC3339),
+	}
+	if key.LastUsedAt != nil {
+		lastUsed := key.LastUsedAt.Format(time.RFC3339)
+		summary.LastUsedAt = &lastUsed
+	}
+	return summary
+}
+
+// CreateKey issues a named key with the given scopes and returns it, it is only shown this once
+func (s *APIKeyService) CreateKey(ctx context.Context, vendorID uint, name string, scopes []string) (*KeySummary, string, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxNameLength))
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, "", models.NewHTTPError(http.StatusBadRequest, "unknown scope: "+scope)
+		}
+	}
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	existing, err := s.repo.FindKeysByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	if len(existing) >= maxKeysPerVendor {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a vendor can have at most %d API keys", maxKeysPerVendor))
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "error generating API key")
+	}
+	plain := KeyPrefix + hex.EncodeToString(secret)
+
+	key := &models.APIKey{
+		VendorID: vendorID,
+		Name:     name,
+		Prefix:   plain[:displayPrefixLen],
+		KeyHash:  Hash(plain),
+		Scopes:   strings.Join(scopes, ","),
+	}
+	if err := s.repo.CreateKey(ctx, key); err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	summary := newKeySummary(key)
+	return &summary, plain, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context, vendorID uint) ([]KeySummary, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	keys, err := s.repo.FindKeysByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	result := make([]KeySummary, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, newKeySummary(key))
+	}
+	return result, nil
+}
+
+// RevokeKey deletes the key, requests made with it are refused from then on
+func (s *APIKeyService) RevokeKey(ctx context.Context, vendorID uint, keyID uint) *models.HTTPError {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deleted, err := s.repo.DeleteKey(ctx, vendorID, keyID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	if !deleted {
+		return models.NewHTTPError(http.StatusNotFound, "API key not found")
+	}
+	return nil
+}
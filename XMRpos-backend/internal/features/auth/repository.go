@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
 	"gorm.io/gorm"
@@ -14,6 +15,8 @@ type AuthRepository interface {
 	FindPosByID(ctx context.Context, id uint) (*models.Pos, error)
 	UpdateVendorPasswordHash(ctx context.Context, vendorID uint, newPasswordHash string) (uint32, error)
 	UpdatePosPasswordHash(ctx context.Context, posID uint, newPasswordHash string) (uint32, error)
+	FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	UpdateAPIKeyLastUsed(ctx context.Context, keyID uint, usedAt time.Time) error
 }
 
 type authRepository struct {
@@ -113,3 +116,21 @@ func (r *authRepository) UpdatePosPasswordHash(ctx context.Context, posID uint,
 	}
 	return pos.PasswordVersion, nil
 }
+
+func (r *authRepository) FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *authRepository) UpdateAPIKeyLastUsed(ctx context.Context, keyID uint, usedAt time.Time) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", keyID).Update("last_used_at", usedAt).Error
+}
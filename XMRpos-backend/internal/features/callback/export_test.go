@@ -0,0 +1,18 @@
+package callback
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+)
+
+// ProcessTransaction applies a receive status the way a callback or the confirmation checker would
+func (s *CallbackService) ProcessTransaction(ctx context.Context, transactionID uint, status payment.ReceiveStatus) *models.HTTPError {
+	return s.processTransaction(ctx, transactionID, status)
+}
+
+var (
+	PaymentTolerance    = paymentTolerance
+	ApplyReceivedAmount = applyReceivedAmount
+)
@@ -0,0 +1,76 @@
+package callback_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/address"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestLwsHookMatchesPaymentID(t *testing.T) {
+	const lwsAddress = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.LwsAddress = lwsAddress
+	})
+	posToken := env.LoginPos(t)
+
+	// Two customers paying the same price at once
+	var first, second struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	for _, created := range []any{&first, &second} {
+		env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+			"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+		}, created)
+	}
+	firstTx, _ := env.Store.Transaction(first.ID)
+	secondTx, _ := env.Store.Transaction(second.ID)
+	if firstTx.PaymentID == nil || secondTx.PaymentID == nil || *firstTx.PaymentID == *secondTx.PaymentID {
+		t.Fatalf("payment IDs not unique: %v %v", firstTx.PaymentID, secondTx.PaymentID)
+	}
+	if want, _ := address.Integrated(lwsAddress, *secondTx.PaymentID); second.Address != want {
+		t.Fatalf("address: got %s, want integrated address %s", second.Address, want)
+	}
+
+	hook := func(paymentID, txHash string, confirmations int64) int {
+		code, _ := env.Do(t, http.MethodPost, "/callback/lws-hook/lws-token", "", map[string]any{
+			"event":         "tx-confirmation",
+			"payment_id":    paymentID,
+			"amount":        oneXMR,
+			"tx_hash":       txHash,
+			"confirmations": confirmations,
+			"timestamp":     time.Now().UTC(),
+		})
+		return code
+	}
+
+	// Matching on the amount alone is ambiguous, the payment ID is not
+	if code := hook("", "no-payment-id", 0); code != http.StatusUnauthorized {
+		t.Fatalf("hook without payment ID: got status %d, want 401", code)
+	}
+	if code := hook("00000000ffffffff", "unknown", 0); code != http.StatusUnauthorized {
+		t.Fatalf("hook with unknown payment ID: got status %d, want 401", code)
+	}
+	if code := hook(strings.ToUpper(*secondTx.PaymentID), "second", 0); code != http.StatusOK {
+		t.Fatalf("hook for the second transaction: got status %d", code)
+	}
+	if tx, _ := env.Store.Transaction(second.ID); !tx.Accepted || tx.Confirmed {
+		t.Fatalf("second transaction not accepted: %+v", tx)
+	}
+	if tx, _ := env.Store.Transaction(first.ID); tx.AmountReceived != 0 {
+		t.Fatalf("first transaction credited with the second payment: %+v", tx)
+	}
+
+	// Hooks keep coming until the payment is unlocked
+	if code := hook(*firstTx.PaymentID, "first", 10); code != http.StatusOK {
+		t.Fatalf("hook for the first transaction: got status %d", code)
+	}
+	if tx, _ := env.Store.Transaction(first.ID); !tx.Accepted || !tx.Confirmed {
+		t.Fatalf("first transaction not confirmed: %+v", tx)
+	}
+}
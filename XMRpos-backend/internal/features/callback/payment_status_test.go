@@ -0,0 +1,111 @@
+package callback_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestPaymentStatus(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) { cfg.PaymentTolerancePercent = 1 })
+	posToken := env.LoginPos(t)
+
+	newTransaction := func(amount int64) (uint, string, string) {
+		t.Helper()
+		var created struct {
+			ID      uint   `json:"id"`
+			Address string `json:"address"`
+		}
+		env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+			"amount": amount, "amount_in_currency": 1.0, "currency": "EUR", "required_confirmations": 0,
+		}, &created)
+		receives := env.MoneroPay.Receives()
+		return created.ID, created.Address, testutil.CallbackJWT(t, receives[len(receives)-1])
+	}
+	pay := func(jwt, address string, txs ...moneropay.Transaction) {
+		t.Helper()
+		status := env.MoneroPay.SetPayments(address, txs...)
+		if code := env.SendCallback(t, jwt, status, txs[len(txs)-1]); code != http.StatusOK {
+			t.Fatalf("callback: got status %d", code)
+		}
+	}
+
+	// Underpayment: the cashier is told what is still missing over the websocket
+	id, address, jwt := newTransaction(oneXMR)
+	header := http.Header{"Authorization": {"Bearer " + posToken}}
+	wsURL := "ws" + strings.TrimPrefix(env.Server.URL, "http") + fmt.Sprintf("/pos/ws/transaction?transaction_id=%d", id)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer conn.Close()
+
+	first := testutil.Payment("under-1", oneXMR*4/10, 0)
+	pay(jwt, address, first)
+	var update models.Transaction
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("websocket read: %v", err)
+	}
+	if update.Status != models.TransactionStatusPartiallyPaid || update.AmountOutstanding != oneXMR*6/10 || update.Accepted {
+		t.Fatalf("unexpected websocket update: status=%s outstanding=%d accepted=%t", update.Status, update.AmountOutstanding, update.Accepted)
+	}
+
+	// Nobody tops up: the transaction is closed as underpaid instead of being deleted
+	var pending struct {
+		Pending []struct {
+			ID                uint   `json:"id"`
+			Status            string `json:"status"`
+			AmountOutstanding int64  `json:"amount_outstanding"`
+		} `json:"pending_transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/pos/transactions", posToken, nil, &pending)
+	if len(pending.Pending) != 1 || pending.Pending[0].AmountOutstanding != oneXMR*6/10 {
+		t.Fatalf("unexpected pending transactions: %+v", pending)
+	}
+	env.Store.ExpireTransactionAt(id, time.Now().Add(-time.Minute))
+	testutil.WaitFor(t, "underpaid transaction", func() bool {
+		tx, _ := env.Store.Transaction(id)
+		return tx.Status == models.TransactionStatusUnderpaid
+	})
+
+	// A top-up after expiry is not accepted at the lapsed quote, an admin resolves it
+	pay(jwt, address, testutil.Payment("under-1", oneXMR*4/10, 10), testutil.Payment("under-2", oneXMR*595/1000, 10))
+	tx, _ := env.Store.Transaction(id)
+	if tx.Status != models.TransactionStatusLatePayment || tx.Accepted || !tx.Confirmed || tx.AmountReceived != oneXMR*995/1000 {
+		t.Fatalf("expected a late payment: %+v", tx)
+	}
+	var resolved struct {
+		Status string `json:"status"`
+	}
+	env.MustDo(t, http.MethodPost, "/admin/resolve-late-payment", env.LoginAdmin(t), map[string]any{"transaction_id": id}, &resolved)
+	if resolved.Status != models.TransactionStatusPaid {
+		t.Fatalf("expected paid within tolerance once resolved, got %s", resolved.Status)
+	}
+
+	// Overpayment: the surplus is recorded for a refund and not credited to the vendor
+	id, address, jwt = newTransaction(oneXMR)
+	pay(jwt, address, testutil.Payment("over-1", oneXMR+oneXMR/5, 10))
+	tx, _ = env.Store.Transaction(id)
+	if tx.Status != models.TransactionStatusOverpaid || tx.AmountOverpaid != oneXMR/5 || !tx.Confirmed {
+		t.Fatalf("unexpected overpaid transaction: %+v", tx)
+	}
+
+	var balance struct {
+		Balance int64 `json:"balance"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", env.LoginVendor(t), nil, &balance)
+	if want := oneXMR*995/1000 + oneXMR; balance.Balance != want {
+		t.Fatalf("balance: got %d, want %d", balance.Balance, want)
+	}
+}
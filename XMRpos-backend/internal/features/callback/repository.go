@@ -4,17 +4,20 @@ import (
 	"context"
 	"time"
 
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/ledger"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
 	"gorm.io/gorm"
 )
 
 type CallbackRepository interface {
 	FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error)
-	FindUnconfirmedTransactions(ctx context.Context) ([]*models.Transaction, error)
+	FindTransactionsToCheck(ctx context.Context, expiredAfter time.Time, confirmedAfter time.Time) ([]*models.Transaction, error)
+	FindTransactionByPaymentID(ctx context.Context, paymentID string) (*models.Transaction, error)
 	FindRecentPendingTransactionsByAmount(ctx context.Context, amount int64, createdAfter time.Time) ([]*models.Transaction, error)
 	UpdateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error)
 	UpdateSubTransaction(ctx context.Context, subTx *models.SubTransaction) (*models.SubTransaction, error)
 	CreateSubTransaction(ctx context.Context, subTx *models.SubTransaction) (*models.SubTransaction, error)
+	CreateLedgerEntries(ctx context.Context, entries []*models.LedgerEntry) error
 }
 
 type callbackRepository struct {
@@ -36,20 +39,39 @@ func (r *callbackRepository) FindTransactionByID(ctx context.Context, id uint) (
 	return &transaction, nil
 }
 
-func (r *callbackRepository) FindUnconfirmedTransactions(ctx context.Context) ([]*models.Transaction, error) {
+// Unconfirmed transactions whose invoice is still open or expired after expiredAfter, transactions
+// confirmed after confirmedAfter which could still be undone by a reorg, and every transaction at risk
+// until it confirms again. Reversed ones are left alone.
+func (r *callbackRepository) FindTransactionsToCheck(ctx context.Context, expiredAfter time.Time, confirmedAfter time.Time) ([]*models.Transaction, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 	var transactions []*models.Transaction
 	if err := r.db.WithContext(ctx).
 		Preload("SubTransactions").
-		Where("confirmed = ?", false).
+		Where("status <> ?", models.TransactionStatusReversed).
+		Where("status = ? OR (confirmed = ? AND (expires_at IS NULL OR expires_at > ?)) OR (confirmed = ? AND confirmed_at > ?)",
+			models.TransactionStatusAtRisk, false, expiredAfter, true, confirmedAfter).
 		Find(&transactions).Error; err != nil {
 		return nil, err
 	}
 	return transactions, nil
 }
 
+func (r *callbackRepository) FindTransactionByPaymentID(ctx context.Context, paymentID string) (*models.Transaction, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Preload("SubTransactions").
+		Where("payment_id = ?", paymentID).
+		First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// FindRecentPendingTransactionsByAmount skips transactions with a payment ID, those are matched on it
 func (r *callbackRepository) FindRecentPendingTransactionsByAmount(ctx context.Context, amount int64, createdAfter time.Time) ([]*models.Transaction, error) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -58,7 +80,7 @@ func (r *callbackRepository) FindRecentPendingTransactionsByAmount(ctx context.C
 	var transactions []*models.Transaction
 	if err := r.db.WithContext(ctx).
 		Preload("SubTransactions").
-		Where("amount = ? AND confirmed = ? AND created_at >= ?", amount, false, createdAfter).
+		Where("amount = ? AND confirmed = ? AND created_at >= ? AND payment_id IS NULL", amount, false, createdAfter).
 		Order("created_at DESC").
 		Find(&transactions).Error; err != nil {
 		return nil, err
@@ -71,7 +93,21 @@ func (r *callbackRepository) UpdateTransaction(ctx context.Context, transaction
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if err := r.db.WithContext(ctx).Model(&models.Transaction{}).Where("id = ?", transaction.ID).Updates(transaction).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).Where("id = ?", transaction.ID).Updates(transaction).Error; err != nil {
+			return err
+		}
+		// Updates skips zero values, but the amounts have to be able to drop back to 0 and a
+		// double spend or reorg can take back acceptance and confirmation
+		return tx.Model(&models.Transaction{}).Where("id = ?", transaction.ID).Updates(map[string]interface{}{
+			"amount_received":    transaction.AmountReceived,
+			"amount_outstanding": transaction.AmountOutstanding,
+			"amount_overpaid":    transaction.AmountOverpaid,
+			"accepted":           transaction.Accepted,
+			"confirmed":          transaction.Confirmed,
+		}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return transaction, nil
@@ -82,7 +118,20 @@ func (r *callbackRepository) UpdateSubTransaction(ctx context.Context, subTx *mo
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if err := r.db.WithContext(ctx).Model(&models.SubTransaction{}).Where("id = ?", subTx.ID).Updates(subTx).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SubTransaction{}).Where("id = ?", subTx.ID).Updates(subTx).Error; err != nil {
+			return err
+		}
+		// A reorg can send a transfer back to the mempool, so these have to be able to drop to their zero values
+		return tx.Model(&models.SubTransaction{}).Where("id = ?", subTx.ID).Updates(map[string]interface{}{
+			"confirmations":     subTx.Confirmations,
+			"height":            subTx.Height,
+			"double_spend_seen": subTx.DoubleSpendSeen,
+			"locked":            subTx.Locked,
+			"reversed":          subTx.Reversed,
+		}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return subTx, nil
@@ -98,3 +147,11 @@ func (r *callbackRepository) CreateSubTransaction(ctx context.Context, subTx *mo
 	}
 	return subTx, nil
 }
+
+// Record a ledger posting, postings that already exist are left untouched
+func (r *callbackRepository) CreateLedgerEntries(ctx context.Context, entries []*models.LedgerEntry) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ledger.Create(r.db.WithContext(ctx), entries)
+}
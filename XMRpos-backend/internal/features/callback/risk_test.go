@@ -0,0 +1,108 @@
+package callback_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestDoubleSpendAndReorg(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.ConfirmationCheckInterval = 20 * time.Millisecond
+	})
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	create := func() (uint, string) {
+		var created struct {
+			ID      uint   `json:"id"`
+			Address string `json:"address"`
+		}
+		env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+			"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+		}, &created)
+		return created.ID, created.Address
+	}
+	transaction := func(id uint) *models.Transaction {
+		tx, _ := env.Store.Transaction(id)
+		return tx
+	}
+	balance := func() int64 {
+		var resp struct {
+			Balance int64 `json:"balance"`
+		}
+		env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &resp)
+		return resp.Balance
+	}
+
+	// A zero-conf payment flagged as a double spend is no longer accepted
+	zeroConfID, zeroConfAddress := create()
+	pooled := testutil.Payment("pooled", oneXMR, 0)
+	env.MoneroPay.SetPayments(zeroConfAddress, pooled)
+	testutil.WaitFor(t, "zero-conf acceptance", func() bool { return transaction(zeroConfID).Accepted })
+	pooled.DoubleSpendSeen = true
+	env.MoneroPay.SetPayments(zeroConfAddress, pooled)
+	testutil.WaitFor(t, "double spend", func() bool { return transaction(zeroConfID).Status == models.TransactionStatusAtRisk })
+	if tx := transaction(zeroConfID); tx.Accepted || tx.RiskReason == nil || !strings.Contains(*tx.RiskReason, "double spend seen for pooled") {
+		t.Fatalf("unexpected at risk transaction: %+v", tx)
+	}
+
+	// The conflicting transaction wins and the payment disappears
+	env.MoneroPay.SetPayments(zeroConfAddress)
+	testutil.WaitFor(t, "reversal", func() bool { return transaction(zeroConfID).Status == models.TransactionStatusReversed })
+	if tx := transaction(zeroConfID); tx.AmountReceived != 0 || !tx.SubTransactions[0].Reversed {
+		t.Fatalf("unexpected reversed transaction: %+v", tx)
+	}
+
+	// A confirmed payment is moved to another block by a reorg: its credit is held until it confirms again
+	confirmedID, confirmedAddress := create()
+	mined := testutil.Payment("mined", oneXMR, 10)
+	env.MoneroPay.SetPayments(confirmedAddress, mined)
+	testutil.WaitFor(t, "confirmation", func() bool { return transaction(confirmedID).Confirmed })
+	if got := balance(); got != oneXMR {
+		t.Fatalf("balance: got %d, want %d", got, oneXMR)
+	}
+
+	mined.Height, mined.Confirmations, mined.Locked = mined.Height+1, 2, true
+	env.MoneroPay.SetPayments(confirmedAddress, mined)
+	testutil.WaitFor(t, "reorg", func() bool { return transaction(confirmedID).Status == models.TransactionStatusAtRisk })
+	if tx := transaction(confirmedID); tx.Confirmed || tx.Accepted {
+		t.Fatalf("reorganized payment still confirmed: %+v", tx)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("payout of a held credit: got status %d, want 400", code)
+	}
+
+	mined.Confirmations, mined.Locked = 10, false
+	env.MoneroPay.SetPayments(confirmedAddress, mined)
+	testutil.WaitFor(t, "second confirmation", func() bool { return transaction(confirmedID).Status == models.TransactionStatusPaid })
+	if tx := transaction(confirmedID); !tx.Confirmed || !tx.Accepted {
+		t.Fatalf("payment not confirmed again: %+v", tx)
+	}
+
+	// A deeper reorg drops the payment: the credit is taken back
+	env.MoneroPay.SetPayments(confirmedAddress)
+	testutil.WaitFor(t, "reversal of a credited payment", func() bool { return transaction(confirmedID).Status == models.TransactionStatusReversed })
+	if got := balance(); got != 0 {
+		t.Fatalf("balance after reversal: got %d, want 0", got)
+	}
+
+	var listed struct {
+		Pending []struct {
+			ID         uint    `json:"id"`
+			Status     string  `json:"status"`
+			RiskReason *string `json:"risk_reason"`
+		} `json:"pending_transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/transactions", vendorToken, nil, &listed)
+	for _, tx := range listed.Pending {
+		if tx.ID == confirmedID && (tx.RiskReason == nil || !strings.Contains(*tx.RiskReason, "mined disappeared")) {
+			t.Fatalf("risk reason not listed: %+v", tx)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package callback
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"net/http"
@@ -13,19 +15,26 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/ledger"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/webhook"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
 )
 
+// How long expired invoices are still polled when LATE_PAYMENT_WINDOW is not set
+const defaultLatePaymentWindow = 24 * time.Hour
+
 type CallbackService struct {
-	repo      CallbackRepository
-	config    *config.Config
-	moneroPay *moneropay.MoneroPayAPIClient
-	mu        sync.Mutex
+	repo     CallbackRepository
+	config   *config.Config
+	payments payment.PaymentBackend
+	webhooks *webhook.WebhookService
+	mu       sync.Mutex
 }
 
-func NewCallbackService(repo CallbackRepository, cfg *config.Config, moneroPay *moneropay.MoneroPayAPIClient) *CallbackService {
-	return &CallbackService{repo: repo, config: cfg, moneroPay: moneroPay}
+func NewCallbackService(repo CallbackRepository, cfg *config.Config, payments payment.PaymentBackend, webhooks *webhook.WebhookService) *CallbackService {
+	return &CallbackService{repo: repo, config: cfg, payments: payments, webhooks: webhooks}
 }
 
 type LwsHookRequest struct {
@@ -84,36 +93,48 @@ func (s *CallbackService) StartConfirmationChecker(ctx context.Context, interval
 	}()
 }
 
-// This method queries for unconfirmed transactions and checks MoneroPay
+// This method queries for unconfirmed and recently confirmed transactions and checks the payment backend
 func (s *CallbackService) checkUnconfirmedTransactions(ctx context.Context) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	unconfirmed, err := s.repo.FindUnconfirmedTransactions(ctx)
+	window := s.config.LatePaymentWindow
+	if window <= 0 {
+		window = defaultLatePaymentWindow
+	}
+	reorgWindow := s.config.ReorgWatchWindow
+	if reorgWindow <= 0 {
+		reorgWindow = config.DefaultReorgWatchWindow
+	}
+
+	// Expired invoices are still watched for a while so late payments are noticed, and confirmed
+	// ones so a double spend or reorg is noticed before the funds are paid out
+	unconfirmed, err := s.repo.FindTransactionsToCheck(ctx, time.Now().Add(-window), time.Now().Add(-reorgWindow))
 	if err != nil {
 		return
 	}
 
 	for _, tx := range unconfirmed {
 		callCtx, cancel := context.WithTimeout(ctx, 8*time.Second)
-		// Skip transactions without a subaddress
-		if tx.SubAddress == nil {
+		// Skip transactions without a subaddress. Integrated addresses for a light-wallet server
+		// are not known to the payment backend, their payments arrive through the LWS hook.
+		if tx.SubAddress == nil || tx.PaymentID != nil {
 			cancel()
 			continue
 		}
-		moneroStatus, err := s.moneroPay.GetReceiveAddress(callCtx, *tx.SubAddress, &moneropay.GetReceiveAddressParams{})
+		status, err := s.payments.GetReceiveStatus(callCtx, *tx.SubAddress)
 		cancel()
 		if err != nil {
 			continue
 		}
 
-		if moneroStatus != nil {
-			_ = s.processTransaction(ctx, tx.ID, *moneroStatus)
+		if status != nil {
+			_ = s.processTransaction(ctx, tx.ID, *status)
 		}
 	}
 }
 
-func (s *CallbackService) processTransaction(ctx context.Context, transactionID uint, transactionToProcess moneropay.ReceiveAddressResponse) *models.HTTPError {
+func (s *CallbackService) processTransaction(ctx context.Context, transactionID uint, transactionToProcess payment.ReceiveStatus) *models.HTTPError {
 
 	// Get the transaction by ID
 	transaction, err := s.repo.FindTransactionByID(ctx, transactionID)
@@ -121,7 +142,14 @@ func (s *CallbackService) processTransaction(ctx context.Context, transactionID
 		return models.NewHTTPError(http.StatusNotFound, "Transaction not found")
 	}
 
-	for _, subTxToProcess := range transactionToProcess.Transactions {
+	// A credit has to be taken back at the amount it was booked with, before the amounts are updated
+	credited := *transaction
+	var risks []string
+	reported := make(map[string]bool)
+
+	for _, subTxToProcess := range transactionToProcess.Transfers {
+		reported[subTxToProcess.TxHash] = true
+
 		// Create or update the subtransaction
 		subTransaction := &models.SubTransaction{
 			TransactionID:   transaction.ID,
@@ -136,12 +164,20 @@ func (s *CallbackService) processTransaction(ctx context.Context, transactionID
 			Locked:          subTxToProcess.Locked,
 		}
 
+		if subTransaction.DoubleSpendSeen {
+			risks = append(risks, "double spend seen for "+subTransaction.TxHash)
+		}
+
 		// See if the txHash already exists in the transaction's subtransactions
 		existing := false
 		for _, subTx := range transaction.SubTransactions {
 			if subTx.TxHash == subTransaction.TxHash {
 				subTransaction.ID = subTx.ID // Ensure we set the ID for update
 				existing = true
+				// A mined transfer that moved to another height or back to the pool was in an orphaned block
+				if subTx.Height > 0 && subTx.Height != subTransaction.Height {
+					risks = append(risks, fmt.Sprintf("block of %s was reorganized (height %d -> %d)", subTx.TxHash, subTx.Height, subTransaction.Height))
+				}
 				break
 			}
 		}
@@ -161,41 +197,115 @@ func (s *CallbackService) processTransaction(ctx context.Context, transactionID
 		}
 	}
 
+	// A transfer the backend no longer reports was dropped from the pool or from the chain,
+	// typically because a conflicting transaction spent the same outputs
+	vanished := false
+	if !transactionToProcess.Partial {
+		for _, subTx := range transaction.SubTransactions {
+			if reported[subTx.TxHash] || subTx.Reversed {
+				continue
+			}
+			subTx.Reversed = true
+			if _, err := s.repo.UpdateSubTransaction(ctx, subTx); err != nil {
+				return models.NewHTTPError(http.StatusInternalServerError, "Failed to update subtransaction: "+err.Error())
+			}
+			vanished = true
+			risks = append(risks, "payment "+subTx.TxHash+" disappeared from the chain")
+		}
+	}
+
 	// Get the updated transaction with subtransactions
 	transaction, err = s.repo.FindTransactionByID(ctx, transaction.ID)
 	if err != nil {
 		return models.NewHTTPError(http.StatusNotFound, "Transaction not found after update")
 	}
 
+	// The quoted amount no longer holds once the invoice expired, also when it expired underpaid, or was
+	// settled by a refund, so funds that arrive afterwards are never accepted or credited automatically
+	// but flagged for an admin to resolve
+	previousStatus := transaction.Status
+	wasAccepted, wasConfirmed := transaction.Accepted, transaction.Confirmed
+	refunded := previousStatus == models.TransactionStatusRefunded
+	underpaid := previousStatus == models.TransactionStatusUnderpaid
+	late := previousStatus == models.TransactionStatusExpired || previousStatus == models.TransactionStatusLatePayment || refunded || underpaid
+	// A reversal is final, funds that show up again are left to an admin as well
+	reversed := vanished || previousStatus == models.TransactionStatusReversed
+	wasCredited := (wasConfirmed || transaction.ConfirmedAt != nil) && !late && previousStatus != models.TransactionStatusReversed
+
+	tolerance := paymentTolerance(transaction.Amount, s.config.PaymentTolerancePercent)
+	applyReceivedAmount(transaction, transactionToProcess.CoveredTotal, tolerance)
+	if late {
+		transaction.Status = models.TransactionStatusExpired
+		switch {
+		case refunded && transaction.AmountReceived <= credited.AmountReceived:
+			transaction.Status = models.TransactionStatusRefunded
+		case underpaid && transaction.AmountOutstanding > 0:
+			// A top-up that still falls short leaves the invoice underpaid, it can only be refunded in full
+			transaction.Status = models.TransactionStatusUnderpaid
+		case transaction.AmountReceived > 0:
+			transaction.Status = models.TransactionStatusLatePayment
+		}
+	}
+
 	// Calculate if transaction is accepted
 	allAccepted := true
 	for _, subTx := range transaction.SubTransactions {
+		if subTx.Reversed {
+			continue
+		}
 		if subTx.Confirmations < transaction.RequiredConfirmations {
 			allAccepted = false
 			break
 		}
 	}
 
-	if transactionToProcess.Amount.Covered.Total < transaction.Amount {
+	if transactionToProcess.CoveredTotal < transaction.Amount-tolerance {
 		allAccepted = false
 	}
 
-	transaction.Accepted = allAccepted
-
-	// Calculate if the transaction is confirmed
+	// Calculate if the transaction is confirmed. Transactions from before the vendor policy carry no
+	// payable confirmations and fall back to the wallet unlock time.
+	payableConfirmations := max(transaction.PayableConfirmations, models.MinPayableConfirmations)
 	allConfirmed := true
 	for _, subTx := range transaction.SubTransactions {
-		if subTx.Confirmations < 10 {
+		if subTx.Reversed {
+			continue
+		}
+		if subTx.Confirmations < payableConfirmations {
 			allConfirmed = false
 			break
 		}
 	}
 
-	if transactionToProcess.Amount.Covered.Unlocked < transaction.Amount {
+	if transactionToProcess.CoveredUnlocked < transaction.Amount-tolerance {
 		allConfirmed = false
 	}
 
-	transaction.Confirmed = allConfirmed
+	// A payment flagged as a double spend or moved by a reorg is held until it is confirmed again
+	// without the flag. It is neither accepted nor paid out in the meantime.
+	atRisk := !late && !reversed &&
+		(len(risks) > 0 || (previousStatus == models.TransactionStatusAtRisk && !allConfirmed))
+
+	switch {
+	case reversed:
+		transaction.Status = models.TransactionStatusReversed
+	case atRisk:
+		transaction.Status = models.TransactionStatusAtRisk
+		if previousStatus != models.TransactionStatusAtRisk {
+			transaction.RiskEpisodes++
+		}
+	}
+	if len(risks) > 0 {
+		reason := strings.Join(risks, "; ")
+		transaction.RiskReason = &reason
+	}
+
+	transaction.Accepted = allAccepted && !late && !reversed && !atRisk
+	transaction.Confirmed = allConfirmed && !reversed && !atRisk
+	if transaction.Confirmed && transaction.ConfirmedAt == nil {
+		now := time.Now().UTC()
+		transaction.ConfirmedAt = &now
+	}
 
 	// Update the transaction in the repository
 	_, err = s.repo.UpdateTransaction(ctx, transaction)
@@ -203,11 +313,80 @@ func (s *CallbackService) processTransaction(ctx context.Context, transactionID
 		return models.NewHTTPError(http.StatusInternalServerError, "Failed to update transaction: "+err.Error())
 	}
 
+	if (previousStatus == models.TransactionStatusExpired || refunded || underpaid) && transaction.Status == models.TransactionStatusLatePayment {
+		log.Printf("late payment of %d for expired transaction %d needs manual resolution", transaction.AmountReceived, transaction.ID)
+	}
+
+	// Credit the vendor, a payment is only posted once no matter how often it is processed
+	if transaction.Confirmed && !late {
+		if err := s.repo.CreateLedgerEntries(ctx, ledger.PaymentReceived(transaction)); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "Failed to credit vendor: "+err.Error())
+		}
+	}
+
+	// Take back a credit the vanished payment earned, the vendor balance may go negative if it was paid out already
+	if vanished && wasCredited {
+		if err := s.repo.CreateLedgerEntries(ctx, ledger.PaymentReversed(&credited)); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "Failed to reverse vendor credit: "+err.Error())
+		}
+	}
+
+	if transaction.Status != previousStatus && (reversed || atRisk) {
+		log.Printf("transaction %d of vendor %d is %s: %s", transaction.ID, transaction.VendorID, transaction.Status, *transaction.RiskReason)
+	}
+
 	go pos.NotifyTransactionUpdate(transaction.ID, transaction)
 
+	if transaction.Accepted && !wasAccepted {
+		s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionAccepted, transaction)
+	}
+	// Late payments only count as confirmed once an admin resolved them
+	if transaction.Confirmed && !wasConfirmed && !late {
+		s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionConfirmed, transaction)
+	}
+	if transaction.Status != previousStatus {
+		switch transaction.Status {
+		case models.TransactionStatusAtRisk:
+			s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionAtRisk, transaction)
+		case models.TransactionStatusReversed:
+			s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionReversed, transaction)
+		}
+	}
+
 	return nil
 }
 
+// paymentTolerance is the amount by which a payment may miss the requested amount and still count as paid
+func paymentTolerance(amount int64, percent float64) int64 {
+	if percent <= 0 {
+		return 0
+	}
+	return int64(float64(amount) * percent / 100)
+}
+
+// applyReceivedAmount sets the payment status of the transaction from the total received so far
+func applyReceivedAmount(transaction *models.Transaction, received int64, tolerance int64) {
+	transaction.AmountReceived = received
+	transaction.AmountOutstanding = 0
+	transaction.AmountOverpaid = 0
+
+	switch {
+	case received <= 0:
+		transaction.Status = models.TransactionStatusPending
+	case received < transaction.Amount-tolerance:
+		// an underpaid transaction stays underpaid until a top-up covers it
+		if transaction.Status != models.TransactionStatusUnderpaid {
+			transaction.Status = models.TransactionStatusPartiallyPaid
+		}
+		transaction.AmountOutstanding = transaction.Amount - received
+	case received > transaction.Amount+tolerance:
+		transaction.Status = models.TransactionStatusOverpaid
+		transaction.AmountOverpaid = received - transaction.Amount
+	default:
+		transaction.Status = models.TransactionStatusPaid
+	}
+}
+
 func (s *CallbackService) HandleCallback(ctx context.Context, jwtToken string, callback moneropay.CallbackResponse) (httpErr *models.HTTPError) {
 	if ctx == nil {
 		return models.NewHTTPError(http.StatusInternalServerError, "context required")
@@ -241,7 +420,7 @@ func (s *CallbackService) HandleCallback(ctx context.Context, jwtToken string, c
 		return models.NewHTTPError(http.StatusUnauthorized, "Invalid token")
 	}
 
-	httpErr = s.processTransaction(ctx, claims.TransactionID, callback.ToReceiveAddressResponse())
+	httpErr = s.processTransaction(ctx, claims.TransactionID, payment.MoneroPayReceiveStatus(callback.ToReceiveAddressResponse()))
 	if httpErr != nil {
 		return httpErr
 	}
@@ -286,16 +465,22 @@ func (s *CallbackService) HandleLwsHook(ctx context.Context, jwtToken string, pa
 		return models.NewHTTPError(http.StatusUnauthorized, "Invalid token")
 	}
 
-	candidates, err := s.repo.FindRecentPendingTransactionsByAmount(ctx, amount, time.Now().Add(-1*time.Minute))
-	if err != nil {
-		log.Printf("lws-hook: db error resolving by amount: %v", err)
-		return models.NewHTTPError(http.StatusUnauthorized, "Unable to resolve transaction for LWS hook")
+	paymentID := strings.ToLower(payload.PaymentID)
+	if paymentID == "" && payload.TxInfo != nil {
+		paymentID = strings.ToLower(payload.TxInfo.PaymentID)
 	}
-	if len(candidates) != 1 {
-		log.Printf("lws-hook: ambiguous candidates for amount=%d count=%d", amount, len(candidates))
-		return models.NewHTTPError(http.StatusUnauthorized, "Unable to uniquely resolve transaction for LWS hook")
+	// Transfers to a plain address carry no payment ID or one that is all zeros
+	if strings.Trim(paymentID, "0") == "" {
+		paymentID = ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, httpErr := s.resolveLwsTransaction(ctx, paymentID, amount)
+	if httpErr != nil {
+		return httpErr
 	}
-	transactionID := candidates[0].ID
 
 	ts := eventTimestamp
 	height := int64(0)
@@ -305,15 +490,28 @@ func (s *CallbackService) HandleLwsHook(ctx context.Context, jwtToken string, pa
 		height = *payload.TxInfo.Block
 	}
 
-	receive := moneropay.ReceiveAddressResponse{
-		Amount: moneropay.Amount{
-			Expected: amount,
-			Covered: moneropay.Covered{
-				Total:    amount,
-				Unlocked: 0,
-			},
-		},
-		Transactions: []moneropay.Transaction{
+	// The hook only reports one transfer, the ones recorded before count towards the amount as well
+	locked := payload.Confirmations < models.MinPayableConfirmations
+	coveredTotal, coveredUnlocked := amount, int64(0)
+	if !locked {
+		coveredUnlocked = amount
+	}
+	for _, subTx := range transaction.SubTransactions {
+		if subTx.TxHash == txHash || subTx.Reversed {
+			continue
+		}
+		coveredTotal += subTx.Amount
+		if !subTx.Locked {
+			coveredUnlocked += subTx.Amount
+		}
+	}
+
+	receive := payment.ReceiveStatus{
+		Expected:        transaction.Amount,
+		CoveredTotal:    coveredTotal,
+		CoveredUnlocked: coveredUnlocked,
+		Partial:         true,
+		Transfers: []payment.IncomingTransfer{
 			{
 				Amount:          amount,
 				Confirmations:   payload.Confirmations,
@@ -323,17 +521,38 @@ func (s *CallbackService) HandleLwsHook(ctx context.Context, jwtToken string, pa
 				Timestamp:       ts,
 				TxHash:          txHash,
 				UnlockTime:      0,
-				Locked:          payload.Confirmations == 0,
+				Locked:          locked,
 			},
 		},
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	httpErr = s.processTransaction(ctx, transactionID, receive)
+	httpErr = s.processTransaction(ctx, transaction.ID, receive)
 	if httpErr != nil {
 		return httpErr
 	}
 	return nil
 }
+
+// resolveLwsTransaction finds the transaction a hook is for by its payment ID. Transfers without
+// one fall back to the single pending transaction of the same amount created in the last minute.
+func (s *CallbackService) resolveLwsTransaction(ctx context.Context, paymentID string, amount int64) (*models.Transaction, *models.HTTPError) {
+	if paymentID != "" {
+		transaction, err := s.repo.FindTransactionByPaymentID(ctx, paymentID)
+		if err != nil {
+			log.Printf("lws-hook: no transaction for payment_id=%s: %v", paymentID, err)
+			return nil, models.NewHTTPError(http.StatusUnauthorized, "Unable to resolve transaction for LWS hook")
+		}
+		return transaction, nil
+	}
+
+	candidates, err := s.repo.FindRecentPendingTransactionsByAmount(ctx, amount, time.Now().Add(-1*time.Minute))
+	if err != nil {
+		log.Printf("lws-hook: db error resolving by amount: %v", err)
+		return nil, models.NewHTTPError(http.StatusUnauthorized, "Unable to resolve transaction for LWS hook")
+	}
+	if len(candidates) != 1 {
+		log.Printf("lws-hook: ambiguous candidates for amount=%d count=%d", amount, len(candidates))
+		return nil, models.NewHTTPError(http.StatusUnauthorized, "Unable to uniquely resolve transaction for LWS hook")
+	}
+	return candidates[0], nil
+}
@@ -0,0 +1,233 @@
+package callback_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/callback"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+// newTestService drives the payment state machine on the in-memory store without a payment backend
+func newTestService(t *testing.T) (*callback.CallbackService, *testutil.Store, *models.Vendor) {
+	t.Helper()
+	store := testutil.NewStore()
+	v := store.AddVendor("vendor", "vendor-password", testutil.Subaddress(1))
+	return callback.NewCallbackService(store.CallbackRepository(), &config.Config{}, nil, nil), store, v
+}
+
+// received reports transfers as the payment backend would, confirmations decide what is unlocked
+func received(confirmations int64, amounts ...int64) payment.ReceiveStatus {
+	status := payment.ReceiveStatus{}
+	for i, amount := range amounts {
+		status.CoveredTotal += amount
+		if confirmations >= models.MinPayableConfirmations {
+			status.CoveredUnlocked += amount
+		}
+		status.Transfers = append(status.Transfers, payment.IncomingTransfer{
+			Amount: amount, Confirmations: confirmations, Height: 100, TxHash: string(rune('a' + i)),
+		})
+	}
+	return status
+}
+
+func process(t *testing.T, s *callback.CallbackService, store *testutil.Store, id uint, status payment.ReceiveStatus) *models.Transaction {
+	t.Helper()
+	if err := s.ProcessTransaction(context.Background(), id, status); err != nil {
+		t.Fatalf("process transaction %d: %v", id, err)
+	}
+	tx, _ := store.Transaction(id)
+	return tx
+}
+
+// vendorBalance is what the vendor is owed according to the ledger
+func vendorBalance(t *testing.T, store *testutil.Store, v *models.Vendor) int64 {
+	t.Helper()
+	balance, err := store.VendorRepository().GetBalance(context.Background(), v.ID)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	return balance
+}
+
+// An underpaid invoice settled by a refund stays closed, later funds are left to an admin
+func TestRefundedTransactionStaysClosed(t *testing.T) {
+	s, store, v := newTestService(t)
+	id := store.AddTransaction(models.Transaction{
+		VendorID: v.ID, Amount: oneXMR, RequiredConfirmations: 0, PayableConfirmations: models.MinPayableConfirmations,
+		Status: models.TransactionStatusRefunded, AmountReceived: oneXMR / 2, AmountRefunded: oneXMR / 2,
+		SubTransactions: []*models.SubTransaction{{TxHash: "a", Amount: oneXMR / 2, Confirmations: 12, Height: 100}},
+	})
+
+	if tx := process(t, s, store, id, received(12, oneXMR/2)); tx.Status != models.TransactionStatusRefunded || tx.Accepted {
+		t.Fatalf("settled transaction reopened: status %s, accepted %v", tx.Status, tx.Accepted)
+	}
+
+	// The customer tops up the rest after the refund, the invoice must not be credited in full
+	tx := process(t, s, store, id, received(12, oneXMR/2, oneXMR/2))
+	if tx.Status != models.TransactionStatusLatePayment || tx.Accepted {
+		t.Fatalf("top-up after a refund: status %s, accepted %v", tx.Status, tx.Accepted)
+	}
+	if got := vendorBalance(t, store, v); got != 0 {
+		t.Fatalf("vendor credited for a settled transaction: balance %d", got)
+	}
+}
+
+// An invoice that expired underpaid is not paid by a later top-up, the top-up is left to an admin
+func TestTopUpAfterExpiry(t *testing.T) {
+	s, store, v := newTestService(t)
+	id := store.AddTransaction(models.Transaction{
+		VendorID: v.ID, Amount: oneXMR, RequiredConfirmations: 0, PayableConfirmations: models.MinPayableConfirmations,
+		Status: models.TransactionStatusUnderpaid, AmountReceived: oneXMR / 2, AmountOutstanding: oneXMR / 2,
+		SubTransactions: []*models.SubTransaction{{TxHash: "a", Amount: oneXMR / 2, Confirmations: 12, Height: 100}},
+	})
+
+	if tx := process(t, s, store, id, received(12, oneXMR/2)); tx.Status != models.TransactionStatusUnderpaid || tx.Accepted || tx.Confirmed {
+		t.Fatalf("expired underpaid invoice: status %s, accepted %v, confirmed %v", tx.Status, tx.Accepted, tx.Confirmed)
+	}
+	if tx := process(t, s, store, id, received(12, oneXMR/2, oneXMR/4)); tx.Status != models.TransactionStatusUnderpaid || tx.AmountReceived != oneXMR*3/4 {
+		t.Fatalf("top-up that falls short: status %s, received %d", tx.Status, tx.AmountReceived)
+	}
+
+	// The rest arrives after the quote window closed, the lapsed quote must not be credited automatically
+	tx := process(t, s, store, id, received(12, oneXMR/2, oneXMR/2))
+	if tx.Status != models.TransactionStatusLatePayment || tx.Accepted || !tx.Confirmed {
+		t.Fatalf("top-up after expiry: status %s, accepted %v, confirmed %v", tx.Status, tx.Accepted, tx.Confirmed)
+	}
+	if got := vendorBalance(t, store, v); got != 0 {
+		t.Fatalf("vendor credited for a top-up after expiry: balance %d", got)
+	}
+}
+
+// Every time a payment becomes at risk again is a new episode, so its webhook is sent again
+func TestRiskEpisodes(t *testing.T) {
+	s, store, v := newTestService(t)
+	id := store.AddTransaction(models.Transaction{
+		VendorID: v.ID, Amount: oneXMR, RequiredConfirmations: 0, PayableConfirmations: models.MinPayableConfirmations,
+		Status: models.TransactionStatusPending,
+	})
+	doubleSpend := func() payment.ReceiveStatus {
+		status := received(12, oneXMR)
+		status.Transfers[0].DoubleSpendSeen = true
+		return status
+	}
+
+	if tx := process(t, s, store, id, received(12, oneXMR)); tx.Status != models.TransactionStatusPaid || tx.RiskEpisodes != 0 {
+		t.Fatalf("clean payment: status %s, episodes %d", tx.Status, tx.RiskEpisodes)
+	}
+	process(t, s, store, id, doubleSpend())
+	if tx := process(t, s, store, id, doubleSpend()); tx.Status != models.TransactionStatusAtRisk || tx.RiskEpisodes != 1 {
+		t.Fatalf("double spend: status %s, episodes %d", tx.Status, tx.RiskEpisodes)
+	}
+	if tx := process(t, s, store, id, received(12, oneXMR)); tx.Status != models.TransactionStatusPaid {
+		t.Fatalf("flag cleared: status %s", tx.Status)
+	}
+	if tx := process(t, s, store, id, doubleSpend()); tx.Status != models.TransactionStatusAtRisk || tx.RiskEpisodes != 2 {
+		t.Fatalf("second double spend: status %s, episodes %d", tx.Status, tx.RiskEpisodes)
+	}
+}
+
+func TestPaymentTolerance(t *testing.T) {
+	if got := callback.PaymentTolerance(oneXMR, 0); got != 0 {
+		t.Fatalf("no tolerance configured: got %d", got)
+	}
+	if got := callback.PaymentTolerance(oneXMR, -1); got != 0 {
+		t.Fatalf("negative tolerance: got %d", got)
+	}
+	if got := callback.PaymentTolerance(oneXMR, 0.5); got != oneXMR/200 {
+		t.Fatalf("half a percent: got %d, want %d", got, oneXMR/200)
+	}
+}
+
+func TestApplyReceivedAmount(t *testing.T) {
+	const tolerance = 100
+	tests := []struct {
+		name        string
+		previous    string
+		received    int64
+		status      string
+		outstanding int64
+		overpaid    int64
+	}{
+		{"nothing yet", models.TransactionStatusPending, 0, models.TransactionStatusPending, 0, 0},
+		{"partial payment", models.TransactionStatusPending, 400_000, models.TransactionStatusPartiallyPaid, 600_000, 0},
+		{"underpaid stays underpaid", models.TransactionStatusUnderpaid, 500_000, models.TransactionStatusUnderpaid, 500_000, 0},
+		{"short within tolerance", models.TransactionStatusPending, 1_000_000 - tolerance, models.TransactionStatusPaid, 0, 0},
+		{"exact", models.TransactionStatusPartiallyPaid, 1_000_000, models.TransactionStatusPaid, 0, 0},
+		{"over within tolerance", models.TransactionStatusPending, 1_000_000 + tolerance, models.TransactionStatusPaid, 0, 0},
+		{"overpaid", models.TransactionStatusPending, 1_200_000, models.TransactionStatusOverpaid, 0, 200_000},
+	}
+	for _, tt := range tests {
+		tx := &models.Transaction{Amount: 1_000_000, Status: tt.previous, AmountOutstanding: 1, AmountOverpaid: 1}
+		callback.ApplyReceivedAmount(tx, tt.received, tolerance)
+		if tx.Status != tt.status || tx.AmountReceived != tt.received || tx.AmountOutstanding != tt.outstanding || tx.AmountOverpaid != tt.overpaid {
+			t.Errorf("%s: got status %s, received %d, outstanding %d, overpaid %d", tt.name, tx.Status, tx.AmountReceived, tx.AmountOutstanding, tx.AmountOverpaid)
+		}
+	}
+}
+
+// A payment whose block was reorganized is held until it is confirmed again at its new height
+func TestReorgHoldsPayment(t *testing.T) {
+	s, store, v := newTestService(t)
+	id := store.AddTransaction(models.Transaction{
+		VendorID: v.ID, Amount: oneXMR, RequiredConfirmations: 0, PayableConfirmations: models.MinPayableConfirmations,
+		Status: models.TransactionStatusPending,
+	})
+	if tx := process(t, s, store, id, received(2, oneXMR)); tx.Status != models.TransactionStatusPaid || !tx.Accepted || tx.Confirmed {
+		t.Fatalf("unconfirmed payment: status %s, accepted %v, confirmed %v", tx.Status, tx.Accepted, tx.Confirmed)
+	}
+
+	moved := received(1, oneXMR)
+	moved.Transfers[0].Height = 101
+	tx := process(t, s, store, id, moved)
+	if tx.Status != models.TransactionStatusAtRisk || tx.Accepted || tx.RiskReason == nil {
+		t.Fatalf("reorg: status %s, accepted %v, reason %v", tx.Status, tx.Accepted, tx.RiskReason)
+	}
+
+	// Still at risk while the new block is not deep enough, paid once it is
+	moved = received(5, oneXMR)
+	moved.Transfers[0].Height = 101
+	if tx := process(t, s, store, id, moved); tx.Status != models.TransactionStatusAtRisk {
+		t.Fatalf("reorged payment released before it confirmed: status %s", tx.Status)
+	}
+	moved = received(12, oneXMR)
+	moved.Transfers[0].Height = 101
+	if tx := process(t, s, store, id, moved); tx.Status != models.TransactionStatusPaid || !tx.Confirmed {
+		t.Fatalf("confirmed after the reorg: status %s, confirmed %v", tx.Status, tx.Confirmed)
+	}
+	if got := vendorBalance(t, store, v); got != oneXMR {
+		t.Fatalf("balance: got %d, want %d", got, oneXMR)
+	}
+}
+
+// A credited payment that disappears from the chain is reversed for good and its credit taken back
+func TestVanishedPaymentIsReversed(t *testing.T) {
+	s, store, v := newTestService(t)
+	id := store.AddTransaction(models.Transaction{
+		VendorID: v.ID, Amount: oneXMR, RequiredConfirmations: 0, PayableConfirmations: models.MinPayableConfirmations,
+		Status: models.TransactionStatusPending,
+	})
+	process(t, s, store, id, received(12, oneXMR))
+	if got := vendorBalance(t, store, v); got != oneXMR {
+		t.Fatalf("balance after confirmation: got %d, want %d", got, oneXMR)
+	}
+
+	tx := process(t, s, store, id, received(0))
+	if tx.Status != models.TransactionStatusReversed || tx.Accepted || tx.Confirmed {
+		t.Fatalf("vanished payment: status %s, accepted %v, confirmed %v", tx.Status, tx.Accepted, tx.Confirmed)
+	}
+	if got := vendorBalance(t, store, v); got != 0 {
+		t.Fatalf("credit of a vanished payment kept: balance %d", got)
+	}
+
+	// Funds that show up again are left to an admin
+	if tx := process(t, s, store, id, received(12, oneXMR)); tx.Status != models.TransactionStatusReversed {
+		t.Fatalf("reversed payment reopened: status %s", tx.Status)
+	}
+	if got := vendorBalance(t, store, v); got != 0 {
+		t.Fatalf("reversed payment credited again: balance %d", got)
+	}
+}
@@ -0,0 +1,339 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
+)
+
+type CatalogHandler struct {
+	service *CatalogService
+}
+
+func NewCatalogHandler(service *CatalogService) *CatalogHandler {
+	return &CatalogHandler{service: service}
+}
+
+type updateProductRequest struct {
+	ID uint `json:"id"`
+	ProductRequest
+}
+
+type updateCategoryRequest struct {
+	ID uint `json:"id"`
+	CategoryRequest
+}
+
+type deleteRequest struct {
+	ID uint `json:"id"`
+}
+
+type importRequest struct {
+	CSVData string `json:"csv_data"`
+}
+
+type listProductsResponse struct {
+	Categories []Category `json:"categories"`
+	Products   []Product  `json:"products"`
+}
+
+type listCategoriesResponse struct {
+	Categories []Category `json:"categories"`
+}
+
+func (h *CatalogHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req ProductRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	product, httpErr := h.service.CreateProduct(ctx, id, req)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(product)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	categories, products, httpErr := h.service.ListCatalog(ctx, id)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listProductsResponse{Categories: categories, Products: products})
+}
+
+func (h *CatalogHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req updateProductRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	product, httpErr := h.service.UpdateProduct(ctx, id, req.ID, req.ProductRequest)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(product)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req deleteRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	if httpErr := h.service.DeleteProduct(ctx, id, req.ID); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 2<<20)
+
+	var req importRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	result, httpErr := h.service.ImportCSV(ctx, id, req.CSVData)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req CategoryRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	category, httpErr := h.service.CreateCategory(ctx, id, req)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(category)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	categories, _, httpErr := h.service.ListCatalog(ctx, id)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listCategoriesResponse{Categories: categories})
+}
+
+func (h *CatalogHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req updateCategoryRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	category, httpErr := h.service.UpdateCategory(ctx, id, req.ID, req.CategoryRequest)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(category)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *CatalogHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req deleteRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	if httpErr := h.service.DeleteCategory(ctx, id, req.ID); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	io.Copy(io.Discard, r.Body)
+}
+
+// PosCatalog serves the catalog to a POS. The ETag is the catalog revision: If-None-Match with the
+// current revision answers 304, an older revision returns only what changed since then.
+func (h *CatalogHandler) PosCatalog(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "pos" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vendorIDPtr, _ := r.Context().Value(models.ClaimsVendorIDKey).(*uint)
+	if vendorIDPtr == nil {
+		http.Error(w, "Vendor ID is required", http.StatusBadRequest)
+		return
+	}
+
+	since := strings.Trim(strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-None-Match")), "W/"), `"`)
+	version, httpErr := h.service.CatalogVersion(ctx, *vendorIDPtr)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+version+`"`)
+	if since == version {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	catalog, httpErr := h.service.SyncCatalog(ctx, *vendorIDPtr, since)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+catalog.Version+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(catalog)
+}
@@ -0,0 +1,191 @@
+package catalog_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestCatalog(t *testing.T) {
+	env := testutil.NewEnv(t)
+	vendorToken := env.LoginVendor(t)
+	posToken := env.LoginPos(t)
+
+	type product struct {
+		ID         uint    `json:"id"`
+		CategoryID *uint   `json:"category_id"`
+		SKU        *string `json:"sku"`
+		Name       string  `json:"name"`
+		Price      float64 `json:"price"`
+		Currency   string  `json:"currency"`
+		TaxRate    float64 `json:"tax_rate"`
+		Active     bool    `json:"active"`
+	}
+	type category struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	type syncResponse struct {
+		Version            string     `json:"version"`
+		Full               bool       `json:"full"`
+		Categories         []category `json:"categories"`
+		Products           []product  `json:"products"`
+		DeletedCategoryIDs []uint     `json:"deleted_category_ids"`
+		DeletedProductIDs  []uint     `json:"deleted_product_ids"`
+	}
+	sync := func(etag string) (int, http.Header, syncResponse) {
+		t.Helper()
+		header := http.Header{}
+		if etag != "" {
+			header.Set("If-None-Match", etag)
+		}
+		code, respHeader, body := env.DoWithHeaders(t, http.MethodGet, "/pos/catalog", posToken, nil, header)
+		var resp syncResponse
+		if code == http.StatusOK {
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("decode catalog %q: %v", body, err)
+			}
+		}
+		return code, respHeader, resp
+	}
+
+	var drinks category
+	env.MustDo(t, http.MethodPost, "/vendor/products/categories", vendorToken, map[string]any{"name": "Drinks", "position": 1}, &drinks)
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/products/categories", vendorToken, map[string]any{"name": "Drinks"}); code != http.StatusConflict {
+		t.Fatalf("duplicate category: got status %d, want 409", code)
+	}
+
+	var coffee product
+	env.MustDo(t, http.MethodPost, "/vendor/products", vendorToken, map[string]any{
+		"sku": "COF-1", "name": "Coffee", "category_id": drinks.ID, "price": 3.5, "currency": "eur", "tax_class": "reduced", "tax_rate": 7,
+	}, &coffee)
+	if coffee.Currency != "EUR" || !coffee.Active || coffee.CategoryID == nil || *coffee.CategoryID != drinks.ID {
+		t.Fatalf("unexpected product: %+v", coffee)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"duplicate sku":    {"sku": "COF-1", "name": "Espresso", "price": 2.0, "currency": "EUR"},
+		"negative price":   {"name": "Espresso", "price": -1.0, "currency": "EUR"},
+		"bad currency":     {"name": "Espresso", "price": 2.0, "currency": "euro"},
+		"tax rate":         {"name": "Espresso", "price": 2.0, "currency": "EUR", "tax_rate": 120.0},
+		"missing name":     {"name": " ", "price": 2.0, "currency": "EUR"},
+		"unknown category": {"name": "Espresso", "price": 2.0, "currency": "EUR", "category_id": drinks.ID + 100},
+	} {
+		if code, _ := env.Do(t, http.MethodPost, "/vendor/products", vendorToken, body); code != http.StatusBadRequest && code != http.StatusConflict {
+			t.Fatalf("%s: got status %d, want 400 or 409", name, code)
+		}
+	}
+
+	// The import updates products by SKU and creates missing categories
+	var imported struct {
+		Created           int `json:"created"`
+		Updated           int `json:"updated"`
+		CategoriesCreated int `json:"categories_created"`
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/products/import", vendorToken, map[string]any{
+		"csv_data": "sku,name,category,price,currency,tax_rate,active\n" +
+			"COF-1,Coffee,Drinks,3.80,EUR,7,yes\n" +
+			"CAK-1,\"Cake, chocolate\",Food,4.20,EUR,7,\n" +
+			",Tip jar,,0,XMR,,false\n",
+	}, &imported)
+	if imported.Created != 2 || imported.Updated != 1 || imported.CategoriesCreated != 1 {
+		t.Fatalf("unexpected import result: %+v", imported)
+	}
+	code, body := env.Do(t, http.MethodPost, "/vendor/products/import", vendorToken, map[string]any{
+		"csv_data": "sku,name,price,currency\nTEA-1,Tea,2.50,EUR\nBAD-1,Broken,abc,EUR\n",
+	})
+	if code != http.StatusBadRequest || !strings.Contains(string(body), "line 3") {
+		t.Fatalf("invalid import row: got status %d: %s", code, body)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/products/import", vendorToken, map[string]any{"csv_data": "name,price,currency,color\nTea,2.50,EUR,green\n"}); code != http.StatusBadRequest {
+		t.Fatalf("unknown column: got status %d, want 400", code)
+	}
+
+	var listed struct {
+		Categories []category `json:"categories"`
+		Products   []product  `json:"products"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/products", vendorToken, nil, &listed)
+	if len(listed.Categories) != 2 || len(listed.Products) != 3 {
+		t.Fatalf("unexpected catalog after import: %+v", listed)
+	}
+	for _, p := range listed.Products {
+		if p.Name == "Tea" {
+			t.Fatal("a failed import stored products")
+		}
+		if p.ID == coffee.ID && p.Price != 3.8 {
+			t.Fatalf("import did not update the coffee price: %+v", p)
+		}
+		if p.Name == "Tip jar" && (p.Active || p.Currency != "XMR") {
+			t.Fatalf("unexpected imported product: %+v", p)
+		}
+	}
+
+	// The POS gets the full catalog first, then 304 until something changes
+	code, header, full := sync("")
+	if code != http.StatusOK || !full.Full || len(full.Products) != 3 || len(full.Categories) != 2 {
+		t.Fatalf("full sync: status %d: %+v", code, full)
+	}
+	etag := header.Get("ETag")
+	if etag != `"`+full.Version+`"` {
+		t.Fatalf("ETag %q does not carry version %q", etag, full.Version)
+	}
+	if code, _, _ := sync(etag); code != http.StatusNotModified {
+		t.Fatalf("unchanged catalog: got status %d, want 304", code)
+	}
+
+	// After changes only the delta is sent, deletions as IDs
+	coffee.Price = 3.9
+	env.MustDo(t, http.MethodPost, "/vendor/products/update", vendorToken, map[string]any{
+		"id": coffee.ID, "sku": "COF-1", "name": "Coffee", "category_id": drinks.ID, "price": 3.9, "currency": "EUR", "tax_rate": 7,
+	}, nil)
+	env.MustDo(t, http.MethodPost, "/vendor/products/categories/delete", vendorToken, map[string]any{"id": drinks.ID}, nil)
+	var cake uint
+	for _, p := range full.Products {
+		if p.SKU != nil && *p.SKU == "CAK-1" {
+			cake = p.ID
+		}
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/products/delete", vendorToken, map[string]any{"id": cake}, nil)
+
+	code, header, delta := sync(etag)
+	if code != http.StatusOK || delta.Full || delta.Version == full.Version {
+		t.Fatalf("delta sync: status %d: %+v", code, delta)
+	}
+	if len(delta.Products) != 1 || delta.Products[0].ID != coffee.ID || delta.Products[0].Price != 3.9 || delta.Products[0].CategoryID != nil {
+		t.Fatalf("unexpected delta products: %+v", delta.Products)
+	}
+	if len(delta.DeletedProductIDs) != 1 || delta.DeletedProductIDs[0] != cake || len(delta.DeletedCategoryIDs) != 1 || delta.DeletedCategoryIDs[0] != drinks.ID {
+		t.Fatalf("unexpected delta deletions: %+v", delta)
+	}
+	if code, _, _ := sync(header.Get("ETag")); code != http.StatusNotModified {
+		t.Fatalf("synced catalog: got status %d, want 304", code)
+	}
+	if code, _, resync := sync(`"garbage"`); code != http.StatusOK || !resync.Full || len(resync.Products) != 2 {
+		t.Fatalf("unknown version: status %d: %+v", code, resync)
+	}
+
+	// Catalogs are per vendor and only vendors edit them
+	env.CreateVendor(t, "other-vendor", testutil.Subaddress(2))
+	var other testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-vendor", "", map[string]any{"name": "other-vendor", "password": testutil.VendorPassword}, &other)
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/products/delete", other.AccessToken, map[string]any{"id": coffee.ID}); code != http.StatusNotFound {
+		t.Fatalf("delete another vendor's product: got status %d, want 404", code)
+	}
+	var otherListed struct {
+		Products []product `json:"products"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/products", other.AccessToken, nil, &otherListed)
+	if len(otherListed.Products) != 0 {
+		t.Fatalf("other vendor sees products: %+v", otherListed.Products)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/products", posToken, map[string]any{"name": "Tea", "price": 2.0, "currency": "EUR"}); code != http.StatusUnauthorized {
+		t.Fatalf("create product from a POS token: got status %d, want 401", code)
+	}
+	if code, _ := env.Do(t, http.MethodGet, "/pos/catalog", vendorToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("POS catalog with a vendor token: got status %d, want 401", code)
+	}
+}
@@ -0,0 +1,240 @@
+package catalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CatalogRepository interface {
+	FindCategoriesByVendorID(ctx context.Context, vendorID uint) ([]*models.ProductCategory, error)
+	FindCategoryByID(ctx context.Context, vendorID uint, categoryID uint) (*models.ProductCategory, error)
+	FindCategoryByName(ctx context.Context, vendorID uint, name string) (*models.ProductCategory, error)
+	CreateCategory(ctx context.Context, category *models.ProductCategory) error
+	UpdateCategory(ctx context.Context, category *models.ProductCategory) error
+	DeleteCategory(ctx context.Context, vendorID uint, categoryID uint, revision int64) (bool, error)
+	FindProductsByVendorID(ctx context.Context, vendorID uint) ([]*models.Product, error)
+	FindProductByID(ctx context.Context, vendorID uint, productID uint) (*models.Product, error)
+	FindProductBySKU(ctx context.Context, vendorID uint, sku string) (*models.Product, error)
+	CreateProduct(ctx context.Context, product *models.Product) error
+	UpdateProduct(ctx context.Context, product *models.Product) error
+	DeleteProduct(ctx context.Context, vendorID uint, productID uint, revision int64) (bool, error)
+	// BumpCatalogRevision increments the vendor's catalog revision and returns the new one. It locks
+	// the vendor's row until the transaction ends, so catalog changes commit in revision order.
+	BumpCatalogRevision(ctx context.Context, vendorID uint) (int64, error)
+	// CatalogVersion is the committed catalog revision of the vendor, 0 for a vendor that never had one
+	CatalogVersion(ctx context.Context, vendorID uint) (int64, error)
+	// FindCatalogChanges returns the categories and products, deleted ones included, changed after revision since
+	FindCatalogChanges(ctx context.Context, vendorID uint, since int64) ([]*models.ProductCategory, []*models.Product, error)
+	// RunInTransaction runs fn against a repository bound to a single database transaction.
+	// The transaction is rolled back if fn returns an error or panics.
+	RunInTransaction(ctx context.Context, fn func(repo CatalogRepository) error) error
+}
+
+type catalogRepository struct {
+	db *gorm.DB
+}
+
+func NewCatalogRepository(db *gorm.DB) CatalogRepository {
+	return &catalogRepository{db: db}
+}
+
+func (r *catalogRepository) FindCategoriesByVendorID(ctx context.Context, vendorID uint) ([]*models.ProductCategory, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var categories []*models.ProductCategory
+	if err := r.db.WithContext(ctx).
+		Where("vendor_id = ?", vendorID).
+		Order("position ASC, id ASC").
+		Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *catalogRepository) FindCategoryByID(ctx context.Context, vendorID uint, categoryID uint) (*models.ProductCategory, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var category models.ProductCategory
+	if err := r.db.WithContext(ctx).Where("id = ? AND vendor_id = ?", categoryID, vendorID).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *catalogRepository) FindCategoryByName(ctx context.Context, vendorID uint, name string) (*models.ProductCategory, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var category models.ProductCategory
+	if err := r.db.WithContext(ctx).Where("vendor_id = ? AND name = ?", vendorID, name).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *catalogRepository) CreateCategory(ctx context.Context, category *models.ProductCategory) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Create(category).Error
+}
+
+func (r *catalogRepository) UpdateCategory(ctx context.Context, category *models.ProductCategory) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Save(category).Error
+}
+
+// DeleteCategory soft deletes the category and moves its products out of it, stamping them with
+// revision so POS devices pick up the change on their next sync
+func (r *catalogRepository) DeleteCategory(ctx context.Context, vendorID uint, categoryID uint, revision int64) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	deleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProductCategory{}).
+			Where("id = ? AND vendor_id = ?", categoryID, vendorID).
+			Updates(map[string]interface{}{"revision": revision, "deleted_at": time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+		return tx.Model(&models.Product{}).
+			Where("vendor_id = ? AND category_id = ?", vendorID, categoryID).
+			Updates(map[string]interface{}{"category_id": nil, "revision": revision}).Error
+	})
+	return deleted, err
+}
+
+func (r *catalogRepository) FindProductsByVendorID(ctx context.Context, vendorID uint) ([]*models.Product, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var products []*models.Product
+	if err := r.db.WithContext(ctx).
+		Where("vendor_id = ?", vendorID).
+		Order("name ASC, id ASC").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *catalogRepository) FindProductByID(ctx context.Context, vendorID uint, productID uint) (*models.Product, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var product models.Product
+	if err := r.db.WithContext(ctx).Where("id = ? AND vendor_id = ?", productID, vendorID).First(&product).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *catalogRepository) FindProductBySKU(ctx context.Context, vendorID uint, sku string) (*models.Product, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var product models.Product
+	if err := r.db.WithContext(ctx).Where("vendor_id = ? AND sku = ?", vendorID, sku).First(&product).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *catalogRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Create(product).Error
+}
+
+func (r *catalogRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Save(product).Error
+}
+
+func (r *catalogRepository) DeleteProduct(ctx context.Context, vendorID uint, productID uint, revision int64) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND vendor_id = ?", productID, vendorID).
+		Updates(map[string]interface{}{"revision": revision, "deleted_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *catalogRepository) BumpCatalogRevision(ctx context.Context, vendorID uint) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var vendor models.Vendor
+	result := r.db.WithContext(ctx).Model(&vendor).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "catalog_revision"}}}).
+		Where("id = ?", vendorID).
+		UpdateColumn("catalog_revision", gorm.Expr("catalog_revision + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return vendor.CatalogRevision, nil
+}
+
+func (r *catalogRepository) CatalogVersion(ctx context.Context, vendorID uint) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var revision int64
+	if err := r.db.WithContext(ctx).Model(&models.Vendor{}).
+		Where("id = ?", vendorID).
+		Select("catalog_revision").
+		Scan(&revision).Error; err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+func (r *catalogRepository) FindCatalogChanges(ctx context.Context, vendorID uint, since int64) ([]*models.ProductCategory, []*models.Product, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var categories []*models.ProductCategory
+	if err := r.db.WithContext(ctx).Unscoped().
+		Where("vendor_id = ? AND revision > ?", vendorID, since).
+		Order("position ASC, id ASC").
+		Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+	var products []*models.Product
+	if err := r.db.WithContext(ctx).Unscoped().
+		Where("vendor_id = ? AND revision > ?", vendorID, since).
+		Order("name ASC, id ASC").
+		Find(&products).Error; err != nil {
+		return nil, nil, err
+	}
+	return categories, products, nil
+}
+
+func (r *catalogRepository) RunInTransaction(ctx context.Context, fn func(repo CatalogRepository) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&catalogRepository{db: tx})
+	})
+}
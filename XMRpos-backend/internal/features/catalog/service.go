@@ -0,0 +1,658 @@
+package catalog
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxProductsPerVendor   = 5000
+	maxCategoriesPerVendor = 200
+	maxImportRows          = 1000
+	maxNameLength          = 128
+	maxCategoryNameLength  = 64
+	maxSKULength           = 64
+	maxTaxClassLength      = 32
+	maxPrice               = 1_000_000_000
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type CatalogService struct {
+	repo CatalogRepository
+}
+
+func NewCatalogService(repo CatalogRepository) *CatalogService {
+	return &CatalogService{repo: repo}
+}
+
+// ProductRequest describes a product to create, or all fields of one to update
+type ProductRequest struct {
+	SKU        *string `json:"sku"`
+	Name       string  `json:"name"`
+	CategoryID *uint   `json:"category_id"`
+	Price      float64 `json:"price"`
+	Currency   string  `json:"currency"` // Fiat code or XMR
+	TaxClass   string  `json:"tax_class"`
+	TaxRate    float64 `json:"tax_rate"` // Percent
+	Active     *bool   `json:"active"`   // Defaults to true
+}
+
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+type Product struct {
+	ID         uint    `json:"id"`
+	CategoryID *uint   `json:"category_id"`
+	SKU        *string `json:"sku"`
+	Name       string  `json:"name"`
+	Price      float64 `json:"price"`
+	Currency   string  `json:"currency"`
+	TaxClass   string  `json:"tax_class"`
+	TaxRate    float64 `json:"tax_rate"`
+	Active     bool    `json:"active"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+type Category struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+// Catalog is what a POS syncs. A full catalog lists everything, a delta only what changed since
+// the version the POS already has, including the IDs of what was deleted since.
+type Catalog struct {
+	Version            string     `json:"version"`
+	Full               bool       `json:"full"`
+	Categories         []Category `json:"categories"`
+	Products           []Product  `json:"products"`
+	DeletedCategoryIDs []uint     `json:"deleted_category_ids"`
+	DeletedProductIDs  []uint     `json:"deleted_product_ids"`
+}
+
+type ImportResult struct {
+	Created           int `json:"created"`
+	Updated           int `json:"updated"`
+	CategoriesCreated int `json:"categories_created"`
+}
+
+func newProduct(product *models.Product) Product {
+	return Product{
+		ID:         product.ID,
+		CategoryID: product.CategoryID,
+		SKU:        product.SKU,
+		Name:       product.Name,
+		Price:      product.Price,
+		Currency:   product.Currency,
+		TaxClass:   product.TaxClass,
+		TaxRate:    product.TaxRate,
+		Active:     product.Active,
+		UpdatedAt:  product.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func newCategory(category *models.ProductCategory) Category {
+	return Category{ID: category.ID, Name: category.Name, Position: category.Position}
+}
+
+// changeCatalog runs fn in a transaction with the next catalog revision of the vendor, every row fn
+// writes is stamped with it. Taking the revision locks the vendor's row, so a POS that synced a
+// revision has seen everything written with a lower one.
+func (s *CatalogService) changeCatalog(ctx context.Context, vendorID uint, fn func(repo CatalogRepository, revision int64) *models.HTTPError) *models.HTTPError {
+	var httpErr *models.HTTPError
+	err := s.repo.RunInTransaction(ctx, func(repo CatalogRepository) error {
+		revision, err := repo.BumpCatalogRevision(ctx, vendorID)
+		if err != nil {
+			return err
+		}
+		if httpErr = fn(repo, revision); httpErr != nil {
+			return httpErr
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return httpErr
+	}
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	return nil
+}
+
+// applyProductRequest validates req and copies it onto product, the category must belong to the vendor
+func (s *CatalogService) applyProductRequest(ctx context.Context, repo CatalogRepository, vendorID uint, product *models.Product, req ProductRequest) *models.HTTPError {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxNameLength {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxNameLength))
+	}
+
+	var sku *string
+	if req.SKU != nil {
+		if trimmed := strings.TrimSpace(*req.SKU); trimmed != "" {
+			if len(trimmed) > maxSKULength {
+				return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("sku must be at most %d characters", maxSKULength))
+			}
+			sku = &trimmed
+		}
+	}
+
+	if !(req.Price >= 0) || req.Price > maxPrice {
+		return models.NewHTTPError(http.StatusBadRequest, "price must not be negative")
+	}
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if !currencyPattern.MatchString(currency) {
+		return models.NewHTTPError(http.StatusBadRequest, "currency must be a 3 letter code such as EUR or XMR")
+	}
+	taxClass := strings.TrimSpace(req.TaxClass)
+	if len(taxClass) > maxTaxClassLength {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("tax_class must be at most %d characters", maxTaxClassLength))
+	}
+	if !(req.TaxRate >= 0) || req.TaxRate > 100 {
+		return models.NewHTTPError(http.StatusBadRequest, "tax_rate must be between 0 and 100 percent")
+	}
+
+	if req.CategoryID != nil {
+		if _, err := repo.FindCategoryByID(ctx, vendorID, *req.CategoryID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.NewHTTPError(http.StatusBadRequest, "category not found")
+			}
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+	}
+
+	if sku != nil {
+		existing, err := repo.FindProductBySKU(ctx, vendorID, *sku)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if err == nil && existing.ID != product.ID {
+			return models.NewHTTPError(http.StatusConflict, "a product with this sku already exists")
+		}
+	}
+
+	product.VendorID = vendorID
+	product.SKU = sku
+	product.Name = name
+	product.CategoryID = req.CategoryID
+	product.Price = req.Price
+	product.Currency = currency
+	product.TaxClass = taxClass
+	product.TaxRate = req.TaxRate
+	product.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+func (s *CatalogService) CreateProduct(ctx context.Context, vendorID uint, req ProductRequest) (*Product, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	product := &models.Product{}
+	httpErr := s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		existing, err := repo.FindProductsByVendorID(ctx, vendorID)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if len(existing) >= maxProductsPerVendor {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a catalog holds at most %d products", maxProductsPerVendor))
+		}
+		if httpErr := s.applyProductRequest(ctx, repo, vendorID, product, req); httpErr != nil {
+			return httpErr
+		}
+		product.Revision = revision
+		if err := repo.CreateProduct(ctx, product); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	view := newProduct(product)
+	return &view, nil
+}
+
+// UpdateProduct replaces all fields of the product with req
+func (s *CatalogService) UpdateProduct(ctx context.Context, vendorID uint, productID uint, req ProductRequest) (*Product, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var product *models.Product
+	httpErr := s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		var err error
+		product, err = repo.FindProductByID(ctx, vendorID, productID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.NewHTTPError(http.StatusNotFound, "Product not found")
+			}
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if httpErr := s.applyProductRequest(ctx, repo, vendorID, product, req); httpErr != nil {
+			return httpErr
+		}
+		product.Revision = revision
+		if err := repo.UpdateProduct(ctx, product); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	view := newProduct(product)
+	return &view, nil
+}
+
+func (s *CatalogService) DeleteProduct(ctx context.Context, vendorID uint, productID uint) *models.HTTPError {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		deleted, err := repo.DeleteProduct(ctx, vendorID, productID, revision)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if !deleted {
+			return models.NewHTTPError(http.StatusNotFound, "Product not found")
+		}
+		return nil
+	})
+}
+
+// ListCatalog returns the vendor's whole catalog, inactive products included
+func (s *CatalogService) ListCatalog(ctx context.Context, vendorID uint) ([]Category, []Product, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	categories, err := s.repo.FindCategoriesByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	products, err := s.repo.FindProductsByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	categoryViews := make([]Category, 0, len(categories))
+	for _, category := range categories {
+		categoryViews = append(categoryViews, newCategory(category))
+	}
+	productViews := make([]Product, 0, len(products))
+	for _, product := range products {
+		productViews = append(productViews, newProduct(product))
+	}
+	return categoryViews, productViews, nil
+}
+
+func validateCategoryName(name string) (string, *models.HTTPError) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxCategoryNameLength {
+		return "", models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxCategoryNameLength))
+	}
+	return name, nil
+}
+
+// categoryNameTaken reports whether another category of the vendor already has the name
+func categoryNameTaken(ctx context.Context, repo CatalogRepository, vendorID uint, name string, categoryID uint) (bool, *models.HTTPError) {
+	existing, err := repo.FindCategoryByName(ctx, vendorID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	return existing.ID != categoryID, nil
+}
+
+func (s *CatalogService) CreateCategory(ctx context.Context, vendorID uint, req CategoryRequest) (*Category, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	name, httpErr := validateCategoryName(req.Name)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	category := &models.ProductCategory{VendorID: vendorID, Name: name, Position: req.Position}
+	httpErr = s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		existing, err := repo.FindCategoriesByVendorID(ctx, vendorID)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if len(existing) >= maxCategoriesPerVendor {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a catalog holds at most %d categories", maxCategoriesPerVendor))
+		}
+		taken, httpErr := categoryNameTaken(ctx, repo, vendorID, name, 0)
+		if httpErr != nil {
+			return httpErr
+		}
+		if taken {
+			return models.NewHTTPError(http.StatusConflict, "a category with this name already exists")
+		}
+		category.Revision = revision
+		if err := repo.CreateCategory(ctx, category); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	view := newCategory(category)
+	return &view, nil
+}
+
+func (s *CatalogService) UpdateCategory(ctx context.Context, vendorID uint, categoryID uint, req CategoryRequest) (*Category, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	name, httpErr := validateCategoryName(req.Name)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	var category *models.ProductCategory
+	httpErr = s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		var err error
+		category, err = repo.FindCategoryByID(ctx, vendorID, categoryID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.NewHTTPError(http.StatusNotFound, "Category not found")
+			}
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		taken, httpErr := categoryNameTaken(ctx, repo, vendorID, name, category.ID)
+		if httpErr != nil {
+			return httpErr
+		}
+		if taken {
+			return models.NewHTTPError(http.StatusConflict, "a category with this name already exists")
+		}
+
+		category.Name = name
+		category.Position = req.Position
+		category.Revision = revision
+		if err := repo.UpdateCategory(ctx, category); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	view := newCategory(category)
+	return &view, nil
+}
+
+// DeleteCategory removes the category, its products stay in the catalog without one
+func (s *CatalogService) DeleteCategory(ctx context.Context, vendorID uint, categoryID uint) *models.HTTPError {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		deleted, err := repo.DeleteCategory(ctx, vendorID, categoryID, revision)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if !deleted {
+			return models.NewHTTPError(http.StatusNotFound, "Category not found")
+		}
+		return nil
+	})
+}
+
+// CatalogVersion is the current revision of the vendor's catalog, the POS endpoint uses it as ETag
+func (s *CatalogService) CatalogVersion(ctx context.Context, vendorID uint) (string, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	version, err := s.repo.CatalogVersion(ctx, vendorID)
+	if err != nil {
+		return "", models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	return strconv.FormatInt(version, 10), nil
+}
+
+// SyncCatalog returns what changed since the version the POS has, or the full catalog when since
+// is empty or not a version this catalog could have had
+func (s *CatalogService) SyncCatalog(ctx context.Context, vendorID uint, since string) (*Catalog, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	version, err := s.repo.CatalogVersion(ctx, vendorID)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	catalog := &Catalog{
+		Version:            strconv.FormatInt(version, 10),
+		Categories:         []Category{},
+		Products:           []Product{},
+		DeletedCategoryIDs: []uint{},
+		DeletedProductIDs:  []uint{},
+	}
+
+	sinceRevision, parseErr := strconv.ParseInt(since, 10, 64)
+	if parseErr != nil || sinceRevision < 0 || sinceRevision > version {
+		catalog.Full = true
+		categories, products, httpErr := s.ListCatalog(ctx, vendorID)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		catalog.Categories = categories
+		catalog.Products = products
+		return catalog, nil
+	}
+
+	categories, products, err := s.repo.FindCatalogChanges(ctx, vendorID, sinceRevision)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	for _, category := range categories {
+		if category.DeletedAt.Valid {
+			catalog.DeletedCategoryIDs = append(catalog.DeletedCategoryIDs, category.ID)
+			continue
+		}
+		catalog.Categories = append(catalog.Categories, newCategory(category))
+	}
+	for _, product := range products {
+		if product.DeletedAt.Valid {
+			catalog.DeletedProductIDs = append(catalog.DeletedProductIDs, product.ID)
+			continue
+		}
+		catalog.Products = append(catalog.Products, newProduct(product))
+	}
+	return catalog, nil
+}
+
+// importColumns are the columns a catalog CSV may have, name, price and currency are required
+var importColumns = []string{"sku", "name", "category", "price", "currency", "tax_class", "tax_rate", "active"}
+
+// ImportCSV loads a menu from CSV with a header row. Rows with a known SKU update that product, the
+// others are created, and missing categories are created by name. Nothing is stored if a row is invalid.
+func (s *CatalogService) ImportCSV(ctx context.Context, vendorID uint, data string) (*ImportResult, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "csv_data must start with a header row")
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		known := false
+		for _, c := range importColumns {
+			known = known || c == column
+		}
+		if !known {
+			return nil, models.NewHTTPError(http.StatusBadRequest, "unknown column: "+column)
+		}
+		columns[column] = i
+	}
+	for _, required := range []string{"name", "price", "currency"} {
+		if _, ok := columns[required]; !ok {
+			return nil, models.NewHTTPError(http.StatusBadRequest, "missing column: "+required)
+		}
+	}
+
+	var rows [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, models.NewHTTPError(http.StatusBadRequest, "invalid CSV: "+err.Error())
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, record)
+		lines = append(lines, line)
+	}
+	if len(rows) == 0 {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "csv_data has no products")
+	}
+	if len(rows) > maxImportRows {
+		return nil, models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("an import holds at most %d products", maxImportRows))
+	}
+
+	result := &ImportResult{}
+	httpErr := s.changeCatalog(ctx, vendorID, func(repo CatalogRepository, revision int64) *models.HTTPError {
+		for i, record := range rows {
+			line := lines[i]
+			field := func(name string) string {
+				if index, ok := columns[name]; ok && index < len(record) {
+					return strings.TrimSpace(record[index])
+				}
+				return ""
+			}
+
+			req := ProductRequest{Name: field("name"), Currency: field("currency"), TaxClass: field("tax_class")}
+			var err error
+			if req.Price, err = strconv.ParseFloat(field("price"), 64); err != nil {
+				return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d: invalid price", line))
+			}
+			if rate := field("tax_rate"); rate != "" {
+				if req.TaxRate, err = strconv.ParseFloat(rate, 64); err != nil {
+					return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d: invalid tax_rate", line))
+				}
+			}
+			if active := field("active"); active != "" {
+				value, ok := parseBool(active)
+				if !ok {
+					return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d: invalid active", line))
+				}
+				req.Active = &value
+			}
+			if categoryName := field("category"); categoryName != "" {
+				category, created, catErr := findOrCreateCategory(ctx, repo, vendorID, categoryName, revision)
+				if catErr != nil {
+					return models.NewHTTPError(catErr.Code, fmt.Sprintf("line %d: %s", line, catErr.Message))
+				}
+				if created {
+					result.CategoriesCreated++
+				}
+				req.CategoryID = &category.ID
+			}
+
+			product := &models.Product{}
+			if sku := field("sku"); sku != "" {
+				req.SKU = &sku
+				existing, err := repo.FindProductBySKU(ctx, vendorID, sku)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+				}
+				if err == nil {
+					product = existing
+				}
+			}
+			if applyErr := s.applyProductRequest(ctx, repo, vendorID, product, req); applyErr != nil {
+				return models.NewHTTPError(applyErr.Code, fmt.Sprintf("line %d: %s", line, applyErr.Message))
+			}
+			product.Revision = revision
+			if product.ID != 0 {
+				if err := repo.UpdateProduct(ctx, product); err != nil {
+					return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+				}
+				result.Updated++
+			} else {
+				if err := repo.CreateProduct(ctx, product); err != nil {
+					return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+				}
+				result.Created++
+			}
+		}
+
+		products, err := repo.FindProductsByVendorID(ctx, vendorID)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if len(products) > maxProductsPerVendor {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a catalog holds at most %d products", maxProductsPerVendor))
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return result, nil
+}
+
+func findOrCreateCategory(ctx context.Context, repo CatalogRepository, vendorID uint, name string, revision int64) (*models.ProductCategory, bool, *models.HTTPError) {
+	name, httpErr := validateCategoryName(name)
+	if httpErr != nil {
+		return nil, false, httpErr
+	}
+	category, err := repo.FindCategoryByName(ctx, vendorID, name)
+	if err == nil {
+		return category, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	category = &models.ProductCategory{VendorID: vendorID, Name: name, Revision: revision}
+	if err := repo.CreateCategory(ctx, category); err != nil {
+		return nil, false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	return category, true, nil
+}
+
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "y":
+		return true, true
+	case "0", "false", "no", "n":
+		return false, true
+	}
+	return false, false
+}
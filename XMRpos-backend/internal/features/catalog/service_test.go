@@ -0,0 +1,70 @@
+package catalog_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/catalog"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+// A POS syncing while products are written must end up with every product, a row written
+// concurrently with a sync is picked up by the next delta instead of falling behind the version
+func TestSyncCatalogDuringWrites(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewStore()
+	vendor := store.AddVendor("vendor", "vendor-password", testutil.Subaddress(1))
+	s := catalog.NewCatalogService(store.CatalogRepository())
+
+	const writers, perWriter = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				sku := fmt.Sprintf("W%d-%d", w, i)
+				if _, httpErr := s.CreateProduct(ctx, vendor.ID, catalog.ProductRequest{SKU: &sku, Name: sku, Price: 1, Currency: "EUR"}); httpErr != nil {
+					t.Errorf("create product %s: %s", sku, httpErr.Message)
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	seen := map[uint]bool{}
+	version := ""
+	pull := func() {
+		t.Helper()
+		synced, httpErr := s.SyncCatalog(ctx, vendor.ID, version)
+		if httpErr != nil {
+			t.Fatalf("sync catalog: %s", httpErr.Message)
+		}
+		for _, product := range synced.Products {
+			seen[product.ID] = true
+		}
+		version = synced.Version
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		pull()
+	}
+	pull()
+
+	if len(seen) != writers*perWriter {
+		t.Fatalf("POS synced %d products, want %d", len(seen), writers*perWriter)
+	}
+	if version != fmt.Sprint(writers*perWriter) {
+		t.Fatalf("catalog version %s after %d changes", version, writers*perWriter)
+	}
+}
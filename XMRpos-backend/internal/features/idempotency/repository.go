@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	CreateKey(ctx context.Context, key *models.IdempotencyKey) (bool, error)
+	FindKey(ctx context.Context, scope string, key string) (*models.IdempotencyKey, error)
+	CompleteKey(ctx context.Context, key *models.IdempotencyKey) error
+	DeleteKey(ctx context.Context, id uint) error
+	DeleteExpiredKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// CreateKey reserves the key, it returns false when the caller already used it
+func (r *idempotencyRepository) CreateKey(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyRepository) FindKey(ctx context.Context, scope string, key string) (*models.IdempotencyKey, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var record models.IdempotencyKey
+	if err := r.db.WithContext(ctx).Where("scope = ? AND key = ?", scope, key).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) CompleteKey(ctx context.Context, key *models.IdempotencyKey) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
+		"completed":     true,
+		"status_code":   key.StatusCode,
+		"content_type":  key.ContentType,
+		"response_body": key.ResponseBody,
+	}).Error
+}
+
+// DeleteKey frees the key for another attempt, rows are removed for good so the unique index allows it
+func (r *idempotencyRepository) DeleteKey(ctx context.Context, id uint) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Unscoped().Delete(&models.IdempotencyKey{}, id).Error
+}
+
+func (r *idempotencyRepository) DeleteExpiredKeys(ctx context.Context, now time.Time) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := r.db.WithContext(ctx).Unscoped().Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,118 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultKeyTTL = 24 * time.Hour // How long responses are kept when IDEMPOTENCY_KEY_TTL is not set
+	maxKeyLength  = 255
+)
+
+type IdempotencyService struct {
+	repo   IdempotencyRepository
+	config *config.Config
+}
+
+func NewIdempotencyService(repo IdempotencyRepository, cfg *config.Config) *IdempotencyService {
+	return &IdempotencyService{repo: repo, config: cfg}
+}
+
+// Begin reserves the key for a request. When the caller used the key before, the stored
+// record is returned with replay set, unless that request is still running or was a different one.
+func (s *IdempotencyService) Begin(ctx context.Context, scope string, key string, requestHash string) (record *models.IdempotencyKey, replay bool, httpErr *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if key == "" || len(key) > maxKeyLength {
+		return nil, false, models.NewHTTPError(http.StatusBadRequest, "Idempotency-Key must be 1 to 255 characters")
+	}
+
+	ttl := s.config.IdempotencyKeyTTL
+	if ttl <= 0 {
+		ttl = defaultKeyTTL
+	}
+
+	// A second round is only needed when the stored key expired or was released in between
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		record := &models.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(ttl),
+		}
+		created, err := s.repo.CreateKey(ctx, record)
+		if err != nil {
+			return nil, false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if created {
+			return record, false, nil
+		}
+
+		existing, err := s.repo.FindKey(ctx, scope, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+		}
+		if existing.ExpiresAt.Before(now) {
+			if err := s.repo.DeleteKey(ctx, existing.ID); err != nil {
+				return nil, false, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+			}
+			continue
+		}
+		if existing.RequestHash != requestHash {
+			return nil, false, models.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		}
+		if !existing.Completed {
+			return nil, false, models.NewHTTPError(http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		}
+		return existing, true, nil
+	}
+	return nil, false, models.NewHTTPError(http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+}
+
+// Complete stores the response that retries of the request are answered with
+func (s *IdempotencyService) Complete(ctx context.Context, record *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	return s.repo.CompleteKey(ctx, record)
+}
+
+// Release gives the key up after a server error, so the request can be retried for real
+func (s *IdempotencyService) Release(ctx context.Context, record *models.IdempotencyKey) error {
+	return s.repo.DeleteKey(ctx, record.ID)
+}
+
+// StartCleaner removes expired keys in the background until ctx is done
+func (s *IdempotencyService) StartCleaner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweepCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+				if _, err := s.repo.DeleteExpiredKeys(sweepCtx, time.Now()); err != nil {
+					log.Printf("idempotency cleaner: %v", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package invoice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/qr"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/pos"
+)
+
+type InvoiceHandler struct {
+	service *InvoiceService
+}
+
+func NewInvoiceHandler(service *InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service: service}
+}
+
+func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB cap
+
+	var req CreateInvoiceRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	invoice, err := h.service.CreateInvoice(ctx, id, req)
+	if err != nil {
+		var httpErr *models.HTTPError
+		if errors.As(err, &httpErr) {
+			http.Error(w, httpErr.Message, httpErr.Code)
+			return
+		}
+		http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(invoice)
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	invoiceID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	invoice, httpErr := h.service.GetInvoice(ctx, id, uint(invoiceID))
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(invoice)
+}
+
+// GetCheckout is public, the token in the checkout page URL is the only credential
+func (h *InvoiceHandler) GetCheckout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	checkout, httpErr := h.service.GetCheckout(ctx, chi.URLParam(r, "token"))
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(checkout)
+}
+
+// GetCheckoutQRCode renders the payment URI of a checkout as PNG or SVG, e.g. ?format=svg&size=512
+func (h *InvoiceHandler) GetCheckoutQRCode(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	opts, err := qr.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	image, contentType, httpErr := h.service.GetCheckoutQRCode(ctx, chi.URLParam(r, "token"), opts)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(image)
+}
+
+// CheckoutWS streams the public view of a checkout on every status change
+func (h *InvoiceHandler) CheckoutWS(w http.ResponseWriter, r *http.Request) {
+	// bound the lookup, the stream itself lives as long as the connection
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	transactionID, render, httpErr := h.service.CheckoutUpdates(ctx, chi.URLParam(r, "token"))
+	cancel()
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	pos.ServeTransactionUpdates(w, r, transactionID, render)
+}
@@ -0,0 +1,141 @@
+package invoice_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestInvoiceCheckout(t *testing.T) {
+	env := testutil.NewEnv(t)
+	vendorToken := env.LoginVendor(t)
+
+	var invoice struct {
+		ID            uint   `json:"id"`
+		OrderID       string `json:"order_id"`
+		Status        string `json:"status"`
+		Address       string `json:"address"`
+		PaymentURI    string `json:"payment_uri"`
+		CheckoutURL   string `json:"checkout_url"`
+		CheckoutToken string `json:"checkout_token"`
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/invoices", vendorToken, map[string]any{
+		"order_id": "order-1001", "amount": oneXMR / 2, "amount_in_currency": 75.0, "currency": "EUR",
+		"description": "Web order", "redirect_url": "https://shop.example/orders/1001",
+	}, &invoice)
+	if invoice.OrderID != "order-1001" || invoice.Status != models.TransactionStatusPending || invoice.CheckoutToken == "" {
+		t.Fatalf("unexpected invoice: %+v", invoice)
+	}
+	if invoice.CheckoutURL != config.DefaultCheckoutPageURL+"?token="+invoice.CheckoutToken {
+		t.Fatalf("checkout url: got %s", invoice.CheckoutURL)
+	}
+
+	// The invoice belongs to the vendor, not to a POS
+	tx, _ := env.Store.Transaction(invoice.ID)
+	if tx.PosID != nil || tx.VendorID != env.Vendor.ID {
+		t.Fatalf("invoice owner: pos=%v vendor=%d", tx.PosID, tx.VendorID)
+	}
+	var listed struct {
+		Pending []struct {
+			ID uint `json:"id"`
+		} `json:"pending_transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/pos/transactions", env.LoginPos(t), nil, &listed)
+	if len(listed.Pending) != 0 {
+		t.Fatalf("invoice listed on the POS: %+v", listed)
+	}
+
+	// The checkout page needs no credentials, only the token
+	type checkoutView struct {
+		OrderID     string `json:"order_id"`
+		VendorName  string `json:"vendor_name"`
+		Status      string `json:"status"`
+		Address     string `json:"address"`
+		PaymentURI  string `json:"payment_uri"`
+		Accepted    bool   `json:"accepted"`
+		RedirectURL string `json:"redirect_url"`
+	}
+	checkoutPath := "/checkout/" + invoice.CheckoutToken
+	var checkout checkoutView
+	env.MustDo(t, http.MethodGet, checkoutPath, "", nil, &checkout)
+	if checkout.OrderID != "order-1001" || checkout.VendorName != "test-vendor" || checkout.Address != invoice.Address || checkout.PaymentURI != invoice.PaymentURI || checkout.Accepted {
+		t.Fatalf("unexpected checkout: %+v", checkout)
+	}
+	if code, svg := env.Do(t, http.MethodGet, checkoutPath+"/qr?format=svg", "", nil); code != http.StatusOK || !bytes.HasPrefix(svg, []byte("<svg")) {
+		t.Fatalf("checkout QR code: status %d", code)
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(env.Server.URL, "http") + checkoutPath + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Paying the invoice runs through the regular payment callback
+	receives := env.MoneroPay.Receives()
+	jwt := testutil.CallbackJWT(t, receives[len(receives)-1])
+	payment := testutil.Payment("invoice-1", oneXMR/2, 0)
+	status := env.MoneroPay.SetPayments(invoice.Address, payment)
+	if code := env.SendCallback(t, jwt, status, payment); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+
+	var update map[string]any
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("websocket read: %v", err)
+	}
+	if update["accepted"] != true || update["redirect_url"] != "https://shop.example/orders/1001" {
+		t.Fatalf("unexpected checkout update: %v", update)
+	}
+	if _, leaked := update["SubTransactions"]; leaked {
+		t.Fatalf("checkout update exposes the transaction: %v", update)
+	}
+	env.MustDo(t, http.MethodGet, checkoutPath, "", nil, &checkout)
+	if !checkout.Accepted || checkout.Status != models.TransactionStatusPaid {
+		t.Fatalf("checkout after payment: %+v", checkout)
+	}
+
+	env.MustDo(t, http.MethodGet, fmt.Sprintf("/vendor/invoices/%d", invoice.ID), vendorToken, nil, &invoice)
+	if invoice.Status != models.TransactionStatusPaid {
+		t.Fatalf("invoice status: got %s", invoice.Status)
+	}
+
+	if code, _ := env.Do(t, http.MethodGet, "/checkout/unknown-token", "", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown checkout token: got status %d, want 404", code)
+	}
+	for name, body := range map[string]map[string]any{
+		"missing order_id":  {"amount": oneXMR, "currency": "EUR", "amount_in_currency": 150.0, "redirect_url": "https://shop.example"},
+		"relative redirect": {"order_id": "o", "amount": oneXMR, "currency": "EUR", "amount_in_currency": 150.0, "redirect_url": "/thanks"},
+		"javascript scheme": {"order_id": "o", "amount": oneXMR, "currency": "EUR", "amount_in_currency": 150.0, "redirect_url": "javascript://x/%0aalert(1)"},
+	} {
+		if code, _ := env.Do(t, http.MethodPost, "/vendor/invoices", vendorToken, body); code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400", name, code)
+		}
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/invoices", env.LoginPos(t), map[string]any{"order_id": "o"}); code != http.StatusUnauthorized {
+		t.Fatalf("invoice from a POS token: got status %d, want 401", code)
+	}
+
+	// A POS sale is not an invoice
+	var sale struct {
+		ID uint `json:"id"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", env.LoginPos(t), map[string]any{
+		"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &sale)
+	if code, _ := env.Do(t, http.MethodGet, fmt.Sprintf("/vendor/invoices/%d", sale.ID), vendorToken, nil); code != http.StatusNotFound {
+		t.Fatalf("POS sale as invoice: got status %d, want 404", code)
+	}
+}
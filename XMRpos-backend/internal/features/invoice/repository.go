@@ -0,0 +1,55 @@
+package invoice
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+type InvoiceRepository interface {
+	FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error)
+	FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error)
+	FindTransactionByCheckoutToken(ctx context.Context, token string) (*models.Transaction, error)
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func (r *invoiceRepository) FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var vendor models.Vendor
+	if err := r.db.WithContext(ctx).First(&vendor, id).Error; err != nil {
+		return nil, err
+	}
+	return &vendor, nil
+}
+
+func (r *invoiceRepository) FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Preload("LineItems").First(&transaction, id).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *invoiceRepository) FindTransactionByCheckoutToken(ctx context.Context, token string) (*models.Transaction, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Preload("LineItems").Where("checkout_token = ?", token).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
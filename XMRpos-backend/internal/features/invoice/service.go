@@ -0,0 +1,317 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/qr"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/pos"
+	"gorm.io/gorm"
+)
+
+const (
+	maxOrderIDLength    = 128
+	checkoutTokenLength = 32
+)
+
+type InvoiceService struct {
+	repo         InvoiceRepository
+	config       *config.Config
+	transactions *pos.PosService
+}
+
+func NewInvoiceService(repo InvoiceRepository, cfg *config.Config, transactions *pos.PosService) *InvoiceService {
+	return &InvoiceService{repo: repo, config: cfg, transactions: transactions}
+}
+
+type CreateInvoiceRequest struct {
+	OrderID               string                `json:"order_id"`
+	Amount                int64                 `json:"amount"`
+	AmountInCurrency      float64               `json:"amount_in_currency"`
+	Currency              string                `json:"currency"`
+	Description           *string               `json:"description"`
+	RedirectURL           string                `json:"redirect_url"`
+	RequiredConfirmations int64                 `json:"required_confirmations"`
+	LineItems             []pos.LineItemRequest `json:"line_items"`
+	TaxIncluded           bool                  `json:"tax_included"`
+}
+
+// Invoice is what the shop sees of an invoice, amounts are in atomic units
+type Invoice struct {
+	ID                    uint           `json:"id"`
+	OrderID               string         `json:"order_id"`
+	Status                string         `json:"status"`
+	Amount                int64          `json:"amount"`
+	AmountInCurrency      float64        `json:"amount_in_currency"`
+	Currency              string         `json:"currency"`
+	ExchangeRate          float64        `json:"exchange_rate"`
+	Description           *string        `json:"description"`
+	LineItems             []pos.LineItem `json:"line_items"`
+	TaxIncluded           bool           `json:"tax_included"`
+	Address               string         `json:"address"`
+	PaymentURI            string         `json:"payment_uri"`
+	RequiredConfirmations int64          `json:"required_confirmations"`
+	Accepted              bool           `json:"accepted"`
+	Confirmed             bool           `json:"confirmed"`
+	AmountReceived        int64          `json:"amount_received"`
+	AmountOutstanding     int64          `json:"amount_outstanding"`
+	RedirectURL           string         `json:"redirect_url"`
+	CheckoutURL           string         `json:"checkout_url"`
+	CheckoutToken         string         `json:"checkout_token"`
+	ExpiresAt             *time.Time     `json:"expires_at"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// Checkout is the public view of an invoice shown on the checkout page. It leaves out
+// everything the customer does not need, the page URL is all it takes to read it.
+type Checkout struct {
+	OrderID           string         `json:"order_id"`
+	VendorName        string         `json:"vendor_name"`
+	Description       *string        `json:"description"`
+	LineItems         []pos.LineItem `json:"line_items"`
+	TaxIncluded       bool           `json:"tax_included"`
+	Status            string         `json:"status"`
+	Amount            int64          `json:"amount"`
+	AmountInCurrency  float64        `json:"amount_in_currency"`
+	Currency          string         `json:"currency"`
+	Address           string         `json:"address"`
+	PaymentURI        string         `json:"payment_uri"`
+	Accepted          bool           `json:"accepted"`
+	Confirmed         bool           `json:"confirmed"`
+	AmountReceived    int64          `json:"amount_received"`
+	AmountOutstanding int64          `json:"amount_outstanding"`
+	ExpiresAt         *time.Time     `json:"expires_at"`
+	RedirectURL       string         `json:"redirect_url"`
+}
+
+// CreateInvoice issues a transaction that belongs to the vendor rather than to a POS, it goes
+// through the same pricing, confirmation policy and payment pipeline as a POS sale
+func (s *InvoiceService) CreateInvoice(ctx context.Context, vendorID uint, req CreateInvoiceRequest) (*Invoice, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	orderID := strings.TrimSpace(req.OrderID)
+	if orderID == "" || len(orderID) > maxOrderIDLength {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "order_id is required and must be at most 128 characters")
+	}
+
+	redirect, err := url.Parse(strings.TrimSpace(req.RedirectURL))
+	if err != nil || redirect.Host == "" || (redirect.Scheme != "https" && redirect.Scheme != "http") {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "redirect_url must be an absolute http or https URL")
+	}
+	redirectURL := redirect.String()
+
+	if req.RequiredConfirmations > 10 || req.RequiredConfirmations < 0 {
+		return nil, models.NewHTTPError(http.StatusBadRequest, "Required confirmations must be between 0 and 10")
+	}
+
+	token, err := gonanoid.New(checkoutTokenLength)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "error generating checkout token")
+	}
+
+	transaction, err := s.transactions.IssueTransaction(ctx, &models.Transaction{
+		VendorID:              vendorID,
+		Amount:                req.Amount,
+		RequiredConfirmations: req.RequiredConfirmations,
+		Currency:              req.Currency,
+		AmountInCurrency:      req.AmountInCurrency,
+		Description:           req.Description,
+		LineItems:             pos.NewLineItems(req.LineItems),
+		TaxIncluded:           req.TaxIncluded,
+		OrderID:               &orderID,
+		RedirectURL:           &redirectURL,
+		CheckoutToken:         &token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := s.newInvoice(transaction)
+	return &invoice, nil
+}
+
+func (s *InvoiceService) GetInvoice(ctx context.Context, vendorID uint, invoiceID uint) (*Invoice, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	transaction, err := s.repo.FindTransactionByID(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.NewHTTPError(http.StatusNotFound, "Invoice not found")
+		}
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	// POS sales are not invoices and other vendors' invoices do not exist for this one
+	if transaction.VendorID != vendorID || transaction.CheckoutToken == nil {
+		return nil, models.NewHTTPError(http.StatusNotFound, "Invoice not found")
+	}
+
+	invoice := s.newInvoice(transaction)
+	return &invoice, nil
+}
+
+// FindCheckout resolves the token of a checkout page URL to its invoice
+func (s *InvoiceService) FindCheckout(ctx context.Context, token string) (*models.Transaction, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if token == "" || len(token) > 64 {
+		return nil, models.NewHTTPError(http.StatusNotFound, "Checkout not found")
+	}
+	transaction, err := s.repo.FindTransactionByCheckoutToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.NewHTTPError(http.StatusNotFound, "Checkout not found")
+		}
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	return transaction, nil
+}
+
+func (s *InvoiceService) GetCheckout(ctx context.Context, token string) (*Checkout, *models.HTTPError) {
+	transaction, httpErr := s.FindCheckout(ctx, token)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	vendor, err := s.repo.FindVendorByID(ctx, transaction.VendorID)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	checkout := newCheckout(vendor.Name, transaction)
+	return &checkout, nil
+}
+
+// CheckoutUpdates resolves a checkout token for the status stream, render turns every
+// transaction update into the public view before it is pushed to the page
+func (s *InvoiceService) CheckoutUpdates(ctx context.Context, token string) (uint, func(transaction *models.Transaction) interface{}, *models.HTTPError) {
+	transaction, httpErr := s.FindCheckout(ctx, token)
+	if httpErr != nil {
+		return 0, nil, httpErr
+	}
+
+	vendor, err := s.repo.FindVendorByID(ctx, transaction.VendorID)
+	if err != nil {
+		return 0, nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	// Updates pushed by the payment pipeline come without the cart, it never changes after checkout
+	lineItems := transaction.LineItems
+	render := func(transaction *models.Transaction) interface{} {
+		if len(transaction.LineItems) == 0 {
+			updated := *transaction
+			updated.LineItems = lineItems
+			transaction = &updated
+		}
+		return newCheckout(vendor.Name, transaction)
+	}
+	return transaction.ID, render, nil
+}
+
+// GetCheckoutQRCode renders the payment URI of the invoice behind a checkout token
+func (s *InvoiceService) GetCheckoutQRCode(ctx context.Context, token string, opts qr.Options) ([]byte, string, *models.HTTPError) {
+	transaction, httpErr := s.FindCheckout(ctx, token)
+	if httpErr != nil {
+		return nil, "", httpErr
+	}
+	if transaction.PaymentURI == nil {
+		return nil, "", models.NewHTTPError(http.StatusNotFound, "Invoice has no payment URI")
+	}
+
+	image, contentType, err := qr.Render(*transaction.PaymentURI, opts)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "Failed to render QR code: "+err.Error())
+	}
+	return image, contentType, nil
+}
+
+// CheckoutURL is the hosted checkout page of an invoice
+func (s *InvoiceService) CheckoutURL(token string) string {
+	base := s.config.CheckoutPageURL
+	if base == "" {
+		base = config.DefaultCheckoutPageURL
+	}
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+func (s *InvoiceService) newInvoice(transaction *models.Transaction) Invoice {
+	invoice := Invoice{
+		ID:                    transaction.ID,
+		Status:                transaction.Status,
+		Amount:                transaction.Amount,
+		AmountInCurrency:      transaction.AmountInCurrency,
+		Currency:              transaction.Currency,
+		ExchangeRate:          transaction.ExchangeRate,
+		Description:           transaction.Description,
+		LineItems:             pos.LineItemViews(transaction.LineItems),
+		TaxIncluded:           transaction.TaxIncluded,
+		RequiredConfirmations: transaction.RequiredConfirmations,
+		Accepted:              transaction.Accepted,
+		Confirmed:             transaction.Confirmed,
+		AmountReceived:        transaction.AmountReceived,
+		AmountOutstanding:     transaction.AmountOutstanding,
+		ExpiresAt:             transaction.ExpiresAt,
+		CreatedAt:             transaction.CreatedAt,
+	}
+	if transaction.OrderID != nil {
+		invoice.OrderID = *transaction.OrderID
+	}
+	if transaction.SubAddress != nil {
+		invoice.Address = *transaction.SubAddress
+	}
+	if transaction.PaymentURI != nil {
+		invoice.PaymentURI = *transaction.PaymentURI
+	}
+	if transaction.RedirectURL != nil {
+		invoice.RedirectURL = *transaction.RedirectURL
+	}
+	if transaction.CheckoutToken != nil {
+		invoice.CheckoutToken = *transaction.CheckoutToken
+		invoice.CheckoutURL = s.CheckoutURL(*transaction.CheckoutToken)
+	}
+	return invoice
+}
+
+// newCheckout builds the public view, it is also what the checkout websocket pushes on every update
+func newCheckout(vendorName string, transaction *models.Transaction) Checkout {
+	checkout := Checkout{
+		VendorName:        vendorName,
+		Description:       transaction.Description,
+		LineItems:         pos.LineItemViews(transaction.LineItems),
+		TaxIncluded:       transaction.TaxIncluded,
+		Status:            transaction.Status,
+		Amount:            transaction.Amount,
+		AmountInCurrency:  transaction.AmountInCurrency,
+		Currency:          transaction.Currency,
+		Accepted:          transaction.Accepted,
+		Confirmed:         transaction.Confirmed,
+		AmountReceived:    transaction.AmountReceived,
+		AmountOutstanding: transaction.AmountOutstanding,
+		ExpiresAt:         transaction.ExpiresAt,
+	}
+	if transaction.OrderID != nil {
+		checkout.OrderID = *transaction.OrderID
+	}
+	if transaction.SubAddress != nil {
+		checkout.Address = *transaction.SubAddress
+	}
+	if transaction.PaymentURI != nil {
+		checkout.PaymentURI = *transaction.PaymentURI
+	}
+	if transaction.RedirectURL != nil {
+		checkout.RedirectURL = *transaction.RedirectURL
+	}
+	return checkout
+}
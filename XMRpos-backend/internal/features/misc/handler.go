@@ -7,7 +7,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
 )
 
 type MiscHandler struct {
@@ -19,8 +19,9 @@ func NewMiscHandler(service *MiscService) *MiscHandler {
 }
 
 type Services struct {
-	Postgresql bool                     `json:"postgresql"`
-	MoneroPay  moneropay.HealthResponse `json:"MoneroPay"`
+	Postgresql bool `json:"postgresql"`
+	// MoneroPay holds the health of the configured payment backend, the key is kept for the POS app
+	MoneroPay payment.Health `json:"MoneroPay"`
 }
 
 type HealthResponse struct {
@@ -3,30 +3,30 @@ package misc
 import (
 	"context"
 
-	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
 )
 
 type MiscService struct {
-	repo      MiscRepository
-	config    *config.Config
-	moneroPay *moneropay.MoneroPayAPIClient
+	repo     MiscRepository
+	config   *config.Config
+	payments payment.PaymentBackend
 }
 
-func NewMiscService(repo MiscRepository, cfg *config.Config, moneroPay *moneropay.MoneroPayAPIClient) *MiscService {
-	return &MiscService{repo: repo, config: cfg, moneroPay: moneroPay}
+func NewMiscService(repo MiscRepository, cfg *config.Config, payments payment.PaymentBackend) *MiscService {
+	return &MiscService{repo: repo, config: cfg, payments: payments}
 }
 
 // Check if the vendor and POS are authorized for the transaction
 func (s *MiscService) GetHealth(ctx context.Context) HealthResponse {
 	h := HealthResponse{}
 
-	// Check MoneroPay service health
-	mp, mpErr := s.moneroPay.GetHealth(ctx)
-	if mpErr != nil {
+	// Check payment backend health
+	backend, backendErr := s.payments.GetHealth(ctx)
+	if backendErr != nil {
 		h.Services.MoneroPay.Status = 503
 	} else {
-		h.Services.MoneroPay = *mp
+		h.Services.MoneroPay = *backend
 	}
 
 	// Check PostgreSQL service health
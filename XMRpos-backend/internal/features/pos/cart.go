@@ -0,0 +1,183 @@
+package pos
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+const (
+	maxLineItems       = 100
+	maxLineItemName    = 128
+	maxLineItemSKU     = 64
+	maxLineItemValue   = 1_000_000_000 // Upper bound for quantities and unit prices
+	fiatDecimals       = 2
+	xmrDecimals        = 12
+	maxTaxRatePercent  = 100
+	cartTotalTolerance = 0.5 // In units of the last decimal, absorbs the rounding of the client
+)
+
+// LineItemRequest is one position of an itemized cart, as sent by the POS and the invoice API.
+// Prices are in the currency of the transaction, the discount is an amount taken off the line.
+type LineItemRequest struct {
+	SKU       *string `json:"sku"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	TaxRate   float64 `json:"tax_rate"`
+	Discount  float64 `json:"discount"`
+}
+
+// LineItem is how a stored line item is shown to the POS, the vendor and the checkout page
+type LineItem struct {
+	SKU       *string `json:"sku,omitempty"`
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	TaxRate   float64 `json:"tax_rate"`
+	Discount  float64 `json:"discount"`
+	Tax       float64 `json:"tax"`
+	Total     float64 `json:"total"`
+}
+
+// NewLineItems turns the cart of a request into the models IssueTransaction prices
+func NewLineItems(items []LineItemRequest) []*models.LineItem {
+	if len(items) == 0 {
+		return nil
+	}
+	out := make([]*models.LineItem, 0, len(items))
+	for _, item := range items {
+		lineItem := &models.LineItem{
+			Name:      strings.TrimSpace(item.Name),
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			TaxRate:   item.TaxRate,
+			Discount:  item.Discount,
+		}
+		if item.SKU != nil {
+			if sku := strings.TrimSpace(*item.SKU); sku != "" {
+				lineItem.SKU = &sku
+			}
+		}
+		out = append(out, lineItem)
+	}
+	return out
+}
+
+// LineItemViews converts stored line items for JSON responses, it never returns nil
+func LineItemViews(items []*models.LineItem) []LineItem {
+	out := make([]LineItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, LineItem{
+			SKU:       item.SKU,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			TaxRate:   item.TaxRate,
+			Discount:  item.Discount,
+			Tax:       item.Tax,
+			Total:     item.Total,
+		})
+	}
+	return out
+}
+
+// DescribeLineItems summarizes a cart in one line, e.g. "2 x Coffee, 1 x Cake"
+func DescribeLineItems(items []*models.LineItem) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("%g x %s", item.Quantity, item.Name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ExportDescription is the Description column of a CSV export row, it lists what was sold
+func ExportDescription(items []*models.LineItem) string {
+	description := DescribeLineItems(items)
+	if strings.ContainsAny(description, ",\"\r\n") {
+		return `"` + strings.ReplaceAll(description, `"`, `""`) + `"`
+	}
+	return description
+}
+
+// priceCart validates the line items of a transaction and computes their tax and totals. The sum
+// of the lines is the price: it fills an amount the caller left at 0 and has to match one it sent.
+func priceCart(transaction *models.Transaction) error {
+	if len(transaction.LineItems) == 0 {
+		return nil
+	}
+	if len(transaction.LineItems) > maxLineItems {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("A cart holds at most %d line items", maxLineItems))
+	}
+
+	inXMR := transaction.Currency == "" || strings.EqualFold(transaction.Currency, "XMR")
+	decimals := fiatDecimals
+	if inXMR {
+		decimals = xmrDecimals
+	}
+
+	var total float64
+	for i, item := range transaction.LineItems {
+		if err := validateLineItem(item); err != nil {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line_items[%d]: %s", i, err))
+		}
+
+		gross := roundTo(item.Quantity*item.UnitPrice, decimals)
+		if item.Discount > gross {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line_items[%d]: discount exceeds the line amount", i))
+		}
+		net := roundTo(gross-item.Discount, decimals)
+		if transaction.TaxIncluded {
+			item.Tax = roundTo(net-net/(1+item.TaxRate/100), decimals)
+			item.Total = net
+		} else {
+			item.Tax = roundTo(net*item.TaxRate/100, decimals)
+			item.Total = roundTo(net+item.Tax, decimals)
+		}
+		total += item.Total
+	}
+	total = roundTo(total, decimals)
+
+	if inXMR {
+		amount := int64(math.Round(total * float64(moneroAtomicUnitsPerXMR)))
+		if transaction.Amount == 0 {
+			transaction.Amount = amount
+		} else if diff := transaction.Amount - amount; diff > 1 || diff < -1 {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Amount does not match the line items total of %.12f XMR", total))
+		}
+		return nil
+	}
+
+	if transaction.AmountInCurrency == 0 {
+		transaction.AmountInCurrency = total
+	} else if math.Abs(transaction.AmountInCurrency-total) > cartTotalTolerance*math.Pow10(-decimals) {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("amount_in_currency does not match the line items total of %.2f %s", total, transaction.Currency))
+	}
+	return nil
+}
+
+func validateLineItem(item *models.LineItem) error {
+	switch {
+	case item.Name == "" || len(item.Name) > maxLineItemName:
+		return fmt.Errorf("name is required and must be at most %d characters", maxLineItemName)
+	case item.SKU != nil && len(*item.SKU) > maxLineItemSKU:
+		return fmt.Errorf("sku must be at most %d characters", maxLineItemSKU)
+	case !(item.Quantity > 0) || item.Quantity > maxLineItemValue:
+		return fmt.Errorf("quantity must be positive")
+	case !(item.UnitPrice >= 0) || item.UnitPrice > maxLineItemValue:
+		return fmt.Errorf("unit_price must not be negative")
+	case !(item.TaxRate >= 0) || item.TaxRate > maxTaxRatePercent:
+		return fmt.Errorf("tax_rate must be between 0 and %d percent", maxTaxRatePercent)
+	case !(item.Discount >= 0):
+		return fmt.Errorf("discount must not be negative")
+	}
+	return nil
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,94 @@
+package pos
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+func TestPriceCartTax(t *testing.T) {
+	items := func() []*models.LineItem {
+		return []*models.LineItem{
+			{Name: "Coffee", Quantity: 2, UnitPrice: 3.5, TaxRate: 19, Discount: 1},
+			{Name: "Cake", Quantity: 1, UnitPrice: 4.2},
+		}
+	}
+
+	// Tax on top of the net price
+	excluded := &models.Transaction{Currency: "EUR", LineItems: items()}
+	if err := priceCart(excluded); err != nil {
+		t.Fatal(err)
+	}
+	if line := excluded.LineItems[0]; line.Tax != 1.14 || line.Total != 7.14 {
+		t.Fatalf("tax excluded: got tax %v, total %v", line.Tax, line.Total)
+	}
+	if excluded.AmountInCurrency != 11.34 {
+		t.Fatalf("tax excluded: got amount %v, want 11.34", excluded.AmountInCurrency)
+	}
+
+	// Tax contained in the price
+	included := &models.Transaction{Currency: "EUR", TaxIncluded: true, LineItems: items()}
+	if err := priceCart(included); err != nil {
+		t.Fatal(err)
+	}
+	if line := included.LineItems[0]; line.Tax != 0.96 || line.Total != 6 {
+		t.Fatalf("tax included: got tax %v, total %v", line.Tax, line.Total)
+	}
+	if included.AmountInCurrency != 10.2 {
+		t.Fatalf("tax included: got amount %v, want 10.2", included.AmountInCurrency)
+	}
+}
+
+func TestPriceCartAmounts(t *testing.T) {
+	cart := func() []*models.LineItem {
+		return []*models.LineItem{{Name: "Sticker", Quantity: 3, UnitPrice: 0.1}}
+	}
+
+	// Carts in XMR fill the atomic amount, a sent amount may be off by one unit of rounding
+	xmr := &models.Transaction{LineItems: cart()}
+	if err := priceCart(xmr); err != nil || xmr.Amount != 300_000_000_000 {
+		t.Fatalf("xmr cart: got amount %d, %v", xmr.Amount, err)
+	}
+	if err := priceCart(&models.Transaction{Amount: 300_000_000_001, LineItems: cart()}); err != nil {
+		t.Fatalf("rounded xmr amount rejected: %v", err)
+	}
+	if err := priceCart(&models.Transaction{Amount: 300_000_000_002, LineItems: cart()}); err == nil {
+		t.Fatal("mismatching xmr amount accepted")
+	}
+
+	// Fiat amounts have to match the total within half a cent
+	if err := priceCart(&models.Transaction{Currency: "USD", AmountInCurrency: 0.304, LineItems: cart()}); err != nil {
+		t.Fatalf("fiat amount within tolerance rejected: %v", err)
+	}
+	if err := priceCart(&models.Transaction{Currency: "USD", AmountInCurrency: 0.31, LineItems: cart()}); err == nil {
+		t.Fatal("mismatching fiat amount accepted")
+	}
+}
+
+func TestPriceCartRejectsInvalidLines(t *testing.T) {
+	sku := strings.Repeat("x", maxLineItemSKU+1)
+	for name, item := range map[string]*models.LineItem{
+		"no name":           {Quantity: 1, UnitPrice: 1},
+		"long sku":          {Name: "a", SKU: &sku, Quantity: 1, UnitPrice: 1},
+		"zero quantity":     {Name: "a", UnitPrice: 1},
+		"NaN quantity":      {Name: "a", Quantity: math.NaN(), UnitPrice: 1},
+		"negative price":    {Name: "a", Quantity: 1, UnitPrice: -1},
+		"tax over 100":      {Name: "a", Quantity: 1, UnitPrice: 1, TaxRate: 101},
+		"negative discount": {Name: "a", Quantity: 1, UnitPrice: 1, Discount: -1},
+		"discount too high": {Name: "a", Quantity: 1, UnitPrice: 1, Discount: 2},
+	} {
+		if err := priceCart(&models.Transaction{Currency: "EUR", LineItems: []*models.LineItem{item}}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	tooMany := make([]*models.LineItem, maxLineItems+1)
+	for i := range tooMany {
+		tooMany[i] = &models.LineItem{Name: "a", Quantity: 1, UnitPrice: 1}
+	}
+	if err := priceCart(&models.Transaction{Currency: "EUR", LineItems: tooMany}); err == nil {
+		t.Fatal("oversized cart accepted")
+	}
+}
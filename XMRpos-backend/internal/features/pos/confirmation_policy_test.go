@@ -0,0 +1,51 @@
+package pos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+func TestApplyConfirmationPolicy(t *testing.T) {
+	vendor := &models.Vendor{
+		ConfirmationCurrency: "USD",
+		DefaultConfirmations: 10,
+		ConfirmationTiers: []models.ConfirmationTier{
+			{BelowAmount: 20, Confirmations: 0},
+			{BelowAmount: 500, Confirmations: 2},
+		},
+	}
+	tests := []struct {
+		name      string
+		currency  string
+		amount    float64
+		requested int64
+		want      int64
+	}{
+		{"small amount", "USD", 5, 0, 0},
+		{"tier boundary", "USD", 20, 0, 2},
+		{"above all tiers", "USD", 800, 0, 10},
+		{"POS asks for more", "USD", 5, 3, 3},
+		{"other currency without rates", "EUR", 5, 0, 10},
+	}
+	s := &PosService{}
+	for _, tt := range tests {
+		transaction := &models.Transaction{Amount: moneroAtomicUnitsPerXMR, Currency: tt.currency, AmountInCurrency: tt.amount, RequiredConfirmations: tt.requested}
+		s.applyConfirmationPolicy(context.Background(), vendor, transaction)
+		if transaction.RequiredConfirmations != tt.want {
+			t.Errorf("%s: got %d confirmations, want %d", tt.name, transaction.RequiredConfirmations, tt.want)
+		}
+		if transaction.PayableConfirmations != models.MinPayableConfirmations {
+			t.Errorf("%s: payable confirmations %d below the minimum", tt.name, transaction.PayableConfirmations)
+		}
+	}
+
+	// XMR tiers are compared with the amount itself
+	vendor.ConfirmationCurrency = "XMR"
+	transaction := &models.Transaction{Amount: moneroAtomicUnitsPerXMR / 10, Currency: "USD", AmountInCurrency: 30}
+	s.applyConfirmationPolicy(context.Background(), vendor, transaction)
+	if transaction.RequiredConfirmations != 0 {
+		t.Fatalf("XMR tier: got %d confirmations, want 0", transaction.RequiredConfirmations)
+	}
+}
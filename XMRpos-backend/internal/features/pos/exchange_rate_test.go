@@ -0,0 +1,105 @@
+package pos_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestExchangeRates(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.ExchangeRateProvider = config.ExchangeRateProviderFixed
+		cfg.FixedExchangeRates = map[string]float64{"EUR": 150}
+		cfg.ExchangeRateTolerancePercent = 2
+	})
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	var quotes struct {
+		Rates []struct {
+			Currency string  `json:"currency"`
+			Rate     float64 `json:"rate"`
+			Source   string  `json:"source"`
+		} `json:"rates"`
+	}
+	env.MustDo(t, http.MethodGet, "/pos/exchange-rates?currencies=eur", posToken, nil, &quotes)
+	if len(quotes.Rates) != 1 || quotes.Rates[0].Currency != "EUR" || quotes.Rates[0].Rate != 150 || quotes.Rates[0].Source != "fixed" {
+		t.Fatalf("unexpected rates: %+v", quotes)
+	}
+
+	// The XMR amount is computed server-side from the fiat amount
+	var created struct {
+		ID           uint    `json:"id"`
+		Amount       int64   `json:"amount"`
+		ExchangeRate float64 `json:"exchange_rate"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount_in_currency": 75.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+	if created.Amount != oneXMR/2 || created.ExchangeRate != 150 {
+		t.Fatalf("unexpected quote: %+v", created)
+	}
+
+	// A POS amount within the tolerance is accepted and the server rate is recorded
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR + oneXMR/100, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+	tx, _ := env.Store.Transaction(created.ID)
+	if tx.ExchangeRate != 150 || tx.ExchangeRateSource != "fixed" || tx.ExchangeRateAt == nil {
+		t.Fatalf("rate not recorded: %+v", tx)
+	}
+
+	// A manipulated rate is rejected
+	if code, _ := env.Do(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 10, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}); code != http.StatusBadRequest {
+		t.Fatalf("manipulated amount: got status %d, want 400", code)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount_in_currency": 10.0, "currency": "GBP", "required_confirmations": 0,
+	}); code != http.StatusBadRequest {
+		t.Fatalf("unknown currency: got status %d, want 400", code)
+	}
+
+	var listed struct {
+		Pending []struct {
+			ID                 uint    `json:"id"`
+			ExchangeRate       float64 `json:"exchange_rate"`
+			ExchangeRateSource string  `json:"exchange_rate_source"`
+		} `json:"pending_transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/transactions", vendorToken, nil, &listed)
+	if len(listed.Pending) != 2 || listed.Pending[0].ExchangeRate != 150 || listed.Pending[0].ExchangeRateSource != "fixed" {
+		t.Fatalf("unexpected vendor transactions: %+v", listed)
+	}
+}
+
+func TestExchangeRatesWithoutProvider(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+
+	// Without a provider the rate implied by the POS request is recorded
+	var created struct {
+		ID           uint    `json:"id"`
+		ExchangeRate float64 `json:"exchange_rate"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 2, "amount_in_currency": 80.0, "currency": "USD", "required_confirmations": 0,
+	}, &created)
+	tx, _ := env.Store.Transaction(created.ID)
+	if created.ExchangeRate != 160 || tx.ExchangeRateSource != models.ExchangeRateSourcePos {
+		t.Fatalf("unexpected recorded rate: %+v", tx)
+	}
+
+	if code, _ := env.Do(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount_in_currency": 80.0, "currency": "USD", "required_confirmations": 0,
+	}); code != http.StatusBadRequest {
+		t.Fatalf("missing amount: got status %d, want 400", code)
+	}
+	if code, _ := env.Do(t, http.MethodGet, "/pos/exchange-rates?currencies=USD", posToken, nil); code != http.StatusNotFound {
+		t.Fatalf("exchange rates without provider: got status %d, want 404", code)
+	}
+}
@@ -0,0 +1,103 @@
+package pos_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+func TestInvoiceExpiry(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+	adminToken := env.LoginAdmin(t)
+
+	var created struct {
+		ID        uint      `json:"id"`
+		Address   string    `json:"address"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+	if until := time.Until(created.ExpiresAt); until < 14*time.Minute || until > 15*time.Minute {
+		t.Fatalf("expected the default 15 minute quote window, expires in %s", until)
+	}
+	jwt := testutil.CallbackJWT(t, env.MoneroPay.Receives()[0])
+
+	header := http.Header{"Authorization": {"Bearer " + posToken}}
+	wsURL := "ws" + strings.TrimPrefix(env.Server.URL, "http") + fmt.Sprintf("/pos/ws/transaction?transaction_id=%d", created.ID)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer conn.Close()
+
+	// The quote window closes: the POS is told and the row is kept
+	env.Store.ExpireTransactionAt(created.ID, time.Now().Add(-time.Second))
+	var update models.Transaction
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("websocket read: %v", err)
+	}
+	if update.Status != models.TransactionStatusExpired {
+		t.Fatalf("expected expired websocket update, got %s", update.Status)
+	}
+
+	// A payment after expiry is flagged instead of credited
+	late := testutil.Payment("late-1", oneXMR, 10)
+	status := env.MoneroPay.SetPayments(created.Address, late)
+	if code := env.SendCallback(t, jwt, status, late); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+	tx, _ := env.Store.Transaction(created.ID)
+	if tx.Status != models.TransactionStatusLatePayment || tx.Accepted || !tx.Confirmed || tx.AmountReceived != oneXMR {
+		t.Fatalf("expected flagged late payment: %+v", tx)
+	}
+
+	var balance struct {
+		Balance int64 `json:"balance"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != 0 {
+		t.Fatalf("late payment credited before resolution: %d", balance.Balance)
+	}
+
+	var latePayments struct {
+		Transactions []struct {
+			ID             uint  `json:"id"`
+			AmountReceived int64 `json:"amount_received"`
+		} `json:"transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/admin/late-payments", adminToken, nil, &latePayments)
+	if len(latePayments.Transactions) != 1 || latePayments.Transactions[0].ID != created.ID {
+		t.Fatalf("unexpected late payments: %+v", latePayments)
+	}
+
+	// Resolving credits the vendor once
+	var resolved struct {
+		Status string `json:"status"`
+	}
+	env.MustDo(t, http.MethodPost, "/admin/resolve-late-payment", adminToken, map[string]any{"transaction_id": created.ID}, &resolved)
+	if resolved.Status != models.TransactionStatusPaid {
+		t.Fatalf("resolved status: got %s, want paid", resolved.Status)
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/balance", vendorToken, nil, &balance)
+	if balance.Balance != oneXMR {
+		t.Fatalf("balance after resolution: got %d, want %d", balance.Balance, oneXMR)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/admin/resolve-late-payment", adminToken, map[string]any{"transaction_id": created.ID}); code != http.StatusBadRequest {
+		t.Fatalf("second resolution: got status %d, want 400", code)
+	}
+	if code, _ := env.Do(t, http.MethodGet, "/admin/late-payments", vendorToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("vendor listing late payments: got status %d, want 401", code)
+	}
+}
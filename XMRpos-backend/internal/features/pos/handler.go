@@ -7,10 +7,14 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/qr"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rates"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/receipt"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
 )
 
@@ -23,16 +27,38 @@ func NewPosHandler(service *PosService) *PosHandler {
 }
 
 type createTransactionRequest struct {
-	Amount                int64   `json:"amount"`
-	Description           *string `json:"description"`
-	AmountInCurrency      float64 `json:"amount_in_currency"`
-	Currency              string  `json:"currency"`
-	RequiredConfirmations int64   `json:"required_confirmations"`
+	Amount                int64             `json:"amount"`
+	Description           *string           `json:"description"`
+	AmountInCurrency      float64           `json:"amount_in_currency"`
+	Currency              string            `json:"currency"`
+	RequiredConfirmations int64             `json:"required_confirmations"`
+	LineItems             []LineItemRequest `json:"line_items"`
+	TaxIncluded           bool              `json:"tax_included"`    // The unit prices already contain the tax
+	Tip                   int64             `json:"tip"`             // Atomic units on top of amount
+	TipInCurrency         float64           `json:"tip_in_currency"` // Fiat on top of amount_in_currency
 }
 
 type createTransactionResponse struct {
-	Id      uint   `json:"id"`
-	Address string `json:"address"`
+	Id                    uint       `json:"id"`
+	Address               string     `json:"address"`
+	Amount                int64      `json:"amount"`             // Including the tip
+	AmountInCurrency      float64    `json:"amount_in_currency"` // Including the tip, the line items total plus tip when the cart is itemized
+	TipAmount             int64      `json:"tip_amount"`
+	TipInCurrency         float64    `json:"tip_in_currency"`
+	ExchangeRate          float64    `json:"exchange_rate"`
+	ExpiresAt             *time.Time `json:"expires_at"`
+	RequiredConfirmations int64      `json:"required_confirmations"` // After the vendor's policy was applied
+	PaymentURI            string     `json:"payment_uri"`
+	LineItems             []LineItem `json:"line_items"`
+}
+
+type emailReceiptRequest struct {
+	TransactionID uint   `json:"transaction_id"`
+	Email         string `json:"email"`
+}
+
+type exchangeRatesResponse struct {
+	Rates []rates.Quote `json:"rates"`
 }
 
 type listTransactionsResponse struct {
@@ -72,15 +98,29 @@ func (h *PosHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	vendorIDPtr, _ := r.Context().Value(models.ClaimsVendorIDKey).(*uint)
 	posIDPtr, _ := r.Context().Value(models.ClaimsPosIDKey).(*uint)
 
-	id, address, err := h.service.CreateTransaction(ctx, *vendorIDPtr, *posIDPtr, req.Amount, req.Description, req.AmountInCurrency, req.Currency, req.RequiredConfirmations)
+	transaction, err := h.service.CreateTransaction(ctx, *vendorIDPtr, *posIDPtr, req.Amount, req.Description, req.AmountInCurrency, req.Currency, req.RequiredConfirmations, NewLineItems(req.LineItems), req.TaxIncluded, req.Tip, req.TipInCurrency)
 	if err != nil {
+		var httpErr *models.HTTPError
+		if errors.As(err, &httpErr) {
+			http.Error(w, httpErr.Message, httpErr.Code)
+			return
+		}
 		http.Error(w, "Failed to create transaction", http.StatusInternalServerError)
 		return
 	}
 
 	resp := createTransactionResponse{
-		Id:      id,
-		Address: address,
+		Id:                    transaction.ID,
+		Address:               *transaction.SubAddress,
+		Amount:                transaction.Amount,
+		AmountInCurrency:      transaction.AmountInCurrency,
+		TipAmount:             transaction.TipAmount,
+		TipInCurrency:         transaction.TipInCurrency,
+		ExchangeRate:          transaction.ExchangeRate,
+		ExpiresAt:             transaction.ExpiresAt,
+		RequiredConfirmations: transaction.RequiredConfirmations,
+		PaymentURI:            *transaction.PaymentURI,
+		LineItems:             LineItemViews(transaction.LineItems),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -123,6 +163,128 @@ func (h *PosHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(transaction)
 }
 
+// GetTransactionQRCode renders the payment URI as PNG or SVG, e.g. ?format=svg&size=512
+func (h *PosHandler) GetTransactionQRCode(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	transactionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := qr.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "pos" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vendorIDPtr, _ := r.Context().Value(models.ClaimsVendorIDKey).(*uint)
+	posIDPtr, _ := r.Context().Value(models.ClaimsPosIDKey).(*uint)
+	if vendorIDPtr == nil || posIDPtr == nil {
+		http.Error(w, "Vendor ID and POS ID are required", http.StatusBadRequest)
+		return
+	}
+
+	image, contentType, httpErr := h.service.GetTransactionQRCode(ctx, uint(transactionID), *vendorIDPtr, *posIDPtr, opts)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(image)
+}
+
+// GetTransactionReceipt renders the receipt of a paid transaction, ?format=escpos (default), pdf or txt
+func (h *PosHandler) GetTransactionReceipt(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	transactionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	format, err := receipt.ParseFormat(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "pos" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vendorIDPtr, _ := r.Context().Value(models.ClaimsVendorIDKey).(*uint)
+	posIDPtr, _ := r.Context().Value(models.ClaimsPosIDKey).(*uint)
+	if vendorIDPtr == nil || posIDPtr == nil {
+		http.Error(w, "Vendor ID and POS ID are required", http.StatusBadRequest)
+		return
+	}
+
+	data, contentType, httpErr := h.service.GetTransactionReceipt(ctx, uint(transactionID), *vendorIDPtr, *posIDPtr, format)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+// EmailReceipt sends the receipt of a confirmed transaction to the customer's email address
+func (h *PosHandler) EmailReceipt(w http.ResponseWriter, r *http.Request) {
+	// Mail servers may be slow to accept a message
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB cap
+
+	var req emailReceiptRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TransactionID == 0 {
+		http.Error(w, "Transaction ID is required", http.StatusBadRequest)
+		return
+	}
+
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "pos" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vendorIDPtr, _ := r.Context().Value(models.ClaimsVendorIDKey).(*uint)
+	posIDPtr, _ := r.Context().Value(models.ClaimsPosIDKey).(*uint)
+	if vendorIDPtr == nil || posIDPtr == nil {
+		http.Error(w, "Vendor ID and POS ID are required", http.StatusBadRequest)
+		return
+	}
+
+	if httpErr := h.service.EmailReceipt(ctx, req.TransactionID, *vendorIDPtr, *posIDPtr, strings.TrimSpace(req.Email)); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	io.Copy(io.Discard, r.Body)
+}
+
 func (h *PosHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
@@ -190,3 +352,35 @@ func (h *PosHandler) ExportTransactions(w http.ResponseWriter, r *http.Request)
 	resp := exportTransactionsResponse{CSVData: csvData}
 	_ = json.NewEncoder(w).Encode(resp)
 }
+
+func (h *PosHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	role, ok := utils.GetClaimFromContext(r.Context(), models.ClaimsRoleKey)
+	if !ok || role != "pos" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var currencies []string
+	for _, currency := range strings.Split(r.URL.Query().Get("currencies"), ",") {
+		if currency = strings.TrimSpace(currency); currency != "" {
+			currencies = append(currencies, currency)
+		}
+	}
+	if len(currencies) == 0 {
+		http.Error(w, "currencies is required", http.StatusBadRequest)
+		return
+	}
+
+	quotes, httpErr := h.service.GetExchangeRates(ctx, currencies)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exchangeRatesResponse{Rates: quotes})
+}
@@ -0,0 +1,151 @@
+package pos_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestLineItems(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	cart := []map[string]any{
+		{"sku": "COF", "name": "Coffee", "quantity": 2, "unit_price": 3.5, "tax_rate": 19},
+		{"name": "Cake", "quantity": 1, "unit_price": 5.0, "tax_rate": 7, "discount": 1.0},
+	}
+	type lineItem struct {
+		SKU      *string `json:"sku"`
+		Name     string  `json:"name"`
+		Quantity float64 `json:"quantity"`
+		Tax      float64 `json:"tax"`
+		Total    float64 `json:"total"`
+	}
+	var sale struct {
+		ID               uint       `json:"id"`
+		Address          string     `json:"address"`
+		AmountInCurrency float64    `json:"amount_in_currency"`
+		LineItems        []lineItem `json:"line_items"`
+	}
+	// The cart total becomes the fiat amount: 7.00 + 1.33 tax and 4.00 + 0.28 tax
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 10, "currency": "EUR", "required_confirmations": 0, "line_items": cart,
+	}, &sale)
+	if sale.AmountInCurrency != 12.61 || len(sale.LineItems) != 2 {
+		t.Fatalf("unexpected sale: %+v", sale)
+	}
+	if coffee := sale.LineItems[0]; coffee.SKU == nil || *coffee.SKU != "COF" || coffee.Tax != 1.33 || coffee.Total != 8.33 {
+		t.Fatalf("unexpected coffee line: %+v", coffee)
+	}
+	if cake := sale.LineItems[1]; cake.SKU != nil || cake.Tax != 0.28 || cake.Total != 4.28 {
+		t.Fatalf("unexpected cake line: %+v", cake)
+	}
+	if tx, _ := env.Store.Transaction(sale.ID); len(tx.LineItems) != 2 || tx.LineItems[0].TransactionID != sale.ID {
+		t.Fatalf("line items not stored: %+v", tx.LineItems)
+	}
+
+	// Prices that already contain the tax only have it extracted
+	var included struct {
+		AmountInCurrency float64    `json:"amount_in_currency"`
+		LineItems        []lineItem `json:"line_items"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 20, "amount_in_currency": 5.95, "currency": "EUR", "required_confirmations": 0, "tax_included": true,
+		"line_items": []map[string]any{{"name": "Beer", "quantity": 1, "unit_price": 5.95, "tax_rate": 19}},
+	}, &included)
+	if included.AmountInCurrency != 5.95 || included.LineItems[0].Tax != 0.95 || included.LineItems[0].Total != 5.95 {
+		t.Fatalf("unexpected tax included sale: %+v", included)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"total mismatch":     {"amount": oneXMR / 10, "amount_in_currency": 20.0, "currency": "EUR", "line_items": cart},
+		"discount too large": {"amount": oneXMR / 10, "currency": "EUR", "line_items": []map[string]any{{"name": "Tea", "quantity": 1, "unit_price": 2.0, "discount": 3.0}}},
+		"tax rate":           {"amount": oneXMR / 10, "currency": "EUR", "line_items": []map[string]any{{"name": "Tea", "quantity": 1, "unit_price": 2.0, "tax_rate": 150}}},
+		"missing name":       {"amount": oneXMR / 10, "currency": "EUR", "line_items": []map[string]any{{"name": " ", "quantity": 1, "unit_price": 2.0}}},
+		"zero quantity":      {"amount": oneXMR / 10, "currency": "EUR", "line_items": []map[string]any{{"name": "Tea", "quantity": 0, "unit_price": 2.0}}},
+	} {
+		if code, body := env.Do(t, http.MethodPost, "/pos/create-transaction", posToken, body); code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400: %s", name, code, body)
+		}
+	}
+
+	// A cart in XMR prices the atomic amount
+	var inXMR struct {
+		Amount int64 `json:"amount"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"currency": "XMR", "required_confirmations": 0,
+		"line_items": []map[string]any{{"name": "Sticker", "quantity": 4, "unit_price": 0.0125}},
+	}, &inXMR)
+	if inXMR.Amount != oneXMR/20 {
+		t.Fatalf("XMR cart amount: got %d, want %d", inXMR.Amount, oneXMR/20)
+	}
+
+	// Only paid carts show up in the item report and the export
+	var jwt string
+	for _, receive := range env.MoneroPay.Receives() {
+		if receive.Address == sale.Address {
+			jwt = testutil.CallbackJWT(t, receive)
+		}
+	}
+	payment := testutil.Payment("cart-1", oneXMR/10, 10)
+	status := env.MoneroPay.SetPayments(sale.Address, payment)
+	if code := env.SendCallback(t, jwt, status, payment); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+
+	var report struct {
+		Items []struct {
+			SKU          *string `json:"sku"`
+			Name         string  `json:"name"`
+			Currency     string  `json:"currency"`
+			Quantity     float64 `json:"quantity"`
+			Total        float64 `json:"total"`
+			Transactions int     `json:"transactions"`
+		} `json:"items"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/reports/items", vendorToken, nil, &report)
+	if len(report.Items) != 2 || report.Items[0].Name != "Coffee" || report.Items[0].Quantity != 2 || report.Items[0].Total != 8.33 ||
+		report.Items[0].Currency != "EUR" || report.Items[0].Transactions != 1 || report.Items[1].Name != "Cake" {
+		t.Fatalf("unexpected item report: %+v", report.Items)
+	}
+
+	var listed struct {
+		Confirmed []struct {
+			ID        uint       `json:"id"`
+			LineItems []lineItem `json:"line_items"`
+		} `json:"confirmed_transactions"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/transactions", vendorToken, nil, &listed)
+	if len(listed.Confirmed) != 1 || listed.Confirmed[0].ID != sale.ID || len(listed.Confirmed[0].LineItems) != 2 {
+		t.Fatalf("unexpected vendor transactions: %+v", listed.Confirmed)
+	}
+
+	var export struct {
+		CSVData string `json:"csv_data"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/export", vendorToken, nil, &export)
+	if !strings.HasPrefix(export.CSVData, "Koinly Date,Amount,Currency,Label,TxHash,Description\n") ||
+		!strings.Contains(export.CSVData, ",XMR,income,cart-1,\"2 x Coffee, 1 x Cake\"") {
+		t.Fatalf("line items missing from export:\n%s", export.CSVData)
+	}
+
+	// Invoices carry their cart to the checkout page
+	var invoice struct {
+		AmountInCurrency float64 `json:"amount_in_currency"`
+		CheckoutToken    string  `json:"checkout_token"`
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/invoices", vendorToken, map[string]any{
+		"order_id": "order-cart", "amount": oneXMR / 10, "currency": "EUR", "redirect_url": "https://shop.example", "line_items": cart,
+	}, &invoice)
+	var checkout struct {
+		LineItems []lineItem `json:"line_items"`
+	}
+	env.MustDo(t, http.MethodGet, "/checkout/"+invoice.CheckoutToken, "", nil, &checkout)
+	if invoice.AmountInCurrency != 12.61 || len(checkout.LineItems) != 2 || checkout.LineItems[1].Name != "Cake" {
+		t.Fatalf("unexpected invoice cart: %v %+v", invoice.AmountInCurrency, checkout.LineItems)
+	}
+}
@@ -0,0 +1,52 @@
+package pos_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestPaymentURIAndQRCode(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	var created struct {
+		ID         uint   `json:"id"`
+		Address    string `json:"address"`
+		PaymentURI string `json:"payment_uri"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 4, "amount_in_currency": 37.5, "currency": "EUR", "required_confirmations": 0, "description": "Table 4",
+	}, &created)
+	want := "monero:" + created.Address + "?tx_amount=0.25&recipient_name=test-vendor&tx_description=Table%204"
+	if created.PaymentURI != want {
+		t.Fatalf("payment URI: got %s, want %s", created.PaymentURI, want)
+	}
+
+	qrPath := fmt.Sprintf("/pos/transaction/%d/qr", created.ID)
+	code, png := env.Do(t, http.MethodGet, qrPath+"?size=128", posToken, nil)
+	if code != http.StatusOK || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("PNG QR code: status %d", code)
+	}
+	code, svg := env.Do(t, http.MethodGet, fmt.Sprintf("/vendor/transaction/%d/qr?format=svg&size=512", created.ID), vendorToken, nil)
+	if code != http.StatusOK || !bytes.HasPrefix(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512"`)) {
+		t.Fatalf("SVG QR code: status %d: %s", code, svg)
+	}
+
+	for query, wantCode := range map[string]int{"?size=10": http.StatusBadRequest, "?format=gif": http.StatusBadRequest} {
+		if code, _ := env.Do(t, http.MethodGet, qrPath+query, posToken, nil); code != wantCode {
+			t.Fatalf("%s: got status %d, want %d", query, code, wantCode)
+		}
+	}
+
+	env.CreateVendor(t, "other-vendor", testutil.Subaddress(2))
+	var other testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-vendor", "", map[string]any{"name": "other-vendor", "password": testutil.VendorPassword}, &other)
+	if code, _ := env.Do(t, http.MethodGet, fmt.Sprintf("/vendor/transaction/%d/qr", created.ID), other.AccessToken, nil); code != http.StatusNotFound {
+		t.Fatalf("QR code of another vendor's transaction: got status %d, want 404", code)
+	}
+}
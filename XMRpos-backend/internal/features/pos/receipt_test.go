@@ -0,0 +1,167 @@
+package pos_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestReceipts(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount":                 oneXMR,
+		"currency":               "EUR",
+		"required_confirmations": 0,
+		"line_items": []map[string]any{
+			{"name": "Coffee", "quantity": 2, "unit_price": 3.5},
+			{"name": "Cake", "quantity": 1, "unit_price": 5.0, "tax_rate": 7},
+		},
+	}, &created)
+	path := fmt.Sprintf("/pos/transaction/%d/receipt", created.ID)
+	if code, _ := env.Do(t, http.MethodGet, path, posToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("receipt of an unpaid transaction: got status %d, want 400", code)
+	}
+
+	hash := strings.Repeat("ab12", 16)
+	payment := testutil.Payment(hash, oneXMR, 0)
+	status := env.MoneroPay.SetPayments(created.Address, payment)
+	if code := env.SendCallback(t, testutil.CallbackJWT(t, env.MoneroPay.Receives()[0]), status, payment); code != http.StatusOK {
+		t.Fatalf("callback: got status %d", code)
+	}
+
+	code, header, body := env.DoWithHeaders(t, http.MethodGet, path+"?format=txt", posToken, nil, nil)
+	if code != http.StatusOK || !strings.HasPrefix(header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("text receipt: status %d, content type %q", code, header.Get("Content-Type"))
+	}
+	for _, want := range []string{env.Vendor.Name, "2 x Coffee", "TOTAL EUR", "12.35", hash[:32]} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("text receipt misses %q:\n%s", want, body)
+		}
+	}
+
+	// ESC/POS is the default, it prints the hash as QR code
+	code, header, body = env.DoWithHeaders(t, http.MethodGet, path, posToken, nil, nil)
+	if code != http.StatusOK || header.Get("Content-Type") != "application/vnd.escpos" {
+		t.Fatalf("ESC/POS receipt: status %d, content type %q", code, header.Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(body, []byte{0x1b, 0x40}) || !bytes.Contains(body, append([]byte{0x31, 0x50, 0x30}, hash...)) {
+		t.Fatal("ESC/POS receipt misses the reset or the QR code")
+	}
+
+	// The vendor reprints it from the dashboard
+	code, header, body = env.DoWithHeaders(t, http.MethodGet, fmt.Sprintf("/vendor/transaction/%d/receipt?format=pdf", created.ID), vendorToken, nil, nil)
+	if code != http.StatusOK || header.Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(body, []byte("%PDF-")) {
+		t.Fatalf("PDF receipt: status %d, content type %q", code, header.Get("Content-Type"))
+	}
+
+	if code, _ := env.Do(t, http.MethodGet, path+"?format=html", posToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown format: got status %d, want 400", code)
+	}
+	otherPos := env.Store.AddPos(env.Vendor.ID, "second-till", testutil.PosPassword)
+	var other testutil.Tokens
+	env.MustDo(t, http.MethodPost, "/auth/login-pos", "", map[string]any{"vendor_id": env.Vendor.ID, "name": otherPos.Name, "password": testutil.PosPassword}, &other)
+	if code, _ := env.Do(t, http.MethodGet, path, other.AccessToken, nil); code != http.StatusForbidden {
+		t.Fatalf("receipt of another POS: got status %d, want 403", code)
+	}
+}
+
+func TestEmailReceipts(t *testing.T) {
+	sink := testutil.NewFakeSMTP(t)
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.MailSender = config.MailSenderSMTP
+		cfg.SMTPHost = sink.Host
+		cfg.SMTPPort = sink.Port
+		cfg.MailFrom = "XMRpos <receipts@xmrpos.test>"
+		cfg.ReorgWatchWindow = time.Nanosecond // Pay out payments right after they confirm
+	})
+	posToken := env.LoginPos(t)
+
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount":                 oneXMR,
+		"currency":               "EUR",
+		"required_confirmations": 0,
+		"line_items":             []map[string]any{{"name": "Coffee", "quantity": 2, "unit_price": 3.5}},
+	}, &created)
+	request := map[string]any{"transaction_id": created.ID, "email": "customer@example.com"}
+
+	// Accepted with 0 confirmations is not enough, the payment could still be double spent
+	hash := strings.Repeat("cd34", 16)
+	jwt := testutil.CallbackJWT(t, env.MoneroPay.Receives()[0])
+	for _, confirmations := range []int64{0, 10} {
+		payment := testutil.Payment(hash, oneXMR, confirmations)
+		status := env.MoneroPay.SetPayments(created.Address, payment)
+		if code := env.SendCallback(t, jwt, status, payment); code != http.StatusOK {
+			t.Fatalf("callback: got status %d", code)
+		}
+		if confirmations == 0 {
+			if code, _ := env.Do(t, http.MethodPost, "/pos/email-receipt", posToken, request); code != http.StatusBadRequest {
+				t.Fatalf("receipt of an unconfirmed payment: got status %d, want 400", code)
+			}
+		}
+	}
+
+	if code, _ := env.Do(t, http.MethodPost, "/pos/email-receipt", posToken, map[string]any{"transaction_id": created.ID, "email": "customer@example.com\r\nBcc: x@example.com"}); code != http.StatusBadRequest {
+		t.Fatalf("invalid email: got status %d, want 400", code)
+	}
+	env.MustDo(t, http.MethodPost, "/pos/email-receipt", posToken, request, nil)
+
+	messages := sink.Messages()
+	if len(messages) != 1 || len(messages[0].To) != 1 || messages[0].To[0] != "customer@example.com" || messages[0].From != "receipts@xmrpos.test" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	if subject := messages[0].Header("Subject"); subject != "Your receipt from "+env.Vendor.Name {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	text := string(messages[0].Part("text/plain"))
+	for _, want := range []string{env.Vendor.Name, "2 x Coffee", "TOTAL EUR", "7.00", hash[:32]} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("emailed receipt misses %q:\n%s", want, text)
+		}
+	}
+	if pdf := messages[0].Part("application/pdf"); !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatal("receipt PDF is not attached")
+	}
+
+	sink.SetReject(true)
+	if code, _ := env.Do(t, http.MethodPost, "/pos/email-receipt", posToken, request); code != http.StatusBadGateway {
+		t.Fatalf("rejected by the mail server: got status %d, want 502", code)
+	}
+	sink.SetReject(false)
+
+	// Completed payouts are announced to the vendor
+	env.Store.SetVendorEmail(env.Vendor.ID, "owner@example.com")
+	env.MustDo(t, http.MethodPost, "/vendor/transfer-balance", env.LoginVendor(t), nil, nil)
+	testutil.WaitFor(t, "payout notification", func() bool {
+		return len(sink.Messages()) == 2
+	})
+	notification := sink.Messages()[1]
+	if notification.To[0] != "owner@example.com" || !strings.HasPrefix(notification.Header("Subject"), "Payout of ") {
+		t.Fatalf("unexpected notification to %v: %q", notification.To, notification.Header("Subject"))
+	}
+	if body := string(notification.Part("text/plain")); !strings.Contains(body, "wallet-transfer-1") || !strings.Contains(body, env.Vendor.MoneroSubaddress) {
+		t.Fatalf("notification misses the payout details:\n%s", body)
+	}
+
+	// Without a mail sender the POS is told email is unavailable
+	plain := testutil.NewEnv(t)
+	if code, _ := plain.Do(t, http.MethodPost, "/pos/email-receipt", plain.LoginPos(t), request); code != http.StatusServiceUnavailable {
+		t.Fatalf("without mail sender: got status %d, want 503", code)
+	}
+}
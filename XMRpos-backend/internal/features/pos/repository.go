@@ -9,11 +9,13 @@ import (
 )
 
 type PosRepository interface {
+	FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error)
 	FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error)
 	CreateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error)
 	UpdateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error)
 	FindTransactionsByPosID(ctx context.Context, vendorID uint, posID uint) ([]*models.Transaction, error)
-	DeletePendingTransactionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	FindTransactionsToExpire(ctx context.Context, now time.Time) ([]*models.Transaction, error)
+	ExpireTransaction(ctx context.Context, transactionID uint, fromStatus string, toStatus string) (bool, error)
 }
 
 type posRepository struct {
@@ -24,12 +26,25 @@ func NewPosRepository(db *gorm.DB) PosRepository {
 	return &posRepository{db: db}
 }
 
+func (r *posRepository) FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var vendor models.Vendor
+	if err := r.db.WithContext(ctx).
+		Preload("ConfirmationTiers", func(db *gorm.DB) *gorm.DB { return db.Order("below_amount ASC") }).
+		First(&vendor, id).Error; err != nil {
+		return nil, err
+	}
+	return &vendor, nil
+}
+
 func (r *posRepository) FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 	var transaction models.Transaction
-	if err := r.db.WithContext(ctx).Preload("SubTransactions").First(&transaction, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("SubTransactions").Preload("LineItems").First(&transaction, id).Error; err != nil {
 		return nil, err
 	}
 	return &transaction, nil
@@ -63,6 +78,8 @@ func (r *posRepository) FindTransactionsByPosID(ctx context.Context, vendorID ui
 	var transactions []*models.Transaction
 	if err := r.db.WithContext(ctx).
 		Preload("SubTransactions").
+		Preload("Refunds", "status <> ?", models.RefundStatusFailed).
+		Preload("LineItems").
 		Where("vendor_id = ? AND pos_id = ?", vendorID, posID).
 		Order("created_at DESC").
 		Find(&transactions).Error; err != nil {
@@ -72,46 +89,35 @@ func (r *posRepository) FindTransactionsByPosID(ctx context.Context, vendorID ui
 	return transactions, nil
 }
 
-func (r *posRepository) DeletePendingTransactionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+// Transactions still waiting for (the rest of) their payment after their quote window closed
+func (r *posRepository) FindTransactionsToExpire(ctx context.Context, now time.Time) ([]*models.Transaction, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	tx := r.db.WithContext(ctx).Begin()
-	if tx.Error != nil {
-		return 0, tx.Error
-	}
-
-	var ids []uint
-	if err := tx.
-		Model(&models.Transaction{}).
-		Where("confirmed = ? AND created_at < ?", false, cutoff).
-		Pluck("id", &ids).Error; err != nil {
-		tx.Rollback()
-		return 0, err
-	}
-
-	if len(ids) == 0 {
-		if err := tx.Commit().Error; err != nil {
-			return 0, err
-		}
-		return 0, nil
+	var transactions []*models.Transaction
+	if err := r.db.WithContext(ctx).
+		Where("status IN ? AND confirmed = ? AND expires_at < ?",
+			[]string{models.TransactionStatusPending, models.TransactionStatusPartiallyPaid}, false, now).
+		Order("expires_at ASC").
+		Find(&transactions).Error; err != nil {
+		return nil, err
 	}
+	return transactions, nil
+}
 
-	if err := tx.Where("transaction_id IN ?", ids).Delete(&models.SubTransaction{}).Error; err != nil {
-		tx.Rollback()
-		return 0, err
+// The status is only changed if it is still fromStatus, so a payment processed in the meantime is not overwritten
+func (r *posRepository) ExpireTransaction(ctx context.Context, transactionID uint, fromStatus string, toStatus string) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	res := tx.Where("id IN ?", ids).Delete(&models.Transaction{})
+	res := r.db.WithContext(ctx).
+		Model(&models.Transaction{}).
+		Where("id = ? AND status = ? AND confirmed = ?", transactionID, fromStatus, false).
+		Update("status", toStatus)
 	if res.Error != nil {
-		tx.Rollback()
-		return 0, res.Error
+		return false, res.Error
 	}
-
-	if err := tx.Commit().Error; err != nil {
-		return 0, err
-	}
-
-	return res.RowsAffected, nil
+	return res.RowsAffected > 0, nil
 }
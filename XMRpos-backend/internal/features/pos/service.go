@@ -5,27 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/address"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/mail"
 	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
-	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/qr"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rates"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/receipt"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/webhook"
 )
 
 type PosService struct {
-	repo      PosRepository
-	config    *config.Config
-	moneroPay *moneropay.MoneroPayAPIClient
+	repo     PosRepository
+	config   *config.Config
+	payments payment.PaymentBackend
+	rates    *rates.Service
+	webhooks *webhook.WebhookService
+	mailer   mail.Sender
 }
 
 const moneroAtomicUnitsPerXMR int64 = 1_000_000_000_000
 
+// How long the quoted amount stays valid when INVOICE_EXPIRY is not set
+const defaultInvoiceExpiry = 15 * time.Minute
+
 var ErrNoConfirmedTransactions = errors.New("no confirmed transactions in DB")
 
-func NewPosService(repo PosRepository, cfg *config.Config, moneroPay *moneropay.MoneroPayAPIClient) *PosService {
-	return &PosService{repo: repo, config: cfg, moneroPay: moneroPay}
+// NewPosService takes a nil rate service when exchange rates are not checked server-side,
+// and a nil mailer when no email is sent
+func NewPosService(repo PosRepository, cfg *config.Config, payments payment.PaymentBackend, exchangeRates *rates.Service, webhooks *webhook.WebhookService, mailer mail.Sender) *PosService {
+	return &PosService{repo: repo, config: cfg, payments: payments, rates: exchangeRates, webhooks: webhooks, mailer: mailer}
 }
 
 type ConfirmedTransactionSummary struct {
@@ -38,10 +54,14 @@ type ConfirmedTransactionSummary struct {
 }
 
 type PendingTransactionSummary struct {
-	ID        uint  `json:"id"`
-	Amount    int64 `json:"amount"`
-	Accepted  bool  `json:"accepted"`
-	Confirmed bool  `json:"confirmed"`
+	ID                uint       `json:"id"`
+	Amount            int64      `json:"amount"`
+	Status            string     `json:"status"`
+	AmountReceived    int64      `json:"amount_received"`
+	AmountOutstanding int64      `json:"amount_outstanding"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	Accepted          bool       `json:"accepted"`
+	Confirmed         bool       `json:"confirmed"`
 }
 
 type ListTransactionsResult struct {
@@ -49,24 +69,82 @@ type ListTransactionsResult struct {
 	Pending   []PendingTransactionSummary   `json:"pending_transactions"`
 }
 
-func (s *PosService) CreateTransaction(ctx context.Context, vendorID uint, posID uint, amount int64, description *string, amountInCurrency float64, currency string, requiredConfirmations int64) (id uint, address string, err error) {
-	if ctx == nil {
-		ctx = context.Background()
-	}
-
+// CreateTransaction stores the invoice and requests a receive address for it. The amount may be
+// left at 0 when a server-side rate service is configured, it is then computed from the fiat amount.
+// An itemized cart prices itself, the fiat amount may then be left at 0 as well. A tip is added on
+// top of the amounts, in fiat for fiat sales and in atomic units for sales in XMR.
+func (s *PosService) CreateTransaction(ctx context.Context, vendorID uint, posID uint, amount int64, description *string, amountInCurrency float64, currency string, requiredConfirmations int64, lineItems []*models.LineItem, taxIncluded bool, tip int64, tipInCurrency float64) (*models.Transaction, error) {
 	transaction := &models.Transaction{
 		VendorID:              vendorID,
-		PosID:                 posID,
+		PosID:                 &posID,
 		Amount:                amount,
 		RequiredConfirmations: requiredConfirmations,
 		Currency:              currency,
 		AmountInCurrency:      amountInCurrency,
 		Description:           description,
+		LineItems:             lineItems,
+		TaxIncluded:           taxIncluded,
+		TipAmount:             tip,
+		TipInCurrency:         tipInCurrency,
+	}
+	return s.IssueTransaction(ctx, transaction)
+}
+
+// IssueTransaction prices a new transaction, applies the vendor's confirmation policy, stores it
+// and requests its receive address. POS sales and invoices from the invoice API both go through it.
+func (s *PosService) IssueTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	vendor, err := s.repo.FindVendorByID(ctx, transaction.VendorID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := s.config.InvoiceExpiry
+	if expiry <= 0 {
+		expiry = defaultInvoiceExpiry
+	}
+	expiresAt := time.Now().Add(expiry).UTC()
+	transaction.Status = models.TransactionStatusPending
+	transaction.ExpiresAt = &expiresAt
+
+	if err := priceCart(transaction); err != nil {
+		return nil, err
+	}
+	if err := s.priceTransaction(ctx, transaction); err != nil {
+		return nil, err
+	}
+	if err := addTip(transaction); err != nil {
+		return nil, err
+	}
+	s.applyConfirmationPolicy(ctx, vendor, transaction)
+
+	// A light-wallet server only reports the payment ID, so the customer pays to an integrated
+	// address carrying one that is unique to this transaction
+	if s.config.LwsAddress != "" {
+		paymentID, err := address.NewPaymentID()
+		if err != nil {
+			return nil, err
+		}
+		integrated, err := address.Integrated(s.config.LwsAddress, paymentID)
+		if err != nil {
+			return nil, err
+		}
+		transaction.PaymentID = &paymentID
+		transaction.SubAddress = &integrated
+		setPaymentURI(vendor, transaction)
 	}
 
 	transactionDB, err := s.repo.CreateTransaction(ctx, transaction)
 	if err != nil {
-		return 0, "", err
+		return nil, err
+	}
+
+	if transactionDB.PaymentID != nil {
+		s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionCreated, transactionDB)
+		return transactionDB, nil
 	}
 
 	// Create a jwt token for the transaction which contains the transaction ID
@@ -77,7 +155,7 @@ func (s *PosService) CreateTransaction(ctx context.Context, vendorID uint, posID
 
 	accessToken, err := moneroPayTokenJWT.SignedString([]byte(s.config.JWTMoneroPaySecret))
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
 
 	callbackURLTemplate := s.config.MoneroPayCallbackURL
@@ -89,31 +167,184 @@ func (s *PosService) CreateTransaction(ctx context.Context, vendorID uint, posID
 	}
 
 	var desc string
-	if description != nil {
-		desc = *description
+	if transaction.Description != nil {
+		desc = *transaction.Description
 	}
 
-	req := &moneropay.ReceiveRequest{
-		Amount:      amount,
-		Description: desc,
-		CallbackUrl: callbackUrl,
+	// The subaddress is created under the vendor's wallet account so the funds never mix with other vendors
+	req := payment.ReceiveRequest{
+		AccountIndex: vendor.WalletAccountIndex,
+		Amount:       transactionDB.Amount,
+		Description:  desc,
+		CallbackURL:  callbackUrl,
 	}
 
 	// per-call timeout for external dependency
 	callCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	resp, err := s.moneroPay.PostReceive(callCtx, req)
+	resp, err := s.payments.CreateReceive(callCtx, req)
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
 
-	// Update the transaction with the subaddress received from MoneroPay
+	// Update the transaction with the subaddress received from the payment backend
 	transactionDB.SubAddress = &resp.Address
+	setPaymentURI(vendor, transactionDB)
 	if _, err := s.repo.UpdateTransaction(ctx, transactionDB); err != nil {
-		return 0, "", err
+		return nil, err
+	}
+
+	s.webhooks.EmitTransaction(ctx, models.WebhookEventTransactionCreated, transactionDB)
+
+	return transactionDB, nil
+}
+
+// setPaymentURI records the URI wallets read from the QR code, naming the vendor as recipient
+func setPaymentURI(vendor *models.Vendor, transaction *models.Transaction) {
+	var desc string
+	if transaction.Description != nil {
+		desc = *transaction.Description
+	}
+	uri := address.PaymentURI(*transaction.SubAddress, transaction.Amount, vendor.Name, desc)
+	transaction.PaymentURI = &uri
+}
+
+// priceTransaction settles the XMR amount and records the exchange rate it was quoted at.
+// With a rate service the server rate is authoritative: a missing amount is computed from the
+// fiat amount and an amount sent by the POS has to match the rate within the tolerance.
+// Without one the rate implied by the POS request is recorded as is.
+func (s *PosService) priceTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if transaction.Amount < 0 || transaction.AmountInCurrency < 0 {
+		return models.NewHTTPError(http.StatusBadRequest, "Amounts must not be negative")
+	}
+
+	// Invoices denominated in XMR have no rate to check
+	if transaction.AmountInCurrency == 0 || transaction.Currency == "" || strings.EqualFold(transaction.Currency, "XMR") {
+		if transaction.Amount == 0 {
+			return models.NewHTTPError(http.StatusBadRequest, "Amount is required")
+		}
+		return nil
+	}
+
+	if s.rates == nil {
+		if transaction.Amount == 0 {
+			return models.NewHTTPError(http.StatusBadRequest, "Amount is required")
+		}
+		transaction.ExchangeRate = rates.ImpliedRate(transaction.Amount, transaction.AmountInCurrency)
+		transaction.ExchangeRateSource = models.ExchangeRateSourcePos
+		return nil
+	}
+
+	quote, err := s.rates.Rate(ctx, transaction.Currency)
+	if errors.Is(err, rates.ErrUnsupportedCurrency) {
+		return models.NewHTTPError(http.StatusBadRequest, "No exchange rate for currency "+transaction.Currency)
+	}
+	if err != nil {
+		log.Printf("Exchange rate lookup failed: %v", err)
+		return models.NewHTTPError(http.StatusServiceUnavailable, "Exchange rate unavailable")
+	}
+
+	expected := rates.ToAtomic(transaction.AmountInCurrency, quote.Rate)
+	if transaction.Amount == 0 {
+		transaction.Amount = expected
+	} else {
+		tolerance := s.config.ExchangeRateTolerancePercent
+		// One atomic unit of slack absorbs the rounding of the POS conversion
+		allowed := int64(math.Round(float64(expected)*tolerance/100)) + 1
+		if diff := transaction.Amount - expected; diff > allowed || diff < -allowed {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Amount does not match the exchange rate of %g %s/XMR", quote.Rate, quote.Currency))
+		}
+	}
+
+	fetchedAt := quote.FetchedAt.UTC()
+	transaction.ExchangeRate = quote.Rate
+	transaction.ExchangeRateSource = quote.Source
+	transaction.ExchangeRateAt = &fetchedAt
+	return nil
+}
+
+// addTip adds the tip to the priced amounts, so the customer pays both with one transfer. Tips
+// of fiat sales are converted at the rate the sale was priced at, either way round.
+func addTip(transaction *models.Transaction) error {
+	if transaction.TipAmount < 0 || transaction.TipInCurrency < 0 {
+		return models.NewHTTPError(http.StatusBadRequest, "Tips must not be negative")
+	}
+
+	switch {
+	case transaction.TipAmount > 0 && transaction.TipInCurrency > 0:
+		return models.NewHTTPError(http.StatusBadRequest, "Send either tip or tip_in_currency")
+	case transaction.TipInCurrency > 0:
+		if transaction.ExchangeRate <= 0 {
+			return models.NewHTTPError(http.StatusBadRequest, "tip_in_currency requires a fiat amount_in_currency, use tip for sales in XMR")
+		}
+		transaction.TipInCurrency = roundTo(transaction.TipInCurrency, fiatDecimals)
+		transaction.TipAmount = rates.ToAtomic(transaction.TipInCurrency, transaction.ExchangeRate)
+	case transaction.TipAmount > 0 && transaction.ExchangeRate > 0:
+		transaction.TipInCurrency = roundTo(float64(transaction.TipAmount)/float64(moneroAtomicUnitsPerXMR)*transaction.ExchangeRate, fiatDecimals)
+	}
+
+	if transaction.TipInCurrency > 0 {
+		transaction.AmountInCurrency = roundTo(transaction.AmountInCurrency+transaction.TipInCurrency, fiatDecimals)
+	}
+	transaction.Amount += transaction.TipAmount
+	return nil
+}
+
+// applyConfirmationPolicy raises the requested confirmations to what the vendor's policy demands
+// for the amount. A POS may ask for more confirmations than the policy but never for fewer.
+func (s *PosService) applyConfirmationPolicy(ctx context.Context, vendor *models.Vendor, transaction *models.Transaction) {
+	transaction.PayableConfirmations = max(vendor.PayableConfirmations, models.MinPayableConfirmations)
+
+	required := vendor.DefaultConfirmations
+	if amount, ok := s.amountInPolicyCurrency(ctx, vendor.ConfirmationCurrency, transaction); ok {
+		// Tiers are sorted by amount, the first one above the invoice applies
+		for _, tier := range vendor.ConfirmationTiers {
+			if amount < tier.BelowAmount {
+				required = tier.Confirmations
+				break
+			}
+		}
+	}
+	transaction.RequiredConfirmations = max(transaction.RequiredConfirmations, required)
+}
+
+// amountInPolicyCurrency values the invoice in the currency of the vendor's tiers. When that isn't
+// possible the tiers are skipped and the policy default applies.
+func (s *PosService) amountInPolicyCurrency(ctx context.Context, currency string, transaction *models.Transaction) (float64, bool) {
+	switch {
+	case currency == "":
+		return 0, false
+	case strings.EqualFold(currency, transaction.Currency) && transaction.AmountInCurrency > 0:
+		return transaction.AmountInCurrency, true
+	case strings.EqualFold(currency, "XMR"):
+		return float64(transaction.Amount) / float64(moneroAtomicUnitsPerXMR), true
+	case s.rates == nil:
+		return 0, false
 	}
 
-	return transactionDB.ID, resp.Address, nil
+	quote, err := s.rates.Rate(ctx, currency)
+	if err != nil {
+		log.Printf("Exchange rate lookup for the confirmation policy failed: %v", err)
+		return 0, false
+	}
+	return float64(transaction.Amount) / float64(moneroAtomicUnitsPerXMR) * quote.Rate, true
+}
+
+// GetExchangeRates returns the server rates the POS should quote with
+func (s *PosService) GetExchangeRates(ctx context.Context, currencies []string) ([]rates.Quote, *models.HTTPError) {
+	if s.rates == nil {
+		return nil, models.NewHTTPError(http.StatusNotFound, "Exchange rates are not provided by this server")
+	}
+
+	quotes, err := s.rates.Rates(ctx, currencies)
+	if errors.Is(err, rates.ErrUnsupportedCurrency) {
+		return nil, models.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		log.Printf("Exchange rate lookup failed: %v", err)
+		return nil, models.NewHTTPError(http.StatusServiceUnavailable, "Exchange rate unavailable")
+	}
+	return quotes, nil
 }
 
 // GetTransaction retrieves a transaction by its ID if authorized
@@ -135,9 +366,79 @@ func (s *PosService) GetTransaction(ctx context.Context, transactionID uint, ven
 	return transaction, nil
 }
 
+// GetTransactionQRCode renders the payment URI of a transaction of this POS
+func (s *PosService) GetTransactionQRCode(ctx context.Context, transactionID uint, vendorID uint, posID uint, opts qr.Options) ([]byte, string, *models.HTTPError) {
+	transaction, httpErr := s.GetTransaction(ctx, transactionID, vendorID, posID)
+	if httpErr != nil {
+		return nil, "", httpErr
+	}
+	if transaction.PaymentURI == nil {
+		return nil, "", models.NewHTTPError(http.StatusNotFound, "Transaction has no payment URI")
+	}
+
+	image, contentType, err := qr.Render(*transaction.PaymentURI, opts)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "Failed to render QR code: "+err.Error())
+	}
+	return image, contentType, nil
+}
+
+// GetTransactionReceipt renders the receipt of a paid transaction of this POS
+func (s *PosService) GetTransactionReceipt(ctx context.Context, transactionID uint, vendorID uint, posID uint, format string) ([]byte, string, *models.HTTPError) {
+	transaction, httpErr := s.GetTransaction(ctx, transactionID, vendorID, posID)
+	if httpErr != nil {
+		return nil, "", httpErr
+	}
+	if !transaction.Accepted {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, "Only accepted payments have a receipt")
+	}
+	vendor, err := s.repo.FindVendorByID(ctx, vendorID)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "Failed to load vendor: "+err.Error())
+	}
+
+	data, contentType, err := receipt.Render(receipt.New(transaction, vendor.Name), format)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "Failed to render receipt: "+err.Error())
+	}
+	return data, contentType, nil
+}
+
+// EmailReceipt mails the receipt of a confirmed transaction to a customer, the address is not stored
+func (s *PosService) EmailReceipt(ctx context.Context, transactionID uint, vendorID uint, posID uint, email string) *models.HTTPError {
+	if s.mailer == nil {
+		return models.NewHTTPError(http.StatusServiceUnavailable, "Email is not configured on this server")
+	}
+	if err := mail.ValidateAddress(email); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, "Invalid email address")
+	}
+
+	transaction, httpErr := s.GetTransaction(ctx, transactionID, vendorID, posID)
+	if httpErr != nil {
+		return httpErr
+	}
+	if !transaction.Confirmed {
+		return models.NewHTTPError(http.StatusBadRequest, "Only confirmed payments can be emailed")
+	}
+	vendor, err := s.repo.FindVendorByID(ctx, vendorID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, "Failed to load vendor: "+err.Error())
+	}
+
+	msg, err := mail.ReceiptMessage(email, receipt.New(transaction, vendor.Name))
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, "Failed to render receipt: "+err.Error())
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Failed to email the receipt of transaction %d: %v", transactionID, err)
+		return models.NewHTTPError(http.StatusBadGateway, "Failed to send the email")
+	}
+	return nil
+}
+
 // Check if the vendor and POS are authorized for the transaction
 func (s *PosService) IsAuthorizedForTransaction(vendorID uint, posID uint, transaction *models.Transaction) bool {
-	if transaction.VendorID != vendorID || transaction.PosID != posID {
+	if transaction.VendorID != vendorID || transaction.PosID == nil || *transaction.PosID != posID {
 		return false
 	}
 	return true
@@ -174,10 +475,14 @@ func (s *PosService) ListTransactionsByPos(ctx context.Context, vendorID uint, p
 		}
 
 		result.Pending = append(result.Pending, PendingTransactionSummary{
-			ID:        transaction.ID,
-			Amount:    transaction.Amount,
-			Accepted:  transaction.Accepted,
-			Confirmed: transaction.Confirmed,
+			ID:                transaction.ID,
+			Amount:            transaction.Amount,
+			Status:            transaction.Status,
+			AmountReceived:    transaction.AmountReceived,
+			AmountOutstanding: transaction.AmountOutstanding,
+			ExpiresAt:         transaction.ExpiresAt,
+			Accepted:          transaction.Accepted,
+			Confirmed:         transaction.Confirmed,
 		})
 	}
 
@@ -195,7 +500,7 @@ func (s *PosService) ExportConfirmedTransactionsCSV(ctx context.Context, vendorI
 	}
 
 	var builder strings.Builder
-	builder.WriteString("Koinly Date,Amount,Currency,Label,TxHash")
+	builder.WriteString("Koinly Date,Amount,Currency,Label,TxHash,Description")
 
 	rows := 0
 	for _, transaction := range transactions {
@@ -203,9 +508,15 @@ func (s *PosService) ExportConfirmedTransactionsCSV(ctx context.Context, vendorI
 			continue
 		}
 
+		description := ExportDescription(transaction.LineItems)
 		for _, sub := range transaction.SubTransactions {
 			builder.WriteByte('\n')
-			builder.WriteString(formatExportRow(sub))
+			builder.WriteString(formatExportRow(sub, description))
+			rows++
+		}
+		for _, refund := range transaction.Refunds {
+			builder.WriteByte('\n')
+			builder.WriteString(formatRefundExportRow(refund))
 			rows++
 		}
 	}
@@ -217,12 +528,29 @@ func (s *PosService) ExportConfirmedTransactionsCSV(ctx context.Context, vendorI
 	return builder.String(), nil
 }
 
-func formatExportRow(sub *models.SubTransaction) string {
+func formatExportRow(sub *models.SubTransaction, description string) string {
 	date := sub.Timestamp.UTC()
 	dateStr := fmt.Sprintf("%04d-%02d-%02d 00:00 UTC", date.Year(), date.Month(), date.Day())
 	amount := formatAtomicAmountTwoDecimals(sub.Amount)
 
-	return fmt.Sprintf("%s,%s,XMR,income,%s", dateStr, amount, sub.TxHash)
+	return fmt.Sprintf("%s,%s,XMR,income,%s,%s", dateStr, amount, sub.TxHash, description)
+}
+
+// formatRefundExportRow books a refund as an outgoing amount
+func formatRefundExportRow(refund *models.Refund) string {
+	date := refund.CreatedAt.UTC()
+	dateStr := fmt.Sprintf("%04d-%02d-%02d 00:00 UTC", date.Year(), date.Month(), date.Day())
+	amount := formatAtomicAmountTwoDecimals(refund.Amount)
+
+	var txHash, description string
+	if refund.TxHash != nil {
+		txHash = *refund.TxHash
+	}
+	// a pending refund may not have left the wallet yet
+	if refund.Status == models.RefundStatusPending {
+		description = "pending"
+	}
+	return fmt.Sprintf("%s,-%s,XMR,refund,%s,%s", dateStr, amount, txHash, description)
 }
 
 func formatAtomicAmountTwoDecimals(amount int64) string {
@@ -246,20 +574,50 @@ func formatAtomicAmountTwoDecimals(amount int64) string {
 	return fmt.Sprintf("%d.%02d", integer, decimals)
 }
 
-func (s *PosService) CleanupOldPendingTransactions(ctx context.Context, olderThan time.Duration) (int64, error) {
+// ExpireTransactions closes the quote window of transactions whose invoice expired and pushes the new
+// state to the POS. Partially paid ones become underpaid, the rest expired. Rows are kept so a payment
+// that still arrives can be matched and flagged for manual resolution.
+func (s *PosService) ExpireTransactions(ctx context.Context) (int64, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	cutoff := time.Now().Add(-olderThan)
-
-	cleanupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	expireCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	return s.repo.DeletePendingTransactionsBefore(cleanupCtx, cutoff)
+	transactions, err := s.repo.FindTransactionsToExpire(expireCtx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for _, transaction := range transactions {
+		status := models.TransactionStatusExpired
+		if transaction.Status == models.TransactionStatusPartiallyPaid {
+			status = models.TransactionStatusUnderpaid
+		}
+
+		updated, err := s.repo.ExpireTransaction(expireCtx, transaction.ID, transaction.Status, status)
+		if err != nil {
+			return expired, err
+		}
+		if !updated {
+			continue
+		}
+		expired++
+
+		transaction, err = s.repo.FindTransactionByID(expireCtx, transaction.ID)
+		if err != nil {
+			continue
+		}
+		go NotifyTransactionUpdate(transaction.ID, transaction)
+		s.webhooks.EmitTransaction(expireCtx, models.WebhookEventTransactionExpired, transaction)
+	}
+
+	return expired, nil
 }
 
-func (s *PosService) StartPendingCleanup(ctx context.Context, interval time.Duration, olderThan time.Duration) {
+func (s *PosService) StartExpiryChecker(ctx context.Context, interval time.Duration) {
 	if ctx == nil {
 		return
 	}
@@ -273,8 +631,8 @@ func (s *PosService) StartPendingCleanup(ctx context.Context, interval time.Dura
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				if _, err := s.CleanupOldPendingTransactions(ctx, olderThan); err != nil {
-					log.Printf("pending transaction cleanup failed: %v", err)
+				if _, err := s.ExpireTransactions(ctx); err != nil {
+					log.Printf("transaction expiry failed: %v", err)
 				}
 			}
 		}
@@ -0,0 +1,43 @@
+package pos
+
+import (
+	"testing"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+func TestAddTip(t *testing.T) {
+	// A fiat tip is converted at the rate of the sale
+	fiat := &models.Transaction{Amount: 100_000_000_000, AmountInCurrency: 17, ExchangeRate: 170, TipInCurrency: 2.004}
+	if err := addTip(fiat); err != nil {
+		t.Fatal(err)
+	}
+	if fiat.TipInCurrency != 2 || fiat.TipAmount != 11_764_705_882 || fiat.Amount != 111_764_705_882 || fiat.AmountInCurrency != 19 {
+		t.Fatalf("fiat tip: got %+v", fiat)
+	}
+
+	// A tip in XMR on a fiat sale is shown in the currency too
+	xmrOnFiat := &models.Transaction{Amount: 100_000_000_000, AmountInCurrency: 17, ExchangeRate: 170, TipAmount: 10_000_000_000}
+	if err := addTip(xmrOnFiat); err != nil {
+		t.Fatal(err)
+	}
+	if xmrOnFiat.TipInCurrency != 1.7 || xmrOnFiat.Amount != 110_000_000_000 || xmrOnFiat.AmountInCurrency != 18.7 {
+		t.Fatalf("xmr tip on a fiat sale: got %+v", xmrOnFiat)
+	}
+
+	xmr := &models.Transaction{Amount: 100_000_000_000, TipAmount: 5}
+	if err := addTip(xmr); err != nil || xmr.Amount != 100_000_000_005 || xmr.TipInCurrency != 0 {
+		t.Fatalf("xmr tip: got %+v, %v", xmr, err)
+	}
+
+	for name, transaction := range map[string]*models.Transaction{
+		"negative tip":          {TipAmount: -1},
+		"negative fiat tip":     {ExchangeRate: 170, TipInCurrency: -1},
+		"both tips":             {ExchangeRate: 170, TipAmount: 1, TipInCurrency: 1},
+		"fiat tip on xmr sales": {TipInCurrency: 1},
+	} {
+		if err := addTip(transaction); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
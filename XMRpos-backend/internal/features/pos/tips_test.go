@@ -0,0 +1,140 @@
+package pos_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+func TestTips(t *testing.T) {
+	env := testutil.NewEnv(t)
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	type sale struct {
+		Amount           int64   `json:"amount"`
+		AmountInCurrency float64 `json:"amount_in_currency"`
+		TipAmount        int64   `json:"tip_amount"`
+		TipInCurrency    float64 `json:"tip_in_currency"`
+	}
+	// The tip is added on top of the sale at the rate the sale was priced at, 100 EUR/XMR here
+	var fiatTip sale
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 10, "amount_in_currency": 10.0, "currency": "EUR", "required_confirmations": 0, "tip_in_currency": 2.0,
+	}, &fiatTip)
+	if fiatTip.Amount != oneXMR/10+oneXMR/50 || fiatTip.AmountInCurrency != 12 || fiatTip.TipAmount != oneXMR/50 || fiatTip.TipInCurrency != 2 {
+		t.Fatalf("unexpected fiat tip: %+v", fiatTip)
+	}
+	var atomicTip sale
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR / 10, "amount_in_currency": 10.0, "currency": "EUR", "required_confirmations": 0, "tip": oneXMR / 100,
+	}, &atomicTip)
+	if atomicTip.Amount != oneXMR/10+oneXMR/100 || atomicTip.AmountInCurrency != 11 || atomicTip.TipInCurrency != 1 {
+		t.Fatalf("unexpected atomic tip: %+v", atomicTip)
+	}
+	var xmrTip sale
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR, "currency": "XMR", "required_confirmations": 0, "tip": oneXMR / 100,
+	}, &xmrTip)
+	if xmrTip.Amount != oneXMR+oneXMR/100 || xmrTip.TipAmount != oneXMR/100 || xmrTip.TipInCurrency != 0 {
+		t.Fatalf("unexpected XMR tip: %+v", xmrTip)
+	}
+	if receives := env.MoneroPay.Receives(); receives[len(receives)-1].Request.Amount != oneXMR+oneXMR/100 {
+		t.Fatalf("receive amount does not include the tip: %d", receives[len(receives)-1].Request.Amount)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"negative tip":      {"amount": oneXMR, "amount_in_currency": 100.0, "currency": "EUR", "tip": -1},
+		"both tips":         {"amount": oneXMR, "amount_in_currency": 100.0, "currency": "EUR", "tip": 1, "tip_in_currency": 1.0},
+		"fiat tip in XMR":   {"amount": oneXMR, "currency": "XMR", "tip_in_currency": 1.0},
+		"negative fiat tip": {"amount": oneXMR, "amount_in_currency": 100.0, "currency": "EUR", "tip_in_currency": -1.0},
+	} {
+		if code, body := env.Do(t, http.MethodPost, "/pos/create-transaction", posToken, body); code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400: %s", name, code, body)
+		}
+	}
+
+	// Only accepted tips are reported, per POS and period
+	till2 := env.Store.AddPos(env.Vendor.ID, "till-2", testutil.PosPassword)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 10, 0, 0, 0, time.UTC) }
+	for _, seed := range []struct {
+		pos      *models.Pos
+		currency string
+		tip      int64
+		fiat     float64
+		status   string
+		accepted bool
+		at       time.Time
+	}{
+		{env.Pos, "EUR", oneXMR / 50, 2, models.TransactionStatusPaid, true, day(time.March, 2)},
+		{env.Pos, "EUR", oneXMR / 100, 1, models.TransactionStatusPaid, true, day(time.March, 4)},
+		{till2, "EUR", oneXMR * 4 / 100, 4, models.TransactionStatusPaid, true, day(time.March, 10)},
+		{till2, "EUR", oneXMR, 100, models.TransactionStatusPending, false, day(time.March, 10)},
+		{env.Pos, "EUR", oneXMR, 100, models.TransactionStatusReversed, true, day(time.March, 11)},
+		{env.Pos, "XMR", oneXMR / 100, 0, models.TransactionStatusPaid, true, day(time.March, 11)},
+		{env.Pos, "EUR", oneXMR, 100, models.TransactionStatusPaid, true, day(time.April, 2)},
+	} {
+		id := env.Store.AddTransaction(models.Transaction{
+			VendorID: env.Vendor.ID, PosID: &seed.pos.ID, Amount: oneXMR + seed.tip, Currency: seed.currency,
+			TipAmount: seed.tip, TipInCurrency: seed.fiat, Status: seed.status, Accepted: seed.accepted,
+		})
+		env.Store.SetTransactionCreatedAt(id, seed.at)
+	}
+
+	var report struct {
+		Period string `json:"period"`
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Tips   []struct {
+			PosName       string  `json:"pos_name"`
+			PeriodStart   string  `json:"period_start"`
+			Currency      string  `json:"currency"`
+			Transactions  int     `json:"transactions"`
+			TipAmount     int64   `json:"tip_amount"`
+			TipInCurrency float64 `json:"tip_in_currency"`
+		} `json:"tips"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/reports/tips?period=week&from=2026-03-01&to=2026-03-31", vendorToken, nil, &report)
+	if report.Period != "week" || report.From != "2026-03-01" || report.To != "2026-04-01" || len(report.Tips) != 3 {
+		t.Fatalf("unexpected tip report: %+v", report)
+	}
+	if first := report.Tips[0]; first.PosName != "till-1" || first.PeriodStart != "2026-03-02" || first.Transactions != 2 || first.TipAmount != oneXMR*3/100 || first.TipInCurrency != 3 {
+		t.Fatalf("unexpected first week: %+v", first)
+	}
+	if report.Tips[1].PosName != "till-1" || report.Tips[1].Currency != "XMR" || report.Tips[2].PosName != "till-2" || report.Tips[2].PeriodStart != "2026-03-09" {
+		t.Fatalf("unexpected second week: %+v", report.Tips[1:])
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/reports/tips?period=month&from=2026-03-01&to=2026-04-30", vendorToken, nil, &report)
+	if len(report.Tips) != 4 || report.Tips[0].PeriodStart != "2026-03-01" || report.Tips[3].PeriodStart != "2026-04-01" {
+		t.Fatalf("unexpected monthly report: %+v", report.Tips)
+	}
+
+	var pool struct {
+		Pools []struct {
+			Currency     string `json:"currency"`
+			Transactions int    `json:"transactions"`
+			TipAmount    int64  `json:"tip_amount"`
+			Shares       []struct {
+				PosName      string  `json:"pos_name"`
+				TipAmount    int64   `json:"tip_amount"`
+				SharePercent float64 `json:"share_percent"`
+			} `json:"shares"`
+		} `json:"pools"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/reports/tip-pool?from=2026-03-01&to=2026-03-31", vendorToken, nil, &pool)
+	if len(pool.Pools) != 2 || pool.Pools[0].Currency != "EUR" || pool.Pools[0].Transactions != 3 || pool.Pools[0].TipAmount != oneXMR*7/100 {
+		t.Fatalf("unexpected tip pool: %+v", pool.Pools)
+	}
+	if shares := pool.Pools[0].Shares; len(shares) != 2 || shares[0].PosName != "till-2" || shares[0].SharePercent != 57.14 || shares[1].SharePercent != 42.86 {
+		t.Fatalf("unexpected tip shares: %+v", shares)
+	}
+
+	for _, query := range []string{"?period=year", "?from=03-01-2026", "?from=2026-03-10&to=2026-03-01", "?from=2024-01-01&to=2026-01-01"} {
+		if code, _ := env.Do(t, http.MethodGet, "/vendor/reports/tips"+query, vendorToken, nil); code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400", query, code)
+		}
+	}
+}
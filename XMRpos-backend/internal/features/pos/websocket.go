@@ -16,6 +16,8 @@ import (
 type wsClient struct {
 	conn          *websocket.Conn
 	transactionID uint
+	render        func(transaction *models.Transaction) interface{} // nil sends the transaction as is
+	writeMu       sync.Mutex                                        // a connection supports only one concurrent writer
 }
 
 type wsHub struct {
@@ -121,9 +123,15 @@ func (h *PosHandler) TransactionWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ServeTransactionUpdates(w, r, TransactionID, nil)
+}
+
+// ServeTransactionUpdates upgrades the request and pushes every update of the transaction until
+// the peer goes away. render picks what is sent, callers outside the POS use it to hide internals.
+func ServeTransactionUpdates(w http.ResponseWriter, r *http.Request, transactionID uint, render func(transaction *models.Transaction) interface{}) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("transaction websocket upgrade failed (transactionID=%d, posID=%d, vendorID=%d): %v", TransactionID, posID, vendorID, err)
+		log.Printf("transaction websocket upgrade failed (transactionID=%d): %v", transactionID, err)
 		http.Error(w, "Failed to upgrade websocket connection: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -136,23 +144,23 @@ func (h *PosHandler) TransactionWS(w http.ResponseWriter, r *http.Request) {
 	conn.SetPongHandler(func(string) error { return conn.SetReadDeadline(time.Now().Add(pongWait)) })
 	conn.SetReadLimit(1 << 20) // 1MB
 
-	client := &wsClient{conn: conn, transactionID: TransactionID}
+	client := &wsClient{conn: conn, transactionID: transactionID, render: render}
 
 	hub.mu.Lock()
-	hub.clients[TransactionID] = append(hub.clients[TransactionID], client)
+	hub.clients[transactionID] = append(hub.clients[transactionID], client)
 	hub.mu.Unlock()
 
 	defer func() {
 		hub.mu.Lock()
-		clients := hub.clients[TransactionID]
+		clients := hub.clients[transactionID]
 		for i, c := range clients {
 			if c == client {
-				hub.clients[TransactionID] = append(clients[:i], clients[i+1:]...)
+				hub.clients[transactionID] = append(clients[:i], clients[i+1:]...)
 				break
 			}
 		}
-		if len(hub.clients[TransactionID]) == 0 {
-			delete(hub.clients, TransactionID)
+		if len(hub.clients[transactionID]) == 0 {
+			delete(hub.clients, transactionID)
 		}
 		hub.mu.Unlock()
 		_ = conn.Close()
@@ -167,8 +175,11 @@ func (h *PosHandler) TransactionWS(w http.ResponseWriter, r *http.Request) {
 		case <-r.Context().Done():
 			return
 		case <-ticker.C:
+			client.writeMu.Lock()
 			_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+			client.writeMu.Unlock()
+			if err != nil {
 				return
 			}
 		default:
@@ -188,10 +199,16 @@ func NotifyTransactionUpdate(transactionID uint, update interface{}) {
 	hub.mu.Unlock()
 
 	for _, client := range clients {
+		payload := update
+		if transaction, ok := update.(*models.Transaction); ok && client.render != nil {
+			payload = client.render(transaction)
+		}
+		client.writeMu.Lock()
 		// prevent a slow client from blocking others
 		_ = client.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		if err := client.conn.WriteJSON(update); err != nil {
+		if err := client.conn.WriteJSON(payload); err != nil {
 			_ = client.conn.Close()
 		}
+		client.writeMu.Unlock()
 	}
 }
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+)
+
+func TestSign(t *testing.T) {
+	// Receivers recompute the HMAC from the header timestamp and the raw body
+	const want = "47333fd63cada4e9626dfeb8cf02350e9dadd98c91d07f65f7e066449ccf8027"
+	if got := Sign("whsec_test", "1700000000", []byte(`{"type":"transaction.confirmed"}`)); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if Sign("other-secret", "1700000000", []byte(`{"type":"transaction.confirmed"}`)) == want {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := &WebhookService{config: &config.Config{}}
+	for attempts, want := range map[int]time.Duration{
+		1:  defaultRetryBaseDelay,
+		2:  2 * defaultRetryBaseDelay,
+		5:  16 * defaultRetryBaseDelay,
+		9:  256 * defaultRetryBaseDelay,
+		11: maxRetryDelay,
+	} {
+		if got := s.retryDelay(attempts); got != want {
+			t.Errorf("attempt %d: got %s, want %s", attempts, got, want)
+		}
+	}
+
+	s.config.WebhookRetryBaseDelay = time.Second
+	if got := s.retryDelay(3); got != 4*time.Second {
+		t.Fatalf("configured base delay: got %s, want 4s", got)
+	}
+	if got := s.retryDelay(1000); got != maxRetryDelay {
+		t.Fatalf("delay not capped: got %s", got)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":         true,
+		"2606:2800:220:1::248":  true,
+		"127.0.0.1":             false,
+		"::1":                   false,
+		"10.1.2.3":              false,
+		"172.16.0.1":            false,
+		"192.168.1.1":           false,
+		"169.254.169.254":       false,
+		"100.64.0.1":            false,
+		"0.0.0.0":               false,
+		"fd00::1":               false,
+		"fe80::1":               false,
+		"::ffff:127.0.0.1":      false,
+		"::ffff:93.184.216.34":  true,
+		"64:ff9b::a00:1":        false,
+		"2001:db8::1":           false,
+		"255.255.255.255":       false,
+		"224.0.0.1":             false,
+		"198.51.100.7":          false,
+		"2002:a00:1::1":         false,
+		"100::1":                false,
+		"2001:0:4136:e378::1":   false,
+		"240.0.0.1":             false,
+		"192.0.2.1":             false,
+		"203.0.113.9":           false,
+		"198.18.0.1":            false,
+		"64:ff9b:1::a00:1":      false,
+		"2a00:1450:4001:82a::e": true,
+		"8.8.8.8":               true,
+	} {
+		if got := publicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("%s: got %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/utils"
+)
+
+type WebhookHandler struct {
+	service *WebhookService
+}
+
+func NewWebhookHandler(service *WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+type createEndpointRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type createEndpointResponse struct {
+	EndpointSummary
+	Secret string `json:"secret"`
+}
+
+type deleteEndpointRequest struct {
+	ID uint `json:"id"`
+}
+
+type listEndpointsResponse struct {
+	Endpoints []EndpointSummary `json:"endpoints"`
+}
+
+type listDeliveriesResponse struct {
+	Deliveries []DeliverySummary `json:"deliveries"`
+}
+
+func (h *WebhookHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req createEndpointRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, secret, httpErr := h.service.CreateEndpoint(ctx, id, req.URL, req.Events)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(createEndpointResponse{EndpointSummary: *endpoint, Secret: secret})
+	io.Copy(io.Discard, r.Body)
+}
+
+func (h *WebhookHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, httpErr := h.service.ListEndpoints(ctx, id)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listEndpointsResponse{Endpoints: endpoints})
+}
+
+func (h *WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var req deleteEndpointRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	if httpErr := h.service.DeleteEndpoint(ctx, id, req.ID); httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	io.Copy(io.Discard, r.Body)
+}
+
+// ListDeliveries returns the delivery log, including the error of the last failed attempt
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, ok := utils.VendorID(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, httpErr := h.service.ListDeliveries(ctx, id)
+	if httpErr != nil {
+		http.Error(w, httpErr.Message, httpErr.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listDeliveriesResponse{Deliveries: deliveries})
+}
@@ -0,0 +1,192 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/webhook"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+const oneXMR = int64(1_000_000_000_000)
+
+// webhookReceiver records the events delivered to it and fails the first failFirst requests
+type webhookReceiver struct {
+	mu        sync.Mutex
+	failFirst int
+	requests  int
+	events    []webhook.Event
+}
+
+func (rcv *webhookReceiver) start(t *testing.T, secret *string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, signature, _ := strings.Cut(strings.TrimPrefix(r.Header.Get(webhook.SignatureHeader), "t="), ",v1=")
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if signature != webhook.Sign(*secret, timestamp, body) {
+			t.Errorf("invalid webhook signature %q", r.Header.Get(webhook.SignatureHeader))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rcv.requests++
+		if rcv.requests <= rcv.failFirst {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var event webhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("decode webhook event: %v", err)
+		}
+		if event.Type != r.Header.Get("X-XMRpos-Event") {
+			t.Errorf("event header %q does not match event %q", r.Header.Get("X-XMRpos-Event"), event.Type)
+		}
+		rcv.events = append(rcv.events, event)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (rcv *webhookReceiver) types() []string {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	out := make([]string, len(rcv.events))
+	for i, event := range rcv.events {
+		out[i] = event.Type
+	}
+	return out
+}
+
+func TestWebhooks(t *testing.T) {
+	env := testutil.NewEnv(t, func(cfg *config.Config) {
+		cfg.WebhookAllowHTTP = true
+		cfg.WebhookAllowPrivateHosts = true
+		cfg.WebhookDispatchInterval = 20 * time.Millisecond
+		cfg.WebhookRetryBaseDelay = 10 * time.Millisecond
+		cfg.ReorgWatchWindow = time.Nanosecond // Pay out payments right after they confirm
+	})
+	posToken := env.LoginPos(t)
+	vendorToken := env.LoginVendor(t)
+
+	var paymentsSecret, transfersSecret string
+	payments := &webhookReceiver{failFirst: 1}
+	paymentsServer := payments.start(t, &paymentsSecret)
+	transfers := &webhookReceiver{}
+	transfersServer := transfers.start(t, &transfersSecret)
+
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/webhooks", vendorToken, map[string]any{"url": paymentsServer.URL, "events": []string{"transaction.refunded"}}); code != http.StatusBadRequest {
+		t.Fatalf("unknown event: got status %d, want 400", code)
+	}
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/webhooks", posToken, map[string]any{"url": paymentsServer.URL}); code != http.StatusUnauthorized {
+		t.Fatalf("webhook created by a POS: got status %d, want 401", code)
+	}
+
+	var endpoint struct {
+		ID     uint     `json:"id"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	env.MustDo(t, http.MethodPost, "/vendor/webhooks", vendorToken, map[string]any{"url": paymentsServer.URL}, &endpoint)
+	if !strings.HasPrefix(endpoint.Secret, "whsec_") || len(endpoint.Events) != len(webhook.Events) {
+		t.Fatalf("unexpected endpoint: %+v", endpoint)
+	}
+	paymentsSecret = endpoint.Secret
+	env.MustDo(t, http.MethodPost, "/vendor/webhooks", vendorToken, map[string]any{
+		"url": transfersServer.URL, "events": []string{models.WebhookEventTransferCompleted},
+	}, &endpoint)
+	transfersSecret = endpoint.Secret
+
+	var listed struct {
+		Endpoints []struct {
+			ID     uint   `json:"id"`
+			Secret string `json:"secret"`
+		} `json:"endpoints"`
+	}
+	env.MustDo(t, http.MethodGet, "/vendor/webhooks", vendorToken, nil, &listed)
+	if len(listed.Endpoints) != 2 || listed.Endpoints[0].Secret != "" {
+		t.Fatalf("unexpected endpoint list: %+v", listed)
+	}
+
+	var created struct {
+		ID      uint   `json:"id"`
+		Address string `json:"address"`
+	}
+	env.MustDo(t, http.MethodPost, "/pos/create-transaction", posToken, map[string]any{
+		"amount": oneXMR, "amount_in_currency": 150.0, "currency": "EUR", "required_confirmations": 0,
+	}, &created)
+	payment := testutil.Payment("hash-1", oneXMR, 10)
+	status := env.MoneroPay.SetPayments(created.Address, payment)
+	jwt := testutil.CallbackJWT(t, env.MoneroPay.Receives()[0])
+	for i := 0; i < 2; i++ {
+		if code := env.SendCallback(t, jwt, status, payment); code != http.StatusOK {
+			t.Fatalf("callback: got status %d", code)
+		}
+	}
+
+	// The first delivery fails and is retried, repeated callbacks do not raise events twice
+	want := []string{models.WebhookEventTransactionCreated, models.WebhookEventTransactionAccepted, models.WebhookEventTransactionConfirmed}
+	testutil.WaitFor(t, "transaction webhooks", func() bool { return len(payments.types()) == len(want) })
+	got := payments.types()
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("delivered events: got %v, want %v", got, want)
+	}
+	payments.mu.Lock()
+	data, _ := json.Marshal(payments.events[0].Data)
+	payments.mu.Unlock()
+	var tx webhook.TransactionData
+	if err := json.Unmarshal(data, &tx); err != nil || tx.ID != created.ID || tx.Amount != oneXMR {
+		t.Fatalf("unexpected event data: %s", data)
+	}
+
+	// Only the transfer event reaches the endpoint subscribed to transfers
+	env.MustDo(t, http.MethodPost, "/vendor/transfer-balance", vendorToken, nil, nil)
+	testutil.WaitFor(t, "transfer webhooks", func() bool { return len(transfers.types()) == 1 && len(payments.types()) == len(want)+1 })
+	if got := transfers.types(); got[0] != models.WebhookEventTransferCompleted {
+		t.Fatalf("endpoint received an event it is not subscribed to: %v", got)
+	}
+
+	var log struct {
+		Deliveries []webhook.DeliverySummary `json:"deliveries"`
+	}
+	// The endpoint can answer before the dispatcher records the delivery
+	testutil.WaitFor(t, "recorded deliveries", func() bool {
+		env.MustDo(t, http.MethodGet, "/vendor/webhooks/deliveries", vendorToken, nil, &log)
+		for _, delivery := range log.Deliveries {
+			if delivery.Status == models.WebhookDeliveryPending {
+				return false
+			}
+		}
+		return true
+	})
+	if len(log.Deliveries) != 5 {
+		t.Fatalf("expected 5 deliveries, got %+v", log.Deliveries)
+	}
+	retried := 0
+	for _, delivery := range log.Deliveries {
+		if delivery.Status != models.WebhookDeliveryDelivered {
+			t.Fatalf("delivery not delivered: %+v", delivery)
+		}
+		if delivery.Attempts == 2 {
+			retried++
+		}
+	}
+	if retried != 1 {
+		t.Fatalf("expected one retried delivery, got %d", retried)
+	}
+
+	env.MustDo(t, http.MethodPost, "/vendor/webhooks/delete", vendorToken, map[string]any{"id": endpoint.ID}, nil)
+	if code, _ := env.Do(t, http.MethodPost, "/vendor/webhooks/delete", vendorToken, map[string]any{"id": endpoint.ID}); code != http.StatusNotFound {
+		t.Fatalf("deleting a deleted endpoint: got status %d, want 404", code)
+	}
+}
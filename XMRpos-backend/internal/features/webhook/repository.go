@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	FindEndpointsByVendorID(ctx context.Context, vendorID uint) ([]*models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, vendorID uint, endpointID uint) (bool, error)
+	CreateDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	FindDeliveriesByVendorID(ctx context.Context, vendorID uint, limit int) ([]*models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Create(endpoint).Error
+}
+
+func (r *webhookRepository) FindEndpointsByVendorID(ctx context.Context, vendorID uint) ([]*models.WebhookEndpoint, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var endpoints []*models.WebhookEndpoint
+	if err := r.db.WithContext(ctx).
+		Where("vendor_id = ?", vendorID).
+		Order("id ASC").
+		Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes the endpoint and gives up on the deliveries still queued for it
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, vendorID uint, endpointID uint) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND vendor_id = ?", endpointID, vendorID).Delete(&models.WebhookEndpoint{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("endpoint_id = ? AND status = ?", endpointID, models.WebhookDeliveryPending).
+			Updates(map[string]interface{}{
+				"status":     models.WebhookDeliveryFailed,
+				"last_error": "endpoint deleted",
+			}).Error
+	})
+	return deleted, err
+}
+
+// CreateDeliveries queues the deliveries, events that were already queued for an endpoint are skipped
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+// FindDueDeliveries returns the pending deliveries whose next attempt is due, oldest first
+func (r *webhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var deliveries []*models.WebhookDelivery
+	if err := r.db.WithContext(ctx).
+		Preload("Endpoint").
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":             delivery.Status,
+			"attempts":           delivery.Attempts,
+			"next_attempt_at":    delivery.NextAttemptAt,
+			"last_response_code": delivery.LastResponseCode,
+			"last_error":         delivery.LastError,
+			"delivered_at":       delivery.DeliveredAt,
+		}).Error
+}
+
+// FindDeliveriesByVendorID returns the most recent deliveries of the vendor, newest first
+func (r *webhookRepository) FindDeliveriesByVendorID(ctx context.Context, vendorID uint, limit int) ([]*models.WebhookDelivery, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var deliveries []*models.WebhookDelivery
+	if err := r.db.WithContext(ctx).
+		Where("vendor_id = ?", vendorID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,561 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+const (
+	// How long the first retry waits when WEBHOOK_RETRY_BASE_DELAY is not set, it doubles on every attempt
+	defaultRetryBaseDelay = 30 * time.Second
+	maxRetryDelay         = 6 * time.Hour
+	maxDeliveryAttempts   = 10
+	maxEndpointsPerVendor = 10
+	dispatchBatchSize     = 50
+	deliveryLogLimit      = 100
+)
+
+// SignatureHeader carries "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">" keyed with the endpoint secret
+const SignatureHeader = "X-XMRpos-Signature"
+
+// Events lists every event type an endpoint can subscribe to
+var Events = []string{
+	models.WebhookEventTransactionCreated,
+	models.WebhookEventTransactionAccepted,
+	models.WebhookEventTransactionConfirmed,
+	models.WebhookEventTransactionExpired,
+	models.WebhookEventTransactionAtRisk,
+	models.WebhookEventTransactionReversed,
+	models.WebhookEventTransferCompleted,
+}
+
+type WebhookService struct {
+	repo       WebhookRepository
+	config     *config.Config
+	httpClient *http.Client
+}
+
+func NewWebhookService(repo WebhookRepository, cfg *config.Config) *WebhookService {
+	// The address is checked once it is resolved for the connection, a hostname that resolved to a
+	// public address at registration may point somewhere else by now
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.WebhookAllowPrivateHosts {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(addrPort.Addr()) {
+				return fmt.Errorf("webhook endpoint address %s is not public", addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the endpoint and hide its address from the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookService{
+		repo:   repo,
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			// A redirect could point the signed payload anywhere, the endpoint has to answer itself
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Event is the body POSTed to an endpoint. The ID stays the same across retries so receivers can deduplicate.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type TransactionData struct {
+	ID                    uint    `json:"id"`
+	PosID                 *uint   `json:"pos_id"` // Null for invoices
+	OrderID               *string `json:"order_id,omitempty"`
+	Amount                int64   `json:"amount"`
+	AmountReceived        int64   `json:"amount_received"`
+	AmountInCurrency      float64 `json:"amount_in_currency"`
+	TipAmount             int64   `json:"tip_amount"` // Included in amount
+	TipInCurrency         float64 `json:"tip_in_currency"`
+	Currency              string  `json:"currency"`
+	ExchangeRate          float64 `json:"exchange_rate"`
+	Description           *string `json:"description"`
+	Address               *string `json:"address"`
+	Status                string  `json:"status"`
+	Accepted              bool    `json:"accepted"`
+	Confirmed             bool    `json:"confirmed"`
+	RequiredConfirmations int64   `json:"required_confirmations"`
+	RiskReason            *string `json:"risk_reason"`
+}
+
+type TransferData struct {
+	ID                uint                      `json:"id"`
+	Amount            int64                     `json:"amount"`
+	AmountTransferred *int64                    `json:"amount_transferred"`
+	Address           string                    `json:"address"`
+	TxHash            *string                   `json:"tx_hash"`
+	Destinations      []TransferDestinationData `json:"destinations,omitempty"` // Outputs of a split payout
+}
+
+type TransferDestinationData struct {
+	Address           string `json:"address"`
+	Label             string `json:"label"`
+	Amount            int64  `json:"amount"`
+	AmountTransferred *int64 `json:"amount_transferred"`
+}
+
+func NewTransactionData(transaction *models.Transaction) TransactionData {
+	return TransactionData{
+		ID:                    transaction.ID,
+		PosID:                 transaction.PosID,
+		OrderID:               transaction.OrderID,
+		Amount:                transaction.Amount,
+		AmountReceived:        transaction.AmountReceived,
+		AmountInCurrency:      transaction.AmountInCurrency,
+		TipAmount:             transaction.TipAmount,
+		TipInCurrency:         transaction.TipInCurrency,
+		Currency:              transaction.Currency,
+		ExchangeRate:          transaction.ExchangeRate,
+		Description:           transaction.Description,
+		Address:               transaction.SubAddress,
+		Status:                transaction.Status,
+		Accepted:              transaction.Accepted,
+		Confirmed:             transaction.Confirmed,
+		RequiredConfirmations: transaction.RequiredConfirmations,
+		RiskReason:            transaction.RiskReason,
+	}
+}
+
+func NewTransferData(transfer *models.Transfer) TransferData {
+	data := TransferData{
+		ID:                transfer.ID,
+		Amount:            transfer.Amount,
+		AmountTransferred: transfer.AmountTransferred,
+		Address:           transfer.Address,
+		TxHash:            transfer.TxHash,
+	}
+	for _, d := range transfer.Destinations {
+		data.Destinations = append(data.Destinations, TransferDestinationData{
+			Address:           d.Address,
+			Label:             d.Label,
+			Amount:            d.Amount,
+			AmountTransferred: d.AmountTransferred,
+		})
+	}
+	return data
+}
+
+// EmitTransaction queues a transaction event, see Emit. A transaction can be at risk more than
+// once, each episode is an event of its own.
+func (s *WebhookService) EmitTransaction(ctx context.Context, eventType string, transaction *models.Transaction) {
+	subject := strconv.FormatUint(uint64(transaction.ID), 10)
+	if eventType == models.WebhookEventTransactionAtRisk {
+		subject = fmt.Sprintf("%d:%d", transaction.ID, transaction.RiskEpisodes)
+	}
+	s.Emit(ctx, transaction.VendorID, eventType, subject, NewTransactionData(transaction))
+}
+
+// EmitTransfer queues a transfer event, see Emit
+func (s *WebhookService) EmitTransfer(ctx context.Context, eventType string, transfer *models.Transfer) {
+	s.Emit(ctx, transfer.VendorID, eventType, strconv.FormatUint(uint64(transfer.ID), 10), NewTransferData(transfer))
+}
+
+// Emit queues the event for every endpoint of the vendor subscribed to it. An event is queued once
+// per subject, so it can be emitted again safely. Failures are only logged: a webhook must never
+// break the payment flow that raised it.
+func (s *WebhookService) Emit(ctx context.Context, vendorID uint, eventType string, subject string, data any) {
+	if s == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	endpoints, err := s.repo.FindEndpointsByVendorID(ctx, vendorID)
+	if err != nil {
+		log.Printf("Failed to load webhook endpoints of vendor %d: %v", vendorID, err)
+		return
+	}
+
+	key := eventType + ":" + subject
+	now := time.Now().UTC()
+	payload, err := json.Marshal(Event{ID: key, Type: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		log.Printf("Failed to encode webhook event %s: %v", key, err)
+		return
+	}
+
+	var deliveries []*models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !subscribed(endpoint, eventType) {
+			continue
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			VendorID:      vendorID,
+			EventKey:      key,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	if err := s.repo.CreateDeliveries(ctx, deliveries); err != nil {
+		log.Printf("Failed to queue webhook event %s: %v", key, err)
+	}
+}
+
+func subscribed(endpoint *models.WebhookEndpoint, eventType string) bool {
+	if endpoint.Events == "" {
+		return true
+	}
+	return slices.Contains(strings.Split(endpoint.Events, ","), eventType)
+}
+
+func (s *WebhookService) StartDispatcher(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// bound each sweep to avoid piling up
+				sweepCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+				s.dispatch(sweepCtx)
+				cancel()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *WebhookService) dispatch(ctx context.Context) {
+	deliveries, err := s.repo.FindDueDeliveries(ctx, time.Now(), dispatchBatchSize)
+	if err != nil {
+		log.Println("Error fetching due webhook deliveries:", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		s.attempt(ctx, delivery)
+		if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+			log.Printf("Error recording webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+}
+
+// attempt POSTs the payload once and schedules the next retry with exponential backoff on failure
+func (s *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	if delivery.Endpoint.ID == 0 {
+		message := "endpoint deleted"
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = &message
+		return
+	}
+	delivery.Attempts++
+
+	code, err := s.post(ctx, delivery)
+	delivery.LastResponseCode = code
+	if err == nil {
+		now := time.Now().UTC()
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		return
+	}
+
+	message := err.Error()
+	delivery.LastError = &message
+	if delivery.Attempts >= maxDeliveryAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(s.retryDelay(delivery.Attempts))
+}
+
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.config.WebhookRetryBaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func (s *WebhookService) post(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "XMRpos-Webhooks/1")
+	req.Header.Set("X-XMRpos-Event", delivery.EventType)
+	req.Header.Set("X-XMRpos-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+Sign(delivery.Endpoint.Secret, timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Ranges outside of what netip classifies that do not lead to a vendor's server either
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddress reports whether a webhook may be delivered to addr. Loopback, private, link-local
+// and reserved addresses would let a vendor reach services on the server's own network.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPublicHost resolves the host of an endpoint and refuses it when any of its addresses is not public
+func checkPublicHost(ctx context.Context, host string) *models.HTTPError {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		addrs, err = net.DefaultResolver.LookupNetIP(lookupCtx, "ip", host)
+		if err != nil || len(addrs) == 0 {
+			return models.NewHTTPError(http.StatusBadRequest, "url host cannot be resolved")
+		}
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return models.NewHTTPError(http.StatusBadRequest, "url must not point to a loopback, private or reserved address")
+		}
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>", receivers recompute it to verify a delivery
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type EndpointSummary struct {
+	ID        uint     `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+func newEndpointSummary(endpoint *models.WebhookEndpoint) EndpointSummary {
+	events := Events
+	if endpoint.Events != "" {
+		events = strings.Split(endpoint.Events, ",")
+	}
+	return EndpointSummary{
+		ID:        endpoint.ID,
+		URL:       endpoint.URL,
+		Events:    events,
+		CreatedAt: endpoint.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// CreateEndpoint registers an endpoint and returns the signing secret, which is only shown this once
+func (s *WebhookService) CreateEndpoint(ctx context.Context, vendorID uint, rawURL string, events []string) (*EndpointSummary, string, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && !(parsed.Scheme == "http" && s.config.WebhookAllowHTTP)) {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, "url must be an absolute https URL")
+	}
+
+	if !s.config.WebhookAllowPrivateHosts {
+		if httpErr := checkPublicHost(ctx, parsed.Hostname()); httpErr != nil {
+			return nil, "", httpErr
+		}
+	}
+
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return nil, "", models.NewHTTPError(http.StatusBadRequest, "unknown event: "+event)
+		}
+	}
+	slices.Sort(events)
+	events = slices.Compact(events)
+
+	existing, err := s.repo.FindEndpointsByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	if len(existing) >= maxEndpointsPerVendor {
+		return nil, "", models.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a vendor can register at most %d webhook endpoints", maxEndpointsPerVendor))
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "error generating secret")
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		VendorID: vendorID,
+		URL:      parsed.String(),
+		Secret:   "whsec_" + hex.EncodeToString(secret),
+		Events:   strings.Join(events, ","),
+	}
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, "", models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	summary := newEndpointSummary(endpoint)
+	return &summary, endpoint.Secret, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, vendorID uint) ([]EndpointSummary, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	endpoints, err := s.repo.FindEndpointsByVendorID(ctx, vendorID)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	result := make([]EndpointSummary, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		result = append(result, newEndpointSummary(endpoint))
+	}
+	return result, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, vendorID uint, endpointID uint) *models.HTTPError {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deleted, err := s.repo.DeleteEndpoint(ctx, vendorID, endpointID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+	if !deleted {
+		return models.NewHTTPError(http.StatusNotFound, "webhook endpoint not found")
+	}
+	return nil
+}
+
+type DeliverySummary struct {
+	ID               uint    `json:"id"`
+	EndpointID       uint    `json:"endpoint_id"`
+	EventID          string  `json:"event_id"`
+	EventType        string  `json:"event_type"`
+	Status           string  `json:"status"`
+	Attempts         int     `json:"attempts"`
+	LastResponseCode int     `json:"last_response_code"`
+	LastError        *string `json:"last_error"`
+	NextAttemptAt    *string `json:"next_attempt_at"`
+	DeliveredAt      *string `json:"delivered_at"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+// ListDeliveries returns the delivery log of the vendor, newest first
+func (s *WebhookService) ListDeliveries(ctx context.Context, vendorID uint) ([]DeliverySummary, *models.HTTPError) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deliveries, err := s.repo.FindDeliveriesByVendorID(ctx, vendorID, deliveryLogLimit)
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, "DB error: "+err.Error())
+	}
+
+	result := make([]DeliverySummary, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		summary := DeliverySummary{
+			ID:               delivery.ID,
+			EndpointID:       delivery.EndpointID,
+			EventID:          delivery.EventKey,
+			EventType:        delivery.EventType,
+			Status:           delivery.Status,
+			Attempts:         delivery.Attempts,
+			LastResponseCode: delivery.LastResponseCode,
+			LastError:        delivery.LastError,
+			CreatedAt:        delivery.CreatedAt.Format(time.RFC3339),
+		}
+		if delivery.Status == models.WebhookDeliveryPending {
+			next := delivery.NextAttemptAt.Format(time.RFC3339)
+			summary.NextAttemptAt = &next
+		}
+		if delivery.DeliveredAt != nil {
+			delivered := delivery.DeliveredAt.Format(time.RFC3339)
+			summary.DeliveredAt = &delivered
+		}
+		result = append(result, summary)
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package webhook_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/webhook"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/testutil"
+)
+
+// An event is queued once per subject, but a transaction that is at risk again is a new event
+func TestAtRiskEpisodesAreSeparateEvents(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewStore()
+	repo := store.WebhookRepository()
+	if err := repo.CreateEndpoint(ctx, &models.WebhookEndpoint{VendorID: 1, URL: "https://shop.example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	service := webhook.NewWebhookService(repo, &config.Config{})
+
+	transaction := &models.Transaction{VendorID: 1, Status: models.TransactionStatusAtRisk, RiskEpisodes: 1}
+	transaction.ID = 7
+	service.EmitTransaction(ctx, models.WebhookEventTransactionAtRisk, transaction)
+	service.EmitTransaction(ctx, models.WebhookEventTransactionAtRisk, transaction)
+	service.EmitTransaction(ctx, models.WebhookEventTransactionReversed, transaction)
+	transaction.RiskEpisodes = 2
+	service.EmitTransaction(ctx, models.WebhookEventTransactionAtRisk, transaction)
+
+	var keys []string
+	for _, delivery := range store.WebhookDeliveries() {
+		keys = append(keys, delivery.EventKey)
+	}
+	want := []string{"transaction.at_risk:7:1", "transaction.reversed:7", "transaction.at_risk:7:2"}
+	if len(keys) != len(want) {
+		t.Fatalf("queued events: got %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("queued events: got %v, want %v", keys, want)
+		}
+	}
+}
+
+// Endpoints must not reach into the server's own network
+func TestCreateEndpointRejectsInternalHosts(t *testing.T) {
+	ctx := context.Background()
+	service := webhook.NewWebhookService(testutil.NewStore().WebhookRepository(), &config.Config{})
+
+	for _, rawURL := range []string{
+		"https://127.0.0.1/hook",
+		"https://localhost:8443/hook",
+		"https://10.1.2.3/hook",
+		"https://192.168.1.10/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/hook",
+		"https://0.0.0.0/hook",
+		"https://[::1]/hook",
+		"https://[fd00::1]/hook",
+		"https://[fe80::1]/hook",
+		"https://[::ffff:192.168.1.10]/hook",
+	} {
+		if _, _, err := service.CreateEndpoint(ctx, 1, rawURL, nil); err == nil || err.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %v, want 400", rawURL, err)
+		}
+	}
+
+	if _, _, err := service.CreateEndpoint(ctx, 1, "https://93.184.215.14/hook", nil); err != nil {
+		t.Fatalf("public address rejected: %v", err)
+	}
+}
+
+// A hostname can be pointed elsewhere after registration, the connection itself is checked as well
+func TestDeliveryRefusesInternalAddress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	store := testutil.NewStore()
+	repo := store.WebhookRepository()
+	if err := repo.CreateEndpoint(ctx, &models.WebhookEndpoint{VendorID: 1, URL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	service := webhook.NewWebhookService(repo, &config.Config{WebhookAllowHTTP: true, WebhookRetryBaseDelay: time.Hour})
+	transfer := &models.Transfer{VendorID: 1}
+	transfer.ID = 3
+	service.EmitTransfer(ctx, models.WebhookEventTransferCompleted, transfer)
+	service.StartDispatcher(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries := store.WebhookDeliveries()
+		if len(deliveries) == 1 && deliveries[0].LastError != nil {
+			if !strings.Contains(*deliveries[0].LastError, "not public") {
+				t.Fatalf("unexpected delivery error: %s", *deliveries[0].LastError)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery was not attempted: %+v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hits.Load() != 0 {
+		t.Fatal("delivery reached an internal address")
+	}
+}
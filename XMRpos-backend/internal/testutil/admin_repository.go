@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/admin"
+)
+
+// AdminRepository implements admin.AdminRepository.
+type AdminRepository struct{ store *Store }
+
+func (s *Store) AdminRepository() *AdminRepository { return &AdminRepository{store: s} }
+
+func (r *AdminRepository) CreateInvite(ctx context.Context, invite *models.Invite) (*models.Invite, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	invite.Model = r.store.newModel()
+	c := *invite
+	r.store.invites[c.ID] = &c
+	return invite, nil
+}
+
+func (r *AdminRepository) ListVendorsWithBalances(ctx context.Context) ([]admin.VendorSummary, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	results := []admin.VendorSummary{}
+	for _, id := range sortedKeys(r.store.vendors) {
+		v := r.store.vendors[id]
+		if isDeleted(v.Model) {
+			continue
+		}
+		summary := admin.VendorSummary{ID: v.ID, Name: v.Name, MoneroSubaddress: v.MoneroSubaddress}
+		summary.Balance = r.store.vendorBalance(v.ID)
+		results = append(results, summary)
+	}
+	return results, nil
+}
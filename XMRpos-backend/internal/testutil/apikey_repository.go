@@ -0,0 +1,46 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+)
+
+// APIKeyRepository implements apikey.APIKeyRepository.
+type APIKeyRepository struct{ store *Store }
+
+func (s *Store) APIKeyRepository() *APIKeyRepository { return &APIKeyRepository{store: s} }
+
+func (r *APIKeyRepository) CreateKey(ctx context.Context, key *models.APIKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key.Model = r.store.newModel()
+	c := *key
+	r.store.apiKeys[c.ID] = &c
+	return nil
+}
+
+func (r *APIKeyRepository) FindKeysByVendorID(ctx context.Context, vendorID uint) ([]*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.APIKey{}
+	for _, id := range sortedKeys(r.store.apiKeys) {
+		key := r.store.apiKeys[id]
+		if key.VendorID == vendorID && !isDeleted(key.Model) {
+			c := *key
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepository) DeleteKey(ctx context.Context, vendorID uint, keyID uint) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key, ok := r.store.apiKeys[keyID]
+	if !ok || key.VendorID != vendorID || isDeleted(key.Model) {
+		return false, nil
+	}
+	softDelete(&key.Model)
+	return true, nil
+}
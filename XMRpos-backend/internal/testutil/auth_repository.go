@@ -0,0 +1,108 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// AuthRepository implements auth.AuthRepository.
+type AuthRepository struct{ store *Store }
+
+func (s *Store) AuthRepository() *AuthRepository { return &AuthRepository{store: s} }
+
+func (r *AuthRepository) FindPosByVendorIDAndName(ctx context.Context, vendorID uint, name string) (*models.Pos, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.pos) {
+		p := r.store.pos[id]
+		if p.VendorID == vendorID && p.Name == name && !isDeleted(p.Model) {
+			c := *p
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *AuthRepository) FindVendorByName(ctx context.Context, name string) (*models.Vendor, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.vendors) {
+		v := r.store.vendors[id]
+		if v.Name == name && !isDeleted(v.Model) {
+			c := *v
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *AuthRepository) FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	v, ok := r.store.vendors[id]
+	if !ok || isDeleted(v.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *v
+	return &c, nil
+}
+
+func (r *AuthRepository) FindPosByID(ctx context.Context, id uint) (*models.Pos, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	p, ok := r.store.pos[id]
+	if !ok || isDeleted(p.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *p
+	return &c, nil
+}
+
+func (r *AuthRepository) UpdateVendorPasswordHash(ctx context.Context, vendorID uint, newPasswordHash string) (uint32, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	v, ok := r.store.vendors[vendorID]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	v.PasswordHash = newPasswordHash
+	v.PasswordVersion++
+	return v.PasswordVersion, nil
+}
+
+func (r *AuthRepository) UpdatePosPasswordHash(ctx context.Context, posID uint, newPasswordHash string) (uint32, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	p, ok := r.store.pos[posID]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	p.PasswordHash = newPasswordHash
+	p.PasswordVersion++
+	return p.PasswordVersion, nil
+}
+
+func (r *AuthRepository) FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.apiKeys) {
+		key := r.store.apiKeys[id]
+		if key.KeyHash == keyHash && !isDeleted(key.Model) {
+			c := *key
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *AuthRepository) UpdateAPIKeyLastUsed(ctx context.Context, keyID uint, usedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if key, ok := r.store.apiKeys[keyID]; ok {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// CallbackRepository implements callback.CallbackRepository.
+type CallbackRepository struct{ store *Store }
+
+func (s *Store) CallbackRepository() *CallbackRepository { return &CallbackRepository{store: s} }
+
+func (r *CallbackRepository) FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error) {
+	return r.store.PosRepository().FindTransactionByID(ctx, id)
+}
+
+func (r *CallbackRepository) FindTransactionsToCheck(ctx context.Context, expiredAfter time.Time, confirmedAfter time.Time) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		open := !tx.Confirmed && (tx.ExpiresAt == nil || tx.ExpiresAt.After(expiredAfter))
+		recent := tx.Confirmed && tx.ConfirmedAt != nil && tx.ConfirmedAt.After(confirmedAfter)
+		watched := tx.Status == models.TransactionStatusAtRisk || open || recent
+		if watched && tx.Status != models.TransactionStatusReversed && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *CallbackRepository) FindTransactionByPaymentID(ctx context.Context, paymentID string) (*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		if tx.PaymentID != nil && *tx.PaymentID == paymentID && !isDeleted(tx.Model) {
+			return r.store.loadTransaction(tx), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *CallbackRepository) FindRecentPendingTransactionsByAmount(ctx context.Context, amount int64, createdAfter time.Time) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range reversed(sortedKeys(r.store.transactions)) {
+		tx := r.store.transactions[id]
+		if tx.Amount == amount && !tx.Confirmed && !tx.CreatedAt.Before(createdAfter) && tx.PaymentID == nil && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *CallbackRepository) UpdateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error) {
+	return r.store.PosRepository().UpdateTransaction(ctx, transaction)
+}
+
+func (r *CallbackRepository) UpdateSubTransaction(ctx context.Context, subTx *models.SubTransaction) (*models.SubTransaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	existing, ok := r.store.subTransactions[subTx.ID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *subTx
+	c.CreatedAt = existing.CreatedAt
+	c.UpdatedAt = time.Now()
+	r.store.subTransactions[c.ID] = &c
+	return subTx, nil
+}
+
+func (r *CallbackRepository) CreateSubTransaction(ctx context.Context, subTx *models.SubTransaction) (*models.SubTransaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	subTx.Model = r.store.newModel()
+	c := *subTx
+	r.store.subTransactions[c.ID] = &c
+	return subTx, nil
+}
+
+func (r *CallbackRepository) CreateLedgerEntries(ctx context.Context, entries []*models.LedgerEntry) error {
+	return r.store.VendorRepository().CreateLedgerEntries(ctx, entries)
+}
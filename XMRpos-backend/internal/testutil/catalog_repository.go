@@ -0,0 +1,218 @@
+package testutil
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/catalog"
+	"gorm.io/gorm"
+)
+
+// CatalogRepository implements catalog.CatalogRepository.
+type CatalogRepository struct{ store *Store }
+
+func (s *Store) CatalogRepository() *CatalogRepository { return &CatalogRepository{store: s} }
+
+func (r *CatalogRepository) FindCategoriesByVendorID(ctx context.Context, vendorID uint) ([]*models.ProductCategory, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.ProductCategory{}
+	for _, id := range sortedKeys(r.store.categories) {
+		category := r.store.categories[id]
+		if category.VendorID == vendorID && !isDeleted(category.Model) {
+			c := *category
+			out = append(out, &c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out, nil
+}
+
+func (r *CatalogRepository) FindCategoryByID(ctx context.Context, vendorID uint, categoryID uint) (*models.ProductCategory, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	category, ok := r.store.categories[categoryID]
+	if !ok || category.VendorID != vendorID || isDeleted(category.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *category
+	return &c, nil
+}
+
+func (r *CatalogRepository) FindCategoryByName(ctx context.Context, vendorID uint, name string) (*models.ProductCategory, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.categories) {
+		category := r.store.categories[id]
+		if category.VendorID == vendorID && category.Name == name && !isDeleted(category.Model) {
+			c := *category
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *CatalogRepository) CreateCategory(ctx context.Context, category *models.ProductCategory) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	category.Model = r.store.newModel()
+	c := *category
+	r.store.categories[c.ID] = &c
+	return nil
+}
+
+func (r *CatalogRepository) UpdateCategory(ctx context.Context, category *models.ProductCategory) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, ok := r.store.categories[category.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	category.UpdatedAt = time.Now()
+	c := *category
+	r.store.categories[c.ID] = &c
+	return nil
+}
+
+func (r *CatalogRepository) DeleteCategory(ctx context.Context, vendorID uint, categoryID uint, revision int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	category, ok := r.store.categories[categoryID]
+	if !ok || category.VendorID != vendorID || isDeleted(category.Model) {
+		return false, nil
+	}
+	softDelete(&category.Model)
+	category.Revision = revision
+	for _, product := range r.store.products {
+		if product.CategoryID != nil && *product.CategoryID == categoryID && !isDeleted(product.Model) {
+			product.CategoryID = nil
+			product.Revision = revision
+			product.UpdatedAt = time.Now()
+		}
+	}
+	return true, nil
+}
+
+func (r *CatalogRepository) FindProductsByVendorID(ctx context.Context, vendorID uint) ([]*models.Product, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Product{}
+	for _, id := range sortedKeys(r.store.products) {
+		product := r.store.products[id]
+		if product.VendorID == vendorID && !isDeleted(product.Model) {
+			c := *product
+			out = append(out, &c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (r *CatalogRepository) FindProductByID(ctx context.Context, vendorID uint, productID uint) (*models.Product, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	product, ok := r.store.products[productID]
+	if !ok || product.VendorID != vendorID || isDeleted(product.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *product
+	return &c, nil
+}
+
+func (r *CatalogRepository) FindProductBySKU(ctx context.Context, vendorID uint, sku string) (*models.Product, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.products) {
+		product := r.store.products[id]
+		if product.VendorID == vendorID && product.SKU != nil && *product.SKU == sku && !isDeleted(product.Model) {
+			c := *product
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *CatalogRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	product.Model = r.store.newModel()
+	c := *product
+	r.store.products[c.ID] = &c
+	return nil
+}
+
+func (r *CatalogRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, ok := r.store.products[product.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	product.UpdatedAt = time.Now()
+	c := *product
+	r.store.products[c.ID] = &c
+	return nil
+}
+
+func (r *CatalogRepository) DeleteProduct(ctx context.Context, vendorID uint, productID uint, revision int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	product, ok := r.store.products[productID]
+	if !ok || product.VendorID != vendorID || isDeleted(product.Model) {
+		return false, nil
+	}
+	softDelete(&product.Model)
+	product.Revision = revision
+	return true, nil
+}
+
+func (r *CatalogRepository) BumpCatalogRevision(ctx context.Context, vendorID uint) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	vendor, ok := r.store.vendors[vendorID]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	vendor.CatalogRevision++
+	return vendor.CatalogRevision, nil
+}
+
+// CatalogVersion waits for any in-flight RunInTransaction, like the database it only returns a
+// committed revision.
+func (r *CatalogRepository) CatalogVersion(ctx context.Context, vendorID uint) (int64, error) {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	vendor, ok := r.store.vendors[vendorID]
+	if !ok {
+		return 0, nil
+	}
+	return vendor.CatalogRevision, nil
+}
+
+func (r *CatalogRepository) FindCatalogChanges(ctx context.Context, vendorID uint, since int64) ([]*models.ProductCategory, []*models.Product, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	categories := []*models.ProductCategory{}
+	for _, id := range sortedKeys(r.store.categories) {
+		category := r.store.categories[id]
+		if category.VendorID == vendorID && category.Revision > since {
+			c := *category
+			categories = append(categories, &c)
+		}
+	}
+	products := []*models.Product{}
+	for _, id := range sortedKeys(r.store.products) {
+		product := r.store.products[id]
+		if product.VendorID == vendorID && product.Revision > since {
+			c := *product
+			products = append(products, &c)
+		}
+	}
+	return categories, products, nil
+}
+
+func (r *CatalogRepository) RunInTransaction(ctx context.Context, fn func(repo catalog.CatalogRepository) error) error {
+	return r.store.runInTransaction(func() error { return fn(r) })
+}
@@ -0,0 +1,217 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/config"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/server"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+// Passwords of the vendor and POS every Env is seeded with.
+const (
+	VendorPassword = "vendor-password"
+	PosPassword    = "pos-password"
+)
+
+// Env is the full router backed by a Store and the fake MoneroPay and wallet RPC,
+// seeded with one vendor and one POS.
+type Env struct {
+	Cfg       *config.Config
+	Store     *Store
+	MoneroPay *FakeMoneroPay
+	Wallet    *FakeWalletRPC
+	Server    *httptest.Server
+	Vendor    *models.Vendor
+	Pos       *models.Pos
+}
+
+// NewEnv starts the router. The options adjust the config before it is used.
+func NewEnv(t *testing.T, opts ...func(cfg *config.Config)) *Env {
+	t.Helper()
+
+	env := &Env{
+		Cfg: &config.Config{
+			AdminName:                 "admin",
+			AdminPassword:             "admin-password",
+			JWTSecret:                 "jwt-secret",
+			JWTRefreshSecret:          "jwt-refresh-secret",
+			JWTMoneroPaySecret:        "jwt-moneropay-secret",
+			JWTLwsToken:               "lws-token",
+			MoneroPayCallbackURL:      "http://backend.test/callback/receive/{jwt}",
+			ConfirmationCheckInterval: time.Hour,
+			TransferCompleterInterval: 20 * time.Millisecond,
+			ExpiryCheckInterval:       20 * time.Millisecond,
+			PayoutSchedulerInterval:   20 * time.Millisecond,
+			PayoutAddressCoolingOff:   50 * time.Millisecond,
+		},
+		Store:     NewStore(),
+		MoneroPay: NewFakeMoneroPay(t),
+		Wallet:    NewFakeWalletRPC(t),
+	}
+	for _, opt := range opts {
+		opt(env.Cfg)
+	}
+	env.Vendor = env.Store.AddVendor("test-vendor", VendorPassword, Subaddress(1))
+	env.Pos = env.Store.AddPos(env.Vendor.ID, "till-1", PosPassword)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// Let the router pick the backend from the config unless MoneroPay is in use
+	var payments payment.PaymentBackend
+	if env.Cfg.PaymentBackend != config.PaymentBackendWalletRPC {
+		payments = env.MoneroPay.Backend()
+	}
+	router := server.NewRouter(ctx, env.Cfg, env.Store.Repositories(), env.Wallet.Client(), payments)
+	env.Server = httptest.NewServer(router)
+	t.Cleanup(env.Server.Close)
+	return env
+}
+
+// Do sends body as JSON and returns the status and response body.
+func (e *Env) Do(t *testing.T, method, path, token string, body any) (int, []byte) {
+	t.Helper()
+	status, _, data := e.DoWithHeaders(t, method, path, token, body, nil)
+	return status, data
+}
+
+// DoWithHeaders is Do with extra request headers, it also returns the response headers.
+func (e *Env) DoWithHeaders(t *testing.T, method, path, token string, body any, header http.Header) (int, http.Header, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, e.Server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return resp.StatusCode, resp.Header, data
+}
+
+// MustDo is Do failing the test on any status but 200. The response is decoded into out unless it is nil.
+func (e *Env) MustDo(t *testing.T, method, path, token string, body any, out any) {
+	t.Helper()
+	status, data := e.Do(t, method, path, token, body)
+	if status != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", method, path, status, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, data, err)
+		}
+	}
+}
+
+// Tokens is the response of the login endpoints.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (e *Env) LoginVendor(t *testing.T) string {
+	t.Helper()
+	var resp Tokens
+	e.MustDo(t, http.MethodPost, "/auth/login-vendor", "", map[string]any{"name": e.Vendor.Name, "password": VendorPassword}, &resp)
+	return resp.AccessToken
+}
+
+func (e *Env) LoginPos(t *testing.T) string {
+	t.Helper()
+	var resp Tokens
+	e.MustDo(t, http.MethodPost, "/auth/login-pos", "", map[string]any{"vendor_id": e.Vendor.ID, "name": e.Pos.Name, "password": PosPassword}, &resp)
+	return resp.AccessToken
+}
+
+func (e *Env) LoginAdmin(t *testing.T) string {
+	t.Helper()
+	var resp Tokens
+	e.MustDo(t, http.MethodPost, "/auth/login-admin", "", map[string]any{"name": e.Cfg.AdminName, "password": e.Cfg.AdminPassword}, &resp)
+	return resp.AccessToken
+}
+
+// CreateVendor registers a vendor through an admin invite and returns its ID.
+func (e *Env) CreateVendor(t *testing.T, name string, subaddress string) uint {
+	t.Helper()
+	var invite struct {
+		InviteCode string `json:"invite_code"`
+	}
+	e.MustDo(t, http.MethodPost, "/admin/invite", e.LoginAdmin(t), map[string]any{"valid_until": time.Now().Add(time.Hour).Unix()}, &invite)
+
+	var created struct {
+		ID uint `json:"id"`
+	}
+	e.MustDo(t, http.MethodPost, "/vendor/create", "", map[string]any{
+		"name": name, "password": VendorPassword, "invite_code": invite.InviteCode, "monero_subaddress": subaddress,
+	}, &created)
+	return created.ID
+}
+
+// SendCallback posts a MoneroPay callback for tx and returns the status.
+func (e *Env) SendCallback(t *testing.T, jwt string, status moneropay.ReceiveAddressResponse, tx moneropay.Transaction) int {
+	t.Helper()
+	payload := moneropay.CallbackResponse{
+		Amount:       status.Amount,
+		Complete:     status.Complete,
+		Description:  status.Description,
+		CreatedAt:    status.CreatedAt,
+		Transactions: status.Transactions,
+		Transaction:  &tx,
+	}
+	code, _ := e.Do(t, http.MethodPost, "/callback/receive/"+jwt, "", payload)
+	return code
+}
+
+// WaitFor polls cond until it holds, the test fails after 5 seconds.
+func WaitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// CallbackJWT extracts the token MoneroPay will call us back with from the registered callback URL.
+func CallbackJWT(t *testing.T, receive FakeReceive) string {
+	t.Helper()
+	const prefix = "http://backend.test/callback/receive/"
+	if !strings.HasPrefix(receive.Request.CallbackUrl, prefix) {
+		t.Fatalf("unexpected callback url %q", receive.Request.CallbackUrl)
+	}
+	return strings.TrimPrefix(receive.Request.CallbackUrl, prefix)
+}
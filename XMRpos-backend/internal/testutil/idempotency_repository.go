@@ -0,0 +1,77 @@
+package testutil
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// IdempotencyRepository implements idempotency.IdempotencyRepository.
+type IdempotencyRepository struct{ store *Store }
+
+func (s *Store) IdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{store: s}
+}
+
+func (r *IdempotencyRepository) CreateKey(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.idempotencyKeys {
+		if existing.Scope == key.Scope && existing.Key == key.Key {
+			return false, nil
+		}
+	}
+	key.Model = r.store.newModel()
+	c := *key
+	r.store.idempotencyKeys[c.ID] = &c
+	return true, nil
+}
+
+func (r *IdempotencyRepository) FindKey(ctx context.Context, scope string, key string) (*models.IdempotencyKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, existing := range r.store.idempotencyKeys {
+		if existing.Scope == scope && existing.Key == key {
+			c := *existing
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *IdempotencyRepository) CompleteKey(ctx context.Context, key *models.IdempotencyKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	existing, ok := r.store.idempotencyKeys[key.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	existing.Completed = true
+	existing.StatusCode = key.StatusCode
+	existing.ContentType = key.ContentType
+	existing.ResponseBody = slices.Clone(key.ResponseBody)
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteKey(ctx context.Context, id uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delete(r.store.idempotencyKeys, id)
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteExpiredKeys(ctx context.Context, now time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var deleted int64
+	for id, key := range r.store.idempotencyKeys {
+		if key.ExpiresAt.Before(now) {
+			delete(r.store.idempotencyKeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,33 @@
+package testutil
+
+import (
+	"context"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// InvoiceRepository implements invoice.InvoiceRepository.
+type InvoiceRepository struct{ store *Store }
+
+func (s *Store) InvoiceRepository() *InvoiceRepository { return &InvoiceRepository{store: s} }
+
+func (r *InvoiceRepository) FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error) {
+	return r.store.AuthRepository().FindVendorByID(ctx, id)
+}
+
+func (r *InvoiceRepository) FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error) {
+	return r.store.PosRepository().FindTransactionByID(ctx, id)
+}
+
+func (r *InvoiceRepository) FindTransactionByCheckoutToken(ctx context.Context, token string) (*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		if tx.CheckoutToken != nil && *tx.CheckoutToken == token && !isDeleted(tx.Model) {
+			return r.store.loadTransaction(tx), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
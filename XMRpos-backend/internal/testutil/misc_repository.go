@@ -0,0 +1,12 @@
+package testutil
+
+import "context"
+
+// MiscRepository implements misc.MiscRepository.
+type MiscRepository struct{ store *Store }
+
+func (s *Store) MiscRepository() *MiscRepository { return &MiscRepository{store: s} }
+
+func (r *MiscRepository) GetPostgresqlHealth(ctx context.Context) (bool, error) {
+	return true, nil
+}
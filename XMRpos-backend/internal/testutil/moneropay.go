@@ -0,0 +1,243 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/payment"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+// FakeMoneroPay is an httptest server speaking the subset of the MoneroPay v2 API used by the backend.
+type FakeMoneroPay struct {
+	Server *httptest.Server
+
+	mu          sync.Mutex
+	nextAddress byte
+	receives    map[string]*FakeReceive
+	order       []string
+	transfers   []moneropay.TransferRequest
+	balance     moneropay.BalanceResponse
+	transferErr bool
+	receiveErr  bool
+	// TransferFee is subtracted from every destination returned by POST /transfer.
+	TransferFee int64
+}
+
+// FakeReceive is a receive address handed out by the fake together with its current payment state.
+type FakeReceive struct {
+	Request moneropay.ReceiveRequest
+	Address string
+	Status  moneropay.ReceiveAddressResponse
+}
+
+func NewFakeMoneroPay(t testing.TB) *FakeMoneroPay {
+	f := &FakeMoneroPay{receives: make(map[string]*FakeReceive)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", f.handleHealth)
+	mux.HandleFunc("GET /balance", f.handleBalance)
+	mux.HandleFunc("POST /receive", f.handlePostReceive)
+	mux.HandleFunc("GET /receive/{address}", f.handleGetReceive)
+	mux.HandleFunc("POST /transfer", f.handlePostTransfer)
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Client returns a MoneroPay client pointed at the fake.
+func (f *FakeMoneroPay) Client() *moneropay.MoneroPayAPIClient {
+	return moneropay.NewMoneroPayAPIClient(f.Server.URL)
+}
+
+// Backend returns a MoneroPay payment backend pointed at the fake.
+func (f *FakeMoneroPay) Backend() payment.PaymentBackend {
+	return payment.NewMoneroPayBackend(f.Client())
+}
+
+// Receives returns the receive addresses created so far in creation order.
+func (f *FakeMoneroPay) Receives() []FakeReceive {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]FakeReceive, 0, len(f.order))
+	for _, address := range f.order {
+		out = append(out, *f.receives[address])
+	}
+	return out
+}
+
+// Transfers returns the transfer requests received so far.
+func (f *FakeMoneroPay) Transfers() []moneropay.TransferRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]moneropay.TransferRequest(nil), f.transfers...)
+}
+
+// SetBalance sets the response of GET /balance.
+func (f *FakeMoneroPay) SetBalance(total, unlocked int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balance = moneropay.BalanceResponse{Total: total, Unlocked: unlocked}
+}
+
+// FailTransfers makes POST /transfer respond with an error.
+func (f *FakeMoneroPay) FailTransfers(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.transferErr = fail
+}
+
+// FailReceives makes POST /receive respond with an error.
+func (f *FakeMoneroPay) FailReceives(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.receiveErr = fail
+}
+
+// SetPayments replaces the incoming transfers seen on address and returns the
+// resulting status, which is also what GET /receive/{address} serves from now on.
+// A transfer counts as unlocked once it has 10 confirmations.
+func (f *FakeMoneroPay) SetPayments(address string, txs ...moneropay.Transaction) moneropay.ReceiveAddressResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	receive, ok := f.receives[address]
+	if !ok {
+		panic(fmt.Sprintf("fake moneropay: unknown address %s", address))
+	}
+	status := moneropay.ReceiveAddressResponse{
+		Amount:       moneropay.Amount{Expected: receive.Request.Amount},
+		Description:  receive.Request.Description,
+		CreatedAt:    receive.Status.CreatedAt,
+		Transactions: append([]moneropay.Transaction(nil), txs...),
+	}
+	for _, tx := range txs {
+		status.Amount.Covered.Total += tx.Amount
+		if tx.Confirmations >= 10 {
+			status.Amount.Covered.Unlocked += tx.Amount
+		}
+	}
+	status.Complete = status.Amount.Covered.Total >= status.Amount.Expected
+	receive.Status = status
+	return status
+}
+
+// Payment builds an incoming transfer with the given confirmations.
+func Payment(txHash string, amount int64, confirmations int64) moneropay.Transaction {
+	// Pool transfers have no height yet, mined ones stay at the block they were mined in
+	var height int64
+	if confirmations > 0 {
+		height = 3_000_000
+	}
+	return moneropay.Transaction{
+		Amount:        amount,
+		Confirmations: confirmations,
+		Height:        height,
+		Timestamp:     time.Now().UTC(),
+		TxHash:        txHash,
+		Locked:        confirmations < 10,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *FakeMoneroPay) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, moneropay.HealthResponse{
+		Status:   http.StatusOK,
+		Services: moneropay.Services{Walletrpc: true, Postgresql: true},
+	})
+}
+
+func (f *FakeMoneroPay) handleBalance(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeJSON(w, f.balance)
+}
+
+func (f *FakeMoneroPay) handlePostReceive(w http.ResponseWriter, r *http.Request) {
+	var req moneropay.ReceiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	if f.receiveErr {
+		f.mu.Unlock()
+		http.Error(w, "receive failed", http.StatusInternalServerError)
+		return
+	}
+	f.nextAddress++
+	address := Subaddress(100 + f.nextAddress)
+	now := time.Now().UTC()
+	f.receives[address] = &FakeReceive{
+		Request: req,
+		Address: address,
+		Status: moneropay.ReceiveAddressResponse{
+			Amount:       moneropay.Amount{Expected: req.Amount},
+			Description:  req.Description,
+			CreatedAt:    now,
+			Transactions: []moneropay.Transaction{},
+		},
+	}
+	f.order = append(f.order, address)
+	f.mu.Unlock()
+
+	writeJSON(w, moneropay.ReceiveResponse{
+		Address:     address,
+		Amount:      req.Amount,
+		Description: req.Description,
+		CreatedAt:   now,
+	})
+}
+
+func (f *FakeMoneroPay) handleGetReceive(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	receive, ok := f.receives[r.PathValue("address")]
+	var status moneropay.ReceiveAddressResponse
+	if ok {
+		status = receive.Status
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (f *FakeMoneroPay) handlePostTransfer(w http.ResponseWriter, r *http.Request) {
+	var req moneropay.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.transferErr {
+		http.Error(w, "transfer failed", http.StatusInternalServerError)
+		return
+	}
+	f.transfers = append(f.transfers, req)
+
+	resp := moneropay.TransferResponse{
+		TxHash:       fmt.Sprintf("moneropay-transfer-%d", len(f.transfers)),
+		Destinations: make([]moneropay.Destination, len(req.Destinations)),
+	}
+	for i, dest := range req.Destinations {
+		resp.Destinations[i] = moneropay.Destination{Address: dest.Address, Amount: dest.Amount - f.TransferFee}
+		resp.Amount += dest.Amount
+		resp.Fee += f.TransferFee
+	}
+	writeJSON(w, resp)
+}
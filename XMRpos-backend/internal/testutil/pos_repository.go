@@ -0,0 +1,88 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// PosRepository implements pos.PosRepository.
+type PosRepository struct{ store *Store }
+
+func (s *Store) PosRepository() *PosRepository { return &PosRepository{store: s} }
+
+func (r *PosRepository) FindVendorByID(ctx context.Context, id uint) (*models.Vendor, error) {
+	return r.store.AuthRepository().FindVendorByID(ctx, id)
+}
+
+func (r *PosRepository) FindTransactionByID(ctx context.Context, id uint) (*models.Transaction, error) {
+	tx, ok := r.store.transaction(id)
+	if !ok || isDeleted(tx.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return tx, nil
+}
+
+func (r *PosRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	transaction.Model = r.store.newModel()
+	// like gorm, the line items are created with the transaction
+	for _, item := range transaction.LineItems {
+		item.Model = r.store.newModel()
+		item.TransactionID = transaction.ID
+		c := *item
+		r.store.lineItems[c.ID] = &c
+	}
+	r.store.putTransaction(transaction)
+	return transaction, nil
+}
+
+func (r *PosRepository) UpdateTransaction(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	transaction.UpdatedAt = time.Now()
+	r.store.putTransaction(transaction)
+	return transaction, nil
+}
+
+func (r *PosRepository) FindTransactionsByPosID(ctx context.Context, vendorID uint, posID uint) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range reversed(sortedKeys(r.store.transactions)) {
+		tx := r.store.transactions[id]
+		if tx.VendorID == vendorID && tx.PosID != nil && *tx.PosID == posID && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *PosRepository) FindTransactionsToExpire(ctx context.Context, now time.Time) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		open := tx.Status == models.TransactionStatusPending || tx.Status == models.TransactionStatusPartiallyPaid
+		if open && !tx.Confirmed && tx.ExpiresAt != nil && tx.ExpiresAt.Before(now) && !isDeleted(tx.Model) {
+			c := *tx
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *PosRepository) ExpireTransaction(ctx context.Context, transactionID uint, fromStatus string, toStatus string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	tx, ok := r.store.transactions[transactionID]
+	if !ok || tx.Status != fromStatus || tx.Confirmed {
+		return false, nil
+	}
+	tx.Status = toStatus
+	return true, nil
+}
@@ -0,0 +1,185 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeSMTP is a local SMTP sink that accepts every message without TLS or authentication.
+type FakeSMTP struct {
+	Host string
+	Port string
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []SMTPMessage
+	reject   bool
+}
+
+// SMTPMessage is one message as it arrived at the sink.
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+func NewFakeSMTP(t testing.TB) *FakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	f := &FakeSMTP{Host: host, Port: port, listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// Messages returns the messages accepted so far.
+func (f *FakeSMTP) Messages() []SMTPMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SMTPMessage(nil), f.messages...)
+}
+
+// SetReject makes the sink refuse recipients like a server rejecting the address.
+func (f *FakeSMTP) SetReject(reject bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reject = reject
+}
+
+func (f *FakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return text.PrintfLine("%s", line) == nil
+	}
+
+	var current SMTPMessage
+	if !reply("220 fake-smtp ESMTP") {
+		return
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 fake-smtp")
+		case "MAIL":
+			current = SMTPMessage{From: trimPath(arg)}
+			reply("250 OK")
+		case "RCPT":
+			f.mu.Lock()
+			reject := f.reject
+			f.mu.Unlock()
+			if reject {
+				reply("550 Mailbox unavailable")
+				continue
+			}
+			current.To = append(current.To, trimPath(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.Data = data
+			f.mu.Lock()
+			f.messages = append(f.messages, current)
+			f.mu.Unlock()
+			reply("250 OK")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// trimPath turns "FROM:<a@b>" into a@b
+func trimPath(arg string) string {
+	_, path, _ := strings.Cut(arg, ":")
+	return strings.Trim(strings.TrimSpace(path), "<>")
+}
+
+// Header returns a decoded header of the message.
+func (m SMTPMessage) Header(name string) string {
+	msg, err := netmail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+	value, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get(name))
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// Part returns the decoded body of the first part with the media type, e.g. text/plain.
+func (m SMTPMessage) Part(mediaType string) []byte {
+	msg, err := netmail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return nil
+	}
+	return findPart(textproto.MIMEHeader(msg.Header), msg.Body, mediaType)
+}
+
+func findPart(header textproto.MIMEHeader, body io.Reader, mediaType string) []byte {
+	contentType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	if strings.HasPrefix(contentType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return nil
+			}
+			if data := findPart(part.Header, part, mediaType); data != nil {
+				return data
+			}
+		}
+	}
+	if contentType != mediaType {
+		return nil
+	}
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil
+	}
+	return data
+}
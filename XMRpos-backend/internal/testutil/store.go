@@ -0,0 +1,554 @@
+// Package testutil provides in-memory repositories and fake MoneroPay / wallet RPC
+// endpoints so the HTTP API can be exercised end-to-end without Postgres or a wallet.
+package testutil
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/ledger"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/server"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Store is an in-memory stand-in for the Postgres schema. Every fake repository
+// created from the same Store sees the same data.
+type Store struct {
+	mu   sync.Mutex
+	txMu sync.Mutex // serializes RunInTransaction callers like a row lock would
+
+	nextID uint
+
+	refundUpdateErr bool // fails marking refunds completed or failed
+
+	invites         map[uint]*models.Invite
+	vendors         map[uint]*models.Vendor
+	pos             map[uint]*models.Pos
+	transactions    map[uint]*models.Transaction
+	subTransactions map[uint]*models.SubTransaction
+	lineItems       map[uint]*models.LineItem
+	transfers       map[uint]*models.Transfer
+	transferOutputs map[uint]*models.TransferDestination
+	destinations    map[uint]*models.PayoutDestination
+	addressChanges  map[uint]*models.PayoutAddressChange
+	ledger          map[uint]*models.LedgerEntry
+	refunds         map[uint]*models.Refund
+	webhooks        map[uint]*models.WebhookEndpoint
+	deliveries      map[uint]*models.WebhookDelivery
+	apiKeys         map[uint]*models.APIKey
+	idempotencyKeys map[uint]*models.IdempotencyKey
+	categories      map[uint]*models.ProductCategory
+	products        map[uint]*models.Product
+}
+
+func NewStore() *Store {
+	return &Store{
+		invites:         make(map[uint]*models.Invite),
+		vendors:         make(map[uint]*models.Vendor),
+		pos:             make(map[uint]*models.Pos),
+		transactions:    make(map[uint]*models.Transaction),
+		subTransactions: make(map[uint]*models.SubTransaction),
+		lineItems:       make(map[uint]*models.LineItem),
+		transfers:       make(map[uint]*models.Transfer),
+		transferOutputs: make(map[uint]*models.TransferDestination),
+		destinations:    make(map[uint]*models.PayoutDestination),
+		addressChanges:  make(map[uint]*models.PayoutAddressChange),
+		ledger:          make(map[uint]*models.LedgerEntry),
+		refunds:         make(map[uint]*models.Refund),
+		webhooks:        make(map[uint]*models.WebhookEndpoint),
+		deliveries:      make(map[uint]*models.WebhookDelivery),
+		apiKeys:         make(map[uint]*models.APIKey),
+		idempotencyKeys: make(map[uint]*models.IdempotencyKey),
+		categories:      make(map[uint]*models.ProductCategory),
+		products:        make(map[uint]*models.Product),
+	}
+}
+
+// Repositories returns fake repositories for every feature, all backed by s.
+func (s *Store) Repositories() server.Repositories {
+	return server.Repositories{
+		Admin:       s.AdminRepository(),
+		Auth:        s.AuthRepository(),
+		Vendor:      s.VendorRepository(),
+		Pos:         s.PosRepository(),
+		Callback:    s.CallbackRepository(),
+		Misc:        s.MiscRepository(),
+		Webhook:     s.WebhookRepository(),
+		Invoice:     s.InvoiceRepository(),
+		APIKey:      s.APIKeyRepository(),
+		Idempotency: s.IdempotencyRepository(),
+		Catalog:     s.CatalogRepository(),
+	}
+}
+
+// newModel must be called with s.mu held.
+func (s *Store) newModel() gorm.Model {
+	s.nextID++
+	now := time.Now()
+	return gorm.Model{ID: s.nextID, CreatedAt: now, UpdatedAt: now}
+}
+
+// AddVendor seeds a vendor with a bcrypt hashed password and returns its copy.
+func (s *Store) AddVendor(name, password, subaddress string) *models.Vendor {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := &models.Vendor{
+		Model:            s.newModel(),
+		Name:             name,
+		PasswordHash:     string(hash),
+		PasswordVersion:  1,
+		MoneroSubaddress: subaddress,
+	}
+	s.vendors[v.ID] = v
+	c := *v
+	return &c
+}
+
+// AddPos seeds a POS device for vendorID and returns its copy.
+func (s *Store) AddPos(vendorID uint, name, password string) *models.Pos {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := &models.Pos{
+		Model:           s.newModel(),
+		Name:            name,
+		PasswordHash:    string(hash),
+		PasswordVersion: 1,
+		VendorID:        vendorID,
+	}
+	s.pos[p.ID] = p
+	c := *p
+	return &c
+}
+
+// AddTransaction seeds a transaction with its SubTransactions (other relations are ignored) and
+// returns its ID. Confirmed transactions that are not held are credited to the vendor's ledger,
+// like the startup backfill does.
+func (s *Store) AddTransaction(tx models.Transaction) uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx.Model = s.newModel()
+	s.putTransaction(&tx)
+	for _, sub := range tx.SubTransactions {
+		c := *sub
+		c.Model = s.newModel()
+		c.TransactionID = tx.ID
+		s.subTransactions[c.ID] = &c
+	}
+	if tx.Confirmed && !slices.Contains(models.HeldTransactionStatuses, tx.Status) {
+		s.createLedgerEntries(ledger.PaymentReceived(&tx))
+	}
+	return tx.ID
+}
+
+// SetVendorWalletAccount moves a vendor to its own wallet account.
+func (s *Store) SetVendorWalletAccount(vendorID uint, accountIndex uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vendors[vendorID]; ok {
+		v.WalletAccountIndex = accountIndex
+	}
+}
+
+// SetVendorEmail sets the address vendor notifications go to.
+func (s *Store) SetVendorEmail(vendorID uint, email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vendors[vendorID]; ok {
+		v.Email = email
+	}
+}
+
+// SetVendorLastScheduledPayout backdates the last scheduled payout, e.g. to make the next one due.
+func (s *Store) SetVendorLastScheduledPayout(vendorID uint, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vendors[vendorID]; ok {
+		v.LastScheduledPayout = &at
+	}
+}
+
+// ExpireTransactionAt moves the end of a transaction's quote window, e.g. into the past.
+func (s *Store) ExpireTransactionAt(id uint, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx, ok := s.transactions[id]; ok {
+		tx.ExpiresAt = &expiresAt
+	}
+}
+
+// SetTransactionCreatedAt backdates a transaction, e.g. to place it in a report period.
+func (s *Store) SetTransactionCreatedAt(id uint, createdAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx, ok := s.transactions[id]; ok {
+		tx.CreatedAt = createdAt
+	}
+}
+
+// BumpVendorPasswordVersion simulates a password change that invalidates issued tokens.
+func (s *Store) BumpVendorPasswordVersion(vendorID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vendors[vendorID]; ok {
+		v.PasswordVersion++
+	}
+}
+
+// Transaction returns a copy of the stored transaction with its subtransactions.
+// It waits for any in-flight RunInTransaction so tests never observe uncommitted state.
+func (s *Store) Transaction(id uint) (*models.Transaction, bool) {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return s.transaction(id)
+}
+
+func (s *Store) transaction(id uint) (*models.Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[id]
+	if !ok {
+		return nil, false
+	}
+	return s.loadTransaction(tx), true
+}
+
+// Transfers returns copies of all committed transfers ordered by ID.
+func (s *Store) Transfers() []*models.Transfer {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*models.Transfer, 0, len(s.transfers))
+	for _, id := range sortedKeys(s.transfers) {
+		out = append(out, s.loadTransfer(s.transfers[id]))
+	}
+	return out
+}
+
+// FailRefundUpdates makes marking a refund completed or failed return an error.
+func (s *Store) FailRefundUpdates(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refundUpdateErr = fail
+}
+
+// BackdateRefund moves the creation time of a refund into the past.
+func (s *Store) BackdateRefund(refundID uint, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if refund, ok := s.refunds[refundID]; ok {
+		refund.CreatedAt = refund.CreatedAt.Add(-d)
+	}
+}
+
+// Refunds returns copies of all committed refunds ordered by ID.
+func (s *Store) Refunds() []*models.Refund {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*models.Refund, 0, len(s.refunds))
+	for _, id := range sortedKeys(s.refunds) {
+		c := *s.refunds[id]
+		out = append(out, &c)
+	}
+	return out
+}
+
+// WebhookDeliveries returns copies of all queued webhook deliveries ordered by ID.
+func (s *Store) WebhookDeliveries() []*models.WebhookDelivery {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*models.WebhookDelivery, 0, len(s.deliveries))
+	for _, id := range sortedKeys(s.deliveries) {
+		c := *s.deliveries[id]
+		out = append(out, &c)
+	}
+	return out
+}
+
+// IdempotencyKeys returns copies of all stored idempotency keys ordered by ID.
+func (s *Store) IdempotencyKeys() []*models.IdempotencyKey {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*models.IdempotencyKey, 0, len(s.idempotencyKeys))
+	for _, id := range sortedKeys(s.idempotencyKeys) {
+		c := *s.idempotencyKeys[id]
+		out = append(out, &c)
+	}
+	return out
+}
+
+// Ledger returns copies of all committed ledger entries ordered by ID.
+func (s *Store) Ledger() []*models.LedgerEntry {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*models.LedgerEntry, 0, len(s.ledger))
+	for _, id := range sortedKeys(s.ledger) {
+		c := *s.ledger[id]
+		out = append(out, &c)
+	}
+	return out
+}
+
+// createLedgerEntries skips postings that already exist like the unique index does. Must be called with s.mu held.
+func (s *Store) createLedgerEntries(entries []*models.LedgerEntry) {
+	for _, entry := range entries {
+		if s.ledgerEntryExists(entry.Reference, entry.Account) {
+			continue
+		}
+		entry.Model = s.newModel()
+		c := *entry
+		s.ledger[c.ID] = &c
+	}
+}
+
+func (s *Store) ledgerEntryExists(reference, account string) bool {
+	for _, existing := range s.ledger {
+		if existing.Reference == reference && existing.Account == account {
+			return true
+		}
+	}
+	return false
+}
+
+// vendorBalance sums the vendor account entries. Must be called with s.mu held.
+func (s *Store) vendorBalance(vendorID uint) int64 {
+	var balance int64
+	for _, entry := range s.ledger {
+		if entry.VendorID == vendorID && entry.Account == models.LedgerAccountVendor {
+			balance += entry.Amount
+		}
+	}
+	return balance
+}
+
+// putTransaction stores a copy of tx without its relations. Must be called with s.mu held.
+func (s *Store) putTransaction(tx *models.Transaction) {
+	c := *tx
+	c.Vendor = models.Vendor{}
+	c.Pos = nil
+	c.SubTransactions = nil
+	c.LineItems = nil
+	c.Refunds = nil
+	c.Transfer = nil
+	s.transactions[c.ID] = &c
+}
+
+// loadTransaction returns a copy of tx with SubTransactions, LineItems, Refunds and Pos populated. Must be called with s.mu held.
+func (s *Store) loadTransaction(tx *models.Transaction) *models.Transaction {
+	c := *tx
+	c.SubTransactions = nil
+	for _, id := range sortedKeys(s.subTransactions) {
+		sub := s.subTransactions[id]
+		if sub.TransactionID == tx.ID {
+			sc := *sub
+			c.SubTransactions = append(c.SubTransactions, &sc)
+		}
+	}
+	c.LineItems = nil
+	for _, id := range sortedKeys(s.lineItems) {
+		item := s.lineItems[id]
+		if item.TransactionID == tx.ID {
+			ic := *item
+			c.LineItems = append(c.LineItems, &ic)
+		}
+	}
+	c.Refunds = nil
+	for _, id := range sortedKeys(s.refunds) {
+		refund := s.refunds[id]
+		if refund.TransactionID == tx.ID && refund.Status != models.RefundStatusFailed {
+			rc := *refund
+			c.Refunds = append(c.Refunds, &rc)
+		}
+	}
+	if tx.PosID != nil {
+		if p, ok := s.pos[*tx.PosID]; ok {
+			pc := *p
+			c.Pos = &pc
+		}
+	}
+	return &c
+}
+
+// loadTransfer returns a copy of transfer with its Transactions and Destinations populated. Must be called with s.mu held.
+func (s *Store) loadTransfer(transfer *models.Transfer) *models.Transfer {
+	c := *transfer
+	c.Transactions = nil
+	for _, id := range sortedKeys(s.transactions) {
+		tx := s.transactions[id]
+		if tx.TransferID != nil && *tx.TransferID == transfer.ID {
+			tc := *tx
+			c.Transactions = append(c.Transactions, &tc)
+		}
+	}
+	c.Destinations = nil
+	for _, id := range sortedKeys(s.transferOutputs) {
+		if output := s.transferOutputs[id]; output.TransferID == transfer.ID {
+			oc := *output
+			c.Destinations = append(c.Destinations, &oc)
+		}
+	}
+	return &c
+}
+
+type snapshot struct {
+	nextID          uint
+	invites         map[uint]models.Invite
+	vendors         map[uint]models.Vendor
+	pos             map[uint]models.Pos
+	transactions    map[uint]models.Transaction
+	subTransactions map[uint]models.SubTransaction
+	lineItems       map[uint]models.LineItem
+	transfers       map[uint]models.Transfer
+	transferOutputs map[uint]models.TransferDestination
+	destinations    map[uint]models.PayoutDestination
+	addressChanges  map[uint]models.PayoutAddressChange
+	ledger          map[uint]models.LedgerEntry
+	refunds         map[uint]models.Refund
+	webhooks        map[uint]models.WebhookEndpoint
+	deliveries      map[uint]models.WebhookDelivery
+	apiKeys         map[uint]models.APIKey
+	idempotencyKeys map[uint]models.IdempotencyKey
+	categories      map[uint]models.ProductCategory
+	products        map[uint]models.Product
+}
+
+func copyValues[T any](in map[uint]*T) map[uint]T {
+	out := make(map[uint]T, len(in))
+	for k, v := range in {
+		out[k] = *v
+	}
+	return out
+}
+
+func restoreValues[T any](in map[uint]T) map[uint]*T {
+	out := make(map[uint]*T, len(in))
+	for k, v := range in {
+		v := v
+		out[k] = &v
+	}
+	return out
+}
+
+func (s *Store) snapshot() snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return snapshot{
+		nextID:          s.nextID,
+		invites:         copyValues(s.invites),
+		vendors:         copyValues(s.vendors),
+		pos:             copyValues(s.pos),
+		transactions:    copyValues(s.transactions),
+		subTransactions: copyValues(s.subTransactions),
+		lineItems:       copyValues(s.lineItems),
+		transfers:       copyValues(s.transfers),
+		transferOutputs: copyValues(s.transferOutputs),
+		destinations:    copyValues(s.destinations),
+		addressChanges:  copyValues(s.addressChanges),
+		ledger:          copyValues(s.ledger),
+		refunds:         copyValues(s.refunds),
+		webhooks:        copyValues(s.webhooks),
+		deliveries:      copyValues(s.deliveries),
+		apiKeys:         copyValues(s.apiKeys),
+		idempotencyKeys: copyValues(s.idempotencyKeys),
+		categories:      copyValues(s.categories),
+		products:        copyValues(s.products),
+	}
+}
+
+func (s *Store) restore(snap snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID = snap.nextID
+	s.invites = restoreValues(snap.invites)
+	s.vendors = restoreValues(snap.vendors)
+	s.pos = restoreValues(snap.pos)
+	s.transactions = restoreValues(snap.transactions)
+	s.subTransactions = restoreValues(snap.subTransactions)
+	s.lineItems = restoreValues(snap.lineItems)
+	s.transfers = restoreValues(snap.transfers)
+	s.transferOutputs = restoreValues(snap.transferOutputs)
+	s.destinations = restoreValues(snap.destinations)
+	s.addressChanges = restoreValues(snap.addressChanges)
+	s.ledger = restoreValues(snap.ledger)
+	s.refunds = restoreValues(snap.refunds)
+	s.webhooks = restoreValues(snap.webhooks)
+	s.deliveries = restoreValues(snap.deliveries)
+	s.apiKeys = restoreValues(snap.apiKeys)
+	s.idempotencyKeys = restoreValues(snap.idempotencyKeys)
+	s.categories = restoreValues(snap.categories)
+	s.products = restoreValues(snap.products)
+}
+
+// runInTransaction gives fn all-or-nothing semantics by restoring a snapshot on error or panic.
+func (s *Store) runInTransaction(fn func() error) (err error) {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	snap := s.snapshot()
+	defer func() {
+		if r := recover(); r != nil {
+			s.restore(snap)
+			panic(r)
+		}
+	}()
+	if err = fn(); err != nil {
+		s.restore(snap)
+	}
+	return err
+}
+
+func sortedKeys[T any](m map[uint]*T) []uint {
+	keys := make([]uint, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func reversed(ids []uint) []uint {
+	out := make([]uint, len(ids))
+	for i, id := range ids {
+		out[len(ids)-1-i] = id
+	}
+	return out
+}
+
+func isDeleted(m gorm.Model) bool {
+	return m.DeletedAt.Valid
+}
+
+func softDelete(m *gorm.Model) {
+	m.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+}
+
+// Subaddress returns a deterministic string that passes the vendor subaddress validation.
+func Subaddress(seed byte) string {
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	var b strings.Builder
+	b.WriteString("8A")
+	for i := 0; i < 93; i++ {
+		b.WriteByte(alphabet[(int(seed)+i)%len(alphabet)])
+	}
+	return b.String()
+}
@@ -0,0 +1,621 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/features/vendor"
+	"gorm.io/gorm"
+)
+
+// VendorRepository implements vendor.VendorRepository.
+type VendorRepository struct{ store *Store }
+
+func (s *Store) VendorRepository() *VendorRepository { return &VendorRepository{store: s} }
+
+func (r *VendorRepository) VendorByNameExists(ctx context.Context, name string) (bool, error) {
+	_, err := r.store.AuthRepository().FindVendorByName(ctx, name)
+	return err == nil, nil
+}
+
+func (r *VendorRepository) FindInviteByCode(ctx context.Context, inviteCode string) (*models.Invite, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, invite := range r.store.invites {
+		if invite.InviteCode == inviteCode {
+			c := *invite
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *VendorRepository) CreateVendor(ctx context.Context, v *models.Vendor) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	v.Model = r.store.newModel()
+	if v.PasswordVersion == 0 {
+		v.PasswordVersion = 1
+	}
+	c := *v
+	c.Pos = nil
+	c.Transactions = nil
+	r.store.vendors[c.ID] = &c
+	return nil
+}
+
+func (r *VendorRepository) SetInviteToUsed(ctx context.Context, inviteID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if invite, ok := r.store.invites[inviteID]; ok {
+		invite.Used = true
+	}
+	return nil
+}
+
+func (r *VendorRepository) GetVendorByID(ctx context.Context, vendorID uint) (*models.Vendor, error) {
+	return r.store.AuthRepository().FindVendorByID(ctx, vendorID)
+}
+
+func (r *VendorRepository) DeleteVendor(ctx context.Context, vendorID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if v, ok := r.store.vendors[vendorID]; ok {
+		softDelete(&v.Model)
+	}
+	return nil
+}
+
+func (r *VendorRepository) DeleteAllTransactionsForVendor(ctx context.Context, vendorID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, tx := range r.store.transactions {
+		if tx.VendorID == vendorID {
+			softDelete(&tx.Model)
+		}
+	}
+	return nil
+}
+
+func (r *VendorRepository) DeleteAllPosForVendor(ctx context.Context, vendorID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, p := range r.store.pos {
+		if p.VendorID == vendorID {
+			softDelete(&p.Model)
+		}
+	}
+	return nil
+}
+
+func (r *VendorRepository) PosByNameExistsForVendor(ctx context.Context, name string, vendorID uint) (bool, error) {
+	_, err := r.store.AuthRepository().FindPosByVendorIDAndName(ctx, vendorID, name)
+	return err == nil, nil
+}
+
+func (r *VendorRepository) CreatePos(ctx context.Context, p *models.Pos) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	p.Model = r.store.newModel()
+	if p.PasswordVersion == 0 {
+		p.PasswordVersion = 1
+	}
+	c := *p
+	c.Vendor = models.Vendor{}
+	c.DeviceTransactions = nil
+	r.store.pos[c.ID] = &c
+	return nil
+}
+
+func (r *VendorRepository) GetBalance(ctx context.Context, vendorID uint) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.store.vendorBalance(vendorID), nil
+}
+
+func (r *VendorRepository) GetHeldBalance(ctx context.Context, vendorID uint, confirmedAfter time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var held int64
+	for _, entry := range r.store.ledger {
+		if entry.VendorID != vendorID || entry.Account != models.LedgerAccountVendor || entry.Kind != models.LedgerKindPayment || entry.TransactionID == nil {
+			continue
+		}
+		tx, ok := r.store.transactions[*entry.TransactionID]
+		if !ok || isDeleted(tx.Model) || tx.Status == models.TransactionStatusReversed {
+			continue
+		}
+		if tx.Status == models.TransactionStatusAtRisk || (tx.ConfirmedAt != nil && tx.ConfirmedAt.After(confirmedAfter)) {
+			held += entry.Amount
+		}
+	}
+	return held, nil
+}
+
+func (r *VendorRepository) GetActiveTransferByVendorID(ctx context.Context, vendorID uint) (*models.Transfer, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.transfers) {
+		t := r.store.transfers[id]
+		if t.VendorID == vendorID && !t.Completed && !isDeleted(t.Model) {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *VendorRepository) GetAllTransferableTransactions(ctx context.Context, vendorID uint) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var out []*models.Transaction
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		held := slices.Contains(models.HeldTransactionStatuses, tx.Status)
+		if tx.VendorID == vendorID && tx.Confirmed && !tx.Transferred && !held && !isDeleted(tx.Model) {
+			c := *tx
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) CreateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	transfer.Model = r.store.newModel()
+	c := *transfer
+	c.Vendor = models.Vendor{}
+	c.Transactions = nil
+	c.Destinations = nil
+	r.store.transfers[c.ID] = &c
+	for _, output := range transfer.Destinations {
+		output.Model = r.store.newModel()
+		output.TransferID = c.ID
+		oc := *output
+		r.store.transferOutputs[oc.ID] = &oc
+	}
+	// gorm upserts the has-many association, linking the transactions to the transfer
+	for _, tx := range transfer.Transactions {
+		if stored, ok := r.store.transactions[tx.ID]; ok {
+			id := c.ID
+			stored.TransferID = &id
+		}
+	}
+	return nil
+}
+
+func (r *VendorRepository) GetTransferAccountsToComplete(ctx context.Context) ([]uint32, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	seen := make(map[uint32]bool)
+	out := []uint32{}
+	for _, t := range r.store.transfers {
+		if !t.Completed && !isDeleted(t.Model) && !seen[t.WalletAccountIndex] {
+			seen[t.WalletAccountIndex] = true
+			out = append(out, t.WalletAccountIndex)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func (r *VendorRepository) GetTransfersToComplete(ctx context.Context, accountIndex uint32, limit int) ([]*models.Transfer, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transfer{}
+	for _, id := range sortedKeys(r.store.transfers) {
+		t := r.store.transfers[id]
+		if t.Completed || isDeleted(t.Model) || t.WalletAccountIndex != accountIndex {
+			continue
+		}
+		out = append(out, r.store.loadTransfer(t))
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) MarkTransactionsTransferred(ctx context.Context, transferID uint, transactionIDs []uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range transactionIDs {
+		if tx, ok := r.store.transactions[id]; ok {
+			tid := transferID
+			tx.Transferred = true
+			tx.TransferID = &tid
+		}
+	}
+	return nil
+}
+
+func (r *VendorRepository) MarkTransferCompleted(ctx context.Context, transferID uint, amountTransferred int64, txHash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	t, ok := r.store.transfers[transferID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	hash := txHash
+	amount := amountTransferred
+	t.Completed = true
+	t.TxHash = &hash
+	t.AmountTransferred = &amount
+	return nil
+}
+
+func (r *VendorRepository) MarkTransferDestinationCompleted(ctx context.Context, destinationID uint, amountTransferred int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	output, ok := r.store.transferOutputs[destinationID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	amount := amountTransferred
+	output.AmountTransferred = &amount
+	return nil
+}
+
+func (r *VendorRepository) GetPosDevicesByVendorID(ctx context.Context, vendorID uint) ([]*models.Pos, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Pos{}
+	for _, id := range reversed(sortedKeys(r.store.pos)) {
+		p := r.store.pos[id]
+		if p.VendorID == vendorID && !isDeleted(p.Model) {
+			c := *p
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) FindTippedTransactions(ctx context.Context, vendorID uint, from time.Time, to time.Time) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		if tx.VendorID == vendorID && tx.TipAmount > 0 && tx.Accepted && tx.Status != models.TransactionStatusReversed &&
+			!tx.CreatedAt.Before(from) && tx.CreatedAt.Before(to) && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) FindTransactionsByVendorID(ctx context.Context, vendorID uint) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range reversed(sortedKeys(r.store.transactions)) {
+		tx := r.store.transactions[id]
+		if tx.VendorID == vendorID && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) GetTransactionByID(ctx context.Context, transactionID uint) (*models.Transaction, error) {
+	return r.store.PosRepository().FindTransactionByID(ctx, transactionID)
+}
+
+func (r *VendorRepository) FindLatePaymentTransactions(ctx context.Context) ([]*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Transaction{}
+	for _, id := range sortedKeys(r.store.transactions) {
+		tx := r.store.transactions[id]
+		if tx.Status == models.TransactionStatusLatePayment && !isDeleted(tx.Model) {
+			out = append(out, r.store.loadTransaction(tx))
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) ResolveLatePayment(ctx context.Context, transactionID uint, status string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if tx, ok := r.store.transactions[transactionID]; ok && tx.Status == models.TransactionStatusLatePayment {
+		tx.Status = status
+		tx.Accepted = true
+	}
+	return nil
+}
+
+func (r *VendorRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	refund.Model = r.store.newModel()
+	c := *refund
+	r.store.refunds[c.ID] = &c
+	return nil
+}
+
+func (r *VendorRepository) AddRefundedAmount(ctx context.Context, transactionID uint, amount int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if tx, ok := r.store.transactions[transactionID]; ok {
+		tx.AmountRefunded += amount
+	}
+	return nil
+}
+
+func (r *VendorRepository) SetTransactionStatus(ctx context.Context, transactionID uint, status string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if tx, ok := r.store.transactions[transactionID]; ok {
+		tx.Status = status
+	}
+	return nil
+}
+
+func (r *VendorRepository) MarkRefundCompleted(ctx context.Context, refundID uint, amountTransferred int64, txHash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if r.store.refundUpdateErr {
+		return errors.New("refund update failed")
+	}
+	refund, ok := r.store.refunds[refundID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	hash := txHash
+	amount := amountTransferred
+	refund.TxHash = &hash
+	refund.AmountTransferred = &amount
+	refund.Status = models.RefundStatusCompleted
+	return nil
+}
+
+func (r *VendorRepository) MarkRefundFailed(ctx context.Context, refundID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if r.store.refundUpdateErr {
+		return errors.New("refund update failed")
+	}
+	refund, ok := r.store.refunds[refundID]
+	if !ok || refund.Status != models.RefundStatusPending {
+		return gorm.ErrRecordNotFound
+	}
+	refund.Status = models.RefundStatusFailed
+	return nil
+}
+
+func (r *VendorRepository) GetRefundByID(ctx context.Context, refundID uint) (*models.Refund, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	refund, ok := r.store.refunds[refundID]
+	if !ok || isDeleted(refund.Model) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := *refund
+	return &c, nil
+}
+
+func (r *VendorRepository) FindPendingRefunds(ctx context.Context) ([]*models.Refund, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var out []*models.Refund
+	for _, id := range sortedKeys(r.store.refunds) {
+		refund := r.store.refunds[id]
+		if refund.Status == models.RefundStatusPending && !isDeleted(refund.Model) {
+			c := *refund
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) RefundTxHashExists(ctx context.Context, txHash string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, refund := range r.store.refunds {
+		if refund.TxHash != nil && *refund.TxHash == txHash && !isDeleted(refund.Model) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *VendorRepository) CreateLedgerEntries(ctx context.Context, entries []*models.LedgerEntry) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.createLedgerEntries(entries)
+	return nil
+}
+
+func (r *VendorRepository) FindLedgerEntriesByVendorID(ctx context.Context, vendorID uint) ([]*models.LedgerEntry, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.LedgerEntry{}
+	for _, id := range sortedKeys(r.store.ledger) {
+		entry := r.store.ledger[id]
+		if entry.VendorID == vendorID && entry.Account == models.LedgerAccountVendor {
+			c := *entry
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) GetConfirmationTiers(ctx context.Context, vendorID uint) ([]*models.ConfirmationTier, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.ConfirmationTier{}
+	if v, ok := r.store.vendors[vendorID]; ok {
+		for _, tier := range v.ConfirmationTiers {
+			c := tier
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+// UpdateConfirmationPolicy keeps the tiers on the vendor, the way the Postgres repository preloads them.
+func (r *VendorRepository) UpdateConfirmationPolicy(ctx context.Context, vendor *models.Vendor, tiers []*models.ConfirmationTier) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	v, ok := r.store.vendors[vendor.ID]
+	if !ok {
+		return nil
+	}
+	v.ConfirmationCurrency = vendor.ConfirmationCurrency
+	v.DefaultConfirmations = vendor.DefaultConfirmations
+	v.PayableConfirmations = vendor.PayableConfirmations
+	v.ConfirmationTiers = make([]models.ConfirmationTier, 0, len(tiers))
+	for _, tier := range tiers {
+		c := *tier
+		c.Model = r.store.newModel()
+		v.ConfirmationTiers = append(v.ConfirmationTiers, c)
+	}
+	return nil
+}
+
+func (r *VendorRepository) UpdatePayoutSettings(ctx context.Context, vendor *models.Vendor) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if v, ok := r.store.vendors[vendor.ID]; ok {
+		v.PayoutSchedule = vendor.PayoutSchedule
+		v.PayoutWeekday = vendor.PayoutWeekday
+		v.PayoutHour = vendor.PayoutHour
+		v.PayoutThreshold = vendor.PayoutThreshold
+		v.MinPayoutAmount = vendor.MinPayoutAmount
+		v.LastScheduledPayout = vendor.LastScheduledPayout
+	}
+	return nil
+}
+
+func (r *VendorRepository) FindVendorsWithAutomaticPayouts(ctx context.Context) ([]*models.Vendor, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.Vendor{}
+	for _, id := range sortedKeys(r.store.vendors) {
+		v := r.store.vendors[id]
+		if !isDeleted(v.Model) && (v.PayoutSchedule != "" || v.PayoutThreshold > 0) {
+			c := *v
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) SetLastScheduledPayout(ctx context.Context, vendorID uint, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if v, ok := r.store.vendors[vendorID]; ok {
+		v.LastScheduledPayout = &at
+	}
+	return nil
+}
+
+func (r *VendorRepository) GetPayoutDestinations(ctx context.Context, vendorID uint) ([]*models.PayoutDestination, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.PayoutDestination{}
+	for _, id := range sortedKeys(r.store.destinations) {
+		if d := r.store.destinations[id]; d.VendorID == vendorID {
+			c := *d
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) ReplacePayoutDestinations(ctx context.Context, vendorID uint, destinations []*models.PayoutDestination) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for id, d := range r.store.destinations {
+		if d.VendorID == vendorID {
+			delete(r.store.destinations, id)
+		}
+	}
+	for _, d := range destinations {
+		d.Model = r.store.newModel()
+		c := *d
+		r.store.destinations[c.ID] = &c
+	}
+	return nil
+}
+
+func (r *VendorRepository) UpdateMoneroSubaddress(ctx context.Context, vendorID uint, subaddress string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if v, ok := r.store.vendors[vendorID]; ok {
+		v.MoneroSubaddress = subaddress
+	}
+	return nil
+}
+
+func (r *VendorRepository) CreatePayoutAddressChange(ctx context.Context, change *models.PayoutAddressChange) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	change.Model = r.store.newModel()
+	c := *change
+	r.store.addressChanges[c.ID] = &c
+	return nil
+}
+
+func (r *VendorRepository) UpdatePayoutAddressChange(ctx context.Context, change *models.PayoutAddressChange) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	c, ok := r.store.addressChanges[change.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	c.Status = change.Status
+	c.ConfirmTokenHash = change.ConfirmTokenHash
+	c.EffectiveAt = change.EffectiveAt
+	c.ResolvedAt = change.ResolvedAt
+	return nil
+}
+
+func (r *VendorRepository) FindPayoutAddressChanges(ctx context.Context, vendorID uint) ([]*models.PayoutAddressChange, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	ids := sortedKeys(r.store.addressChanges)
+	out := []*models.PayoutAddressChange{}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if c := r.store.addressChanges[ids[i]]; c.VendorID == vendorID {
+			cc := *c
+			out = append(out, &cc)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) FindPayoutAddressChangeByTokenHash(ctx context.Context, tokenHash string) (*models.PayoutAddressChange, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range sortedKeys(r.store.addressChanges) {
+		c := r.store.addressChanges[id]
+		if c.ConfirmTokenHash == tokenHash && c.Status == models.PayoutAddressChangeUnconfirmed {
+			cc := *c
+			return &cc, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *VendorRepository) FindDuePayoutAddressChanges(ctx context.Context, now time.Time) ([]*models.PayoutAddressChange, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.PayoutAddressChange{}
+	for _, id := range sortedKeys(r.store.addressChanges) {
+		c := r.store.addressChanges[id]
+		if c.Status == models.PayoutAddressChangePending && c.EffectiveAt != nil && !c.EffectiveAt.After(now) {
+			cc := *c
+			out = append(out, &cc)
+		}
+	}
+	return out, nil
+}
+
+func (r *VendorRepository) RunInTransaction(ctx context.Context, fn func(repo vendor.VendorRepository) error) error {
+	return r.store.runInTransaction(func() error { return fn(r) })
+}
@@ -0,0 +1,331 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/rpc"
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/thirdparty/moneropay"
+)
+
+// RPCError is returned by a FakeWalletRPC handler to produce a JSON-RPC error response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RPCHandler answers a single JSON-RPC method. The returned result is marshalled as-is.
+type RPCHandler func(params json.RawMessage) (any, *RPCError)
+
+// RPCCall is a recorded JSON-RPC request.
+type RPCCall struct {
+	Method string
+	Params json.RawMessage
+}
+
+// FakeWalletRPC is an httptest server speaking the monero-wallet-rpc JSON-RPC protocol.
+// Unknown methods answer with error -32601 like the real wallet.
+type FakeWalletRPC struct {
+	Server *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]RPCHandler
+	calls    []RPCCall
+
+	// TransferFee is subtracted from every destination by the default transfer handler.
+	TransferFee int64
+	transfers   int
+
+	subaddresses map[string]walletSubaddress
+	incoming     map[string][]moneropay.Transaction
+	outgoing     []walletOutgoing
+	accounts     uint32
+	balances     map[uint32][2]int64
+}
+
+// walletOutgoing is a transfer relayed by the default transfer handler
+type walletOutgoing struct {
+	AccountIndex uint32
+	TxHash       string
+	Timestamp    time.Time
+	Destinations []map[string]any
+}
+
+type walletSubaddress struct {
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+}
+
+func NewFakeWalletRPC(t testing.TB) *FakeWalletRPC {
+	f := &FakeWalletRPC{
+		handlers:     make(map[string]RPCHandler),
+		subaddresses: make(map[string]walletSubaddress),
+		incoming:     make(map[string][]moneropay.Transaction),
+		balances:     make(map[uint32][2]int64),
+	}
+	f.Handle("get_version", func(params json.RawMessage) (any, *RPCError) {
+		return map[string]any{"version": 196623, "release": true}, nil
+	})
+	f.Handle("get_balance", f.defaultGetBalance)
+	f.Handle("create_account", f.defaultCreateAccount)
+	f.Handle("transfer", f.defaultTransfer)
+	f.Handle("create_address", f.defaultCreateAddress)
+	f.Handle("get_address_index", f.defaultGetAddressIndex)
+	f.Handle("get_transfers", f.defaultGetTransfers)
+
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Client returns a wallet RPC client pointed at the fake.
+func (f *FakeWalletRPC) Client() *rpc.Client {
+	return rpc.NewClient(f.Server.URL+"/json_rpc", "", "")
+}
+
+// Handle installs or replaces the handler for method.
+func (f *FakeWalletRPC) Handle(method string, handler RPCHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = handler
+}
+
+// Handler returns the handler installed for method, so a test can wrap it.
+func (f *FakeWalletRPC) Handler(method string) RPCHandler {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.handlers[method]
+}
+
+// Calls returns the recorded calls to method, or all calls when method is empty.
+func (f *FakeWalletRPC) Calls(method string) []RPCCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []RPCCall
+	for _, call := range f.calls {
+		if method == "" || call.Method == method {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+// SetPayments replaces the incoming transfers seen on a subaddress created through create_address.
+// Transfers without confirmations are reported in the pool.
+func (f *FakeWalletRPC) SetPayments(address string, txs ...moneropay.Transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subaddresses[address]; !ok {
+		panic(fmt.Sprintf("fake wallet rpc: unknown address %s", address))
+	}
+	f.incoming[address] = append([]moneropay.Transaction(nil), txs...)
+}
+
+// SetBalance sets the balance get_balance reports for a wallet account.
+func (f *FakeWalletRPC) SetBalance(accountIndex uint32, total, unlocked int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[accountIndex] = [2]int64{total, unlocked}
+}
+
+func (f *FakeWalletRPC) defaultGetBalance(params json.RawMessage) (any, *RPCError) {
+	var p struct {
+		AccountIndex uint32 `json:"account_index"`
+		AllAccounts  bool   `json:"all_accounts"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: -1, Message: err.Error()}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var total, unlocked int64
+	for account, balance := range f.balances {
+		if p.AllAccounts || account == p.AccountIndex {
+			total += balance[0]
+			unlocked += balance[1]
+		}
+	}
+	return map[string]any{"balance": total, "unlocked_balance": unlocked}, nil
+}
+
+func (f *FakeWalletRPC) defaultCreateAccount(params json.RawMessage) (any, *RPCError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts++
+	return map[string]any{"account_index": f.accounts, "address": Subaddress(byte(50 + f.accounts))}, nil
+}
+
+// Subaddresses returns the number of subaddresses created so far.
+func (f *FakeWalletRPC) Subaddresses() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subaddresses)
+}
+
+func (f *FakeWalletRPC) defaultCreateAddress(params json.RawMessage) (any, *RPCError) {
+	var p struct {
+		AccountIndex uint32 `json:"account_index"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: -1, Message: err.Error()}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := walletSubaddress{Major: p.AccountIndex, Minor: uint32(len(f.subaddresses) + 1)}
+	address := Subaddress(byte(150 + len(f.subaddresses)))
+	f.subaddresses[address] = index
+	return map[string]any{"address": address, "address_index": index.Minor}, nil
+}
+
+func (f *FakeWalletRPC) defaultGetAddressIndex(params json.RawMessage) (any, *RPCError) {
+	var p struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: -1, Message: err.Error()}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index, ok := f.subaddresses[p.Address]
+	if !ok {
+		return nil, &RPCError{Code: -2, Message: "Address doesn't belong to the wallet"}
+	}
+	return map[string]any{"index": index}, nil
+}
+
+func (f *FakeWalletRPC) defaultGetTransfers(params json.RawMessage) (any, *RPCError) {
+	var p struct {
+		Out            bool     `json:"out"`
+		AccountIndex   uint32   `json:"account_index"`
+		SubaddrIndices []uint32 `json:"subaddr_indices"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: -1, Message: err.Error()}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, pool := []map[string]any{}, []map[string]any{}
+	for address, index := range f.subaddresses {
+		if index.Major != p.AccountIndex || !slices.Contains(p.SubaddrIndices, index.Minor) {
+			continue
+		}
+		for _, tx := range f.incoming[address] {
+			entry := map[string]any{
+				"address":           address,
+				"amount":            tx.Amount,
+				"confirmations":     tx.Confirmations,
+				"double_spend_seen": tx.DoubleSpendSeen,
+				"fee":               tx.Fee,
+				"height":            tx.Height,
+				"locked":            tx.Locked,
+				"subaddr_index":     index,
+				"timestamp":         tx.Timestamp.Unix(),
+				"txid":              tx.TxHash,
+				"unlock_time":       tx.UnlockTime,
+			}
+			if tx.Confirmations == 0 {
+				entry["height"] = 0
+				pool = append(pool, entry)
+			} else {
+				in = append(in, entry)
+			}
+		}
+	}
+
+	// relayed transfers are reported as sent right away, the fake does not mine them
+	out := []map[string]any{}
+	if p.Out {
+		for _, tx := range f.outgoing {
+			if tx.AccountIndex != p.AccountIndex {
+				continue
+			}
+			out = append(out, map[string]any{
+				"destinations": tx.Destinations,
+				"timestamp":    tx.Timestamp.Unix(),
+				"txid":         tx.TxHash,
+			})
+		}
+	}
+	return map[string]any{"in": in, "pool": pool, "out": out}, nil
+}
+
+type walletTransferParams struct {
+	Destinations []struct {
+		Amount  int64  `json:"amount"`
+		Address string `json:"address"`
+	} `json:"destinations"`
+	AccountIndex uint32 `json:"account_index"`
+	DoNotRelay   bool   `json:"do_not_relay"`
+}
+
+func (f *FakeWalletRPC) defaultTransfer(params json.RawMessage) (any, *RPCError) {
+	var p walletTransferParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: -1, Message: err.Error()}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fee := f.TransferFee
+	if !p.DoNotRelay {
+		f.transfers++
+	}
+	hash := fmt.Sprintf("wallet-transfer-%d", f.transfers)
+
+	amounts := make([]int64, len(p.Destinations))
+	destinations := make([]map[string]any, len(p.Destinations))
+	var total int64
+	for i, dest := range p.Destinations {
+		amounts[i] = dest.Amount - fee
+		destinations[i] = map[string]any{"amount": amounts[i], "address": dest.Address}
+		total += amounts[i]
+	}
+	if !p.DoNotRelay {
+		f.outgoing = append(f.outgoing, walletOutgoing{AccountIndex: p.AccountIndex, TxHash: hash, Timestamp: time.Now(), Destinations: destinations})
+	}
+	return map[string]any{
+		"amount":          total,
+		"amounts_by_dest": map[string]any{"amounts": amounts},
+		"fee":             fee * int64(len(p.Destinations)),
+		"tx_hash":         hash,
+	}, nil
+}
+
+func (f *FakeWalletRPC) serve(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string          `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, RPCCall{Method: req.Method, Params: req.Params})
+	handler, ok := f.handlers[req.Method]
+	f.mu.Unlock()
+
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	if !ok {
+		resp["error"] = RPCError{Code: -32601, Message: "Method not found"}
+	} else if result, rpcErr := handler(req.Params); rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,131 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/monerokon/xmrpos/xmrpos-backend/internal/core/models"
+	"gorm.io/gorm"
+)
+
+// WebhookRepository implements webhook.WebhookRepository.
+type WebhookRepository struct{ store *Store }
+
+func (s *Store) WebhookRepository() *WebhookRepository { return &WebhookRepository{store: s} }
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	endpoint.Model = r.store.newModel()
+	c := *endpoint
+	r.store.webhooks[c.ID] = &c
+	return nil
+}
+
+func (r *WebhookRepository) FindEndpointsByVendorID(ctx context.Context, vendorID uint) ([]*models.WebhookEndpoint, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.WebhookEndpoint{}
+	for _, id := range sortedKeys(r.store.webhooks) {
+		endpoint := r.store.webhooks[id]
+		if endpoint.VendorID == vendorID && !isDeleted(endpoint.Model) {
+			c := *endpoint
+			out = append(out, &c)
+		}
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, vendorID uint, endpointID uint) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	endpoint, ok := r.store.webhooks[endpointID]
+	if !ok || endpoint.VendorID != vendorID || isDeleted(endpoint.Model) {
+		return false, nil
+	}
+	softDelete(&endpoint.Model)
+	for _, delivery := range r.store.deliveries {
+		if delivery.EndpointID == endpointID && delivery.Status == models.WebhookDeliveryPending {
+			message := "endpoint deleted"
+			delivery.Status = models.WebhookDeliveryFailed
+			delivery.LastError = &message
+		}
+	}
+	return true, nil
+}
+
+func (r *WebhookRepository) CreateDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, delivery := range deliveries {
+		duplicate := false
+		for _, existing := range r.store.deliveries {
+			if existing.EndpointID == delivery.EndpointID && existing.EventKey == delivery.EventKey {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		delivery.Model = r.store.newModel()
+		c := *delivery
+		r.store.deliveries[c.ID] = &c
+	}
+	return nil
+}
+
+func (r *WebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.WebhookDelivery{}
+	for _, id := range sortedKeys(r.store.deliveries) {
+		delivery := r.store.deliveries[id]
+		if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		c := *delivery
+		if endpoint, ok := r.store.webhooks[c.EndpointID]; ok && !isDeleted(endpoint.Model) {
+			c.Endpoint = *endpoint
+		}
+		out = append(out, &c)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	existing, ok := r.store.deliveries[delivery.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	existing.Status = delivery.Status
+	existing.Attempts = delivery.Attempts
+	existing.NextAttemptAt = delivery.NextAttemptAt
+	existing.LastResponseCode = delivery.LastResponseCode
+	existing.LastError = delivery.LastError
+	existing.DeliveredAt = delivery.DeliveredAt
+	return nil
+}
+
+func (r *WebhookRepository) FindDeliveriesByVendorID(ctx context.Context, vendorID uint, limit int) ([]*models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	out := []*models.WebhookDelivery{}
+	for _, id := range reversed(sortedKeys(r.store.deliveries)) {
+		delivery := r.store.deliveries[id]
+		if delivery.VendorID != vendorID {
+			continue
+		}
+		c := *delivery
+		out = append(out, &c)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
@@ -6,34 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
-// ExternalAPIClient interacts with an external API
+// MoneroPayAPIClient interacts with the MoneroPay v2 API
 type MoneroPayAPIClient struct {
 	BaseURL string
 }
 
-// NewExternalAPIClient initializes an external API client with a base URL loaded from environment variables
-func NewMoneroPayAPIClient() *MoneroPayAPIClient {
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	// Read the base URL from environment variables
-	baseURL := os.Getenv("MONEROPAY_BASE_URL")
-	if baseURL == "" {
-		log.Fatal("MONEROPAY_BASE_URL environment variable is not set")
-	}
-
-	return &MoneroPayAPIClient{BaseURL: baseURL}
+// NewMoneroPayAPIClient initializes a MoneroPay API client for the given base URL
+func NewMoneroPayAPIClient(baseURL string) *MoneroPayAPIClient {
+	return &MoneroPayAPIClient{BaseURL: strings.TrimRight(baseURL, "/")}
 }
 
 var MpTransport = &http.Transport{
@@ -46,6 +31,18 @@ var MpTransport = &http.Transport{
 
 var mpClient = &http.Client{Transport: MpTransport}
 
+// StatusError is returned when the MoneroPay API answers with an unexpected HTTP status
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to %s: %s: %s", e.Op, e.Status, e.Body)
+}
+
 // GetHealth fetches the health status of services from the MoneroPay API
 func (client *MoneroPayAPIClient) GetHealth(ctx context.Context) (*HealthResponse, error) {
 	url := fmt.Sprintf("%s/health", client.BaseURL)
@@ -205,7 +202,7 @@ func (client *MoneroPayAPIClient) PostTransfer(ctx context.Context, req *Transfe
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to create transfer: %s: %s", resp.Status, strings.TrimSpace(string(bodyBytes)))
+		return nil, &StatusError{Op: "create transfer", StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(bodyBytes))}
 	}
 
 	var transferResp TransferResponse